@@ -1,70 +1,128 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
 	"fyne.io/fyne/v2/app"
-	"github.com/wangle201210/gochat/internal/config"
-	"github.com/wangle201210/gochat/internal/service/ai"
-	"github.com/wangle201210/gochat/internal/service/assistant"
-	"github.com/wangle201210/gochat/internal/storage"
+	"github.com/wangle201210/gochat/internal/cli"
+	"github.com/wangle201210/gochat/internal/service/queue"
+	"github.com/wangle201210/gochat/internal/tui"
 	"github.com/wangle201210/gochat/internal/ui"
 )
 
 func main() {
-	// 加载配置
-	configPath := config.GetConfigPath()
-	cfg, err := config.Load(configPath)
-	if err != nil {
-		log.Fatalf("加载配置失败: %v", err)
+	// 带子命令时以命令行模式运行
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
 	}
 
-	// 检查 API Key
-	if cfg.AI.APIKey == "" {
-		fmt.Println("警告: 未配置 API Key")
-		fmt.Printf("请编辑配置文件: %s\n", configPath)
-		fmt.Println("添加您的 API Key 后重新运行程序")
-
-		// 保存默认配置
-		if err := cfg.Save(configPath); err != nil {
-			log.Printf("保存配置失败: %v", err)
-		} else {
-			fmt.Printf("已创建默认配置文件: %s\n", configPath)
-		}
+	runGUI()
+}
 
+// runGUI 启动图形界面
+func runGUI() {
+	svc, err := newServices(false)
+	if errors.Is(err, errMissingAPIKey) {
 		os.Exit(1)
 	}
-
-	// 初始化数据库
-	configDir := filepath.Dir(configPath)
-	dbPath := filepath.Join(configDir, "gochat.db")
-	db, err := storage.NewDatabase(dbPath)
-	if err != nil {
-		log.Fatalf("初始化数据库失败: %v", err)
-	}
-	defer db.Close()
-
-	// 初始化 AI 服务
-	aiService, err := ai.NewService(&cfg.AI)
-	if err != nil {
-		log.Fatalf("初始化 AI 服务失败: %v", err)
-	}
-
-	// 初始化助手服务
-	assistantService, err := assistant.NewService(&cfg.Assistant)
 	if err != nil {
-		log.Fatalf("初始化助手服务失败: %v", err)
+		log.Fatal(err)
 	}
+	defer svc.Close()
 
 	// 创建 Fyne 应用
 	fyneApp := app.New()
 
-	// 创建聊天窗口，传入 UI 配置、数据库和助手服务
-	chatWindow := ui.NewChatWindow(fyneApp, aiService, assistantService, &cfg.UI, db)
+	// 创建聊天窗口，传入 UI 配置、价格表、知识库、生成队列、数据库和助手服务
+	generationQueue := queue.New(&svc.cfg.Queue)
+	chatWindow := ui.NewChatWindow(fyneApp, svc.ai, svc.assistant, &svc.cfg.UI, &svc.cfg.Pricing, svc.knowledge, generationQueue, svc.db)
 
 	// 显示窗口并运行应用
 	chatWindow.Show()
 }
+
+// runCommand 执行命令行子命令，返回进程退出码
+func runCommand(name string, args []string) int {
+	env := &cli.Env{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+	ctx := context.Background()
+
+	var err error
+	switch name {
+	case "ask", "chat":
+		var svc *services
+		if svc, err = newServices(true); err != nil {
+			break
+		}
+		defer svc.Close()
+
+		env.AI, env.Assistant, env.DB = svc.ai, svc.assistant, svc.db
+		if name == "ask" {
+			err = cli.Ask(ctx, env, args)
+		} else {
+			err = cli.Chat(ctx, env, args)
+		}
+
+	case "tui":
+		var svc *services
+		if svc, err = newServices(true); err != nil {
+			break
+		}
+		defer svc.Close()
+
+		err = tui.Run(&tui.Env{
+			AI:        svc.ai,
+			Assistant: svc.assistant,
+			DB:        svc.db,
+			LogPath:   filepath.Join(filepath.Dir(svc.configPath), "tui.log"),
+			Stderr:    os.Stderr,
+		}, args)
+
+	case "serve":
+		err = runServe(args)
+
+	case "sessions", "export", "import":
+		// 会话管理、导出和导入只需要数据库
+		var configPath string
+		if _, configPath, err = loadConfig(); err != nil {
+			break
+		}
+		if env.DB, err = openDatabase(configPath); err != nil {
+			break
+		}
+		defer env.DB.Close()
+		switch name {
+		case "sessions":
+			err = cli.Sessions(env, args)
+		case "export":
+			err = cli.Export(env, args)
+		default:
+			err = cli.Import(ctx, env, args)
+		}
+
+	case "help", "-h", "--help":
+		cli.Usage(os.Stdout)
+		return 0
+
+	default:
+		cli.Usage(os.Stderr)
+		err = fmt.Errorf("未知的命令: %s", name)
+	}
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errMissingAPIKey):
+		return 1
+	default:
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return 1
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/wangle201210/gochat/internal/server"
+	"github.com/wangle201210/gochat/internal/service/ai"
+	"github.com/wangle201210/gochat/internal/storage"
+)
+
+// runServe 执行 gochat serve：启动 OpenAI 兼容的本地 HTTP 服务，直到收到中断信号
+func runServe(args []string) error {
+	cfg, configPath, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	addr := cfg.Server.Addr
+	if addr == "" {
+		addr = server.DefaultAddr
+	}
+
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.StringVar(&addr, "addr", addr, "监听地址")
+	save := flags.Bool("save", cfg.Server.SaveSessions, "把经过接口的对话记录为会话，在图形界面的历史中可见")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "用法: gochat serve [选项]\n\n选项:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := checkAPIKey(cfg, configPath); err != nil {
+		return err
+	}
+
+	// 记录会话和 REST 接口需要数据库；REST 接口生成回复时使用与图形界面相同的 AI 服务（摘要、工具和知识库）
+	var db *storage.Database
+	var aiService *ai.Service
+	switch {
+	case len(cfg.Server.APITokens) > 0:
+		svc, err := newServices(true)
+		if err != nil {
+			return err
+		}
+		defer svc.Close()
+		db, aiService = svc.db, svc.ai
+	case *save:
+		if db, err = openDatabase(configPath); err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv, err := server.New(ctx, cfg, server.Options{DB: db, SaveSessions: *save, APITokens: cfg.Server.APITokens, AI: aiService})
+	if err != nil {
+		return err
+	}
+	return srv.ListenAndServe(ctx, addr)
+}
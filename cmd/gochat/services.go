@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/wangle201210/gochat/internal/config"
+	"github.com/wangle201210/gochat/internal/service/ai"
+	"github.com/wangle201210/gochat/internal/service/assistant"
+	"github.com/wangle201210/gochat/internal/service/knowledge"
+	"github.com/wangle201210/gochat/internal/service/mcp"
+	"github.com/wangle201210/gochat/internal/service/provider"
+	"github.com/wangle201210/gochat/internal/service/tools"
+	"github.com/wangle201210/gochat/internal/storage"
+)
+
+// services 图形界面和命令行共用的服务
+type services struct {
+	cfg        *config.Config
+	configPath string
+	db         *storage.Database
+	ai         *ai.Service
+	assistant  *assistant.Service
+	knowledge  *knowledge.Service // 向量模型不可用时为空
+	mcp        *mcp.Manager
+}
+
+// errMissingAPIKey 配置文件中缺少 API Key
+var errMissingAPIKey = errors.New("未配置 API Key")
+
+// loadConfig 加载配置文件
+func loadConfig() (*config.Config, string, error) {
+	configPath := config.GetConfigPath()
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("加载配置失败: %w", err)
+	}
+	return cfg, configPath, nil
+}
+
+// checkAPIKey 检查 API Key（本地模型等 provider 无需配置），缺少时保存默认配置便于用户编辑
+func checkAPIKey(cfg *config.Config, configPath string) error {
+	if !provider.RequiresAPIKey(cfg.AI.Provider) || cfg.AI.APIKey != "" {
+		return nil
+	}
+
+	fmt.Println("警告: 未配置 API Key")
+	fmt.Printf("请编辑配置文件: %s\n", configPath)
+	fmt.Println("添加您的 API Key 后重新运行程序")
+
+	// 保存默认配置
+	if err := cfg.Save(configPath); err != nil {
+		log.Printf("保存配置失败: %v", err)
+	} else {
+		fmt.Printf("已创建默认配置文件: %s\n", configPath)
+	}
+
+	return errMissingAPIKey
+}
+
+// openDatabase 打开配置目录下的 gochat.db
+func openDatabase(configPath string) (*storage.Database, error) {
+	dbPath := filepath.Join(filepath.Dir(configPath), "gochat.db")
+	db, err := storage.NewDatabase(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("初始化数据库失败: %w", err)
+	}
+	return db, nil
+}
+
+// newServices 初始化数据库、AI 服务、工具和知识库
+// waitMCP 为 true 时等待 MCP 服务启动完成再返回（命令行模式），否则在后台启动
+func newServices(waitMCP bool) (*services, error) {
+	cfg, configPath, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAPIKey(cfg, configPath); err != nil {
+		return nil, err
+	}
+
+	db, err := openDatabase(configPath)
+	if err != nil {
+		return nil, err
+	}
+	s := &services{cfg: cfg, configPath: configPath, db: db}
+
+	// 初始化 AI 服务
+	s.ai, err = ai.NewService(&cfg.AI)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化 AI 服务失败: %w", err)
+	}
+
+	// 初始化助手服务
+	s.assistant, err = assistant.NewService(&cfg.Assistant)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化助手服务失败: %w", err)
+	}
+
+	// 超出上下文窗口的旧消息由助手服务压缩为摘要并保存到数据库
+	s.ai.SetSummarizer(s.assistant, db)
+
+	// 注册可供模型调用的工具
+	toolRegistry := tools.NewRegistry()
+	if err := tools.RegisterBuiltins(context.Background(), toolRegistry); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("注册内置工具失败: %w", err)
+	}
+	s.ai.SetTools(toolRegistry)
+
+	// 启动 MCP 服务，启动完成后其工具自动加入注册表
+	s.mcp = mcp.NewManager()
+	if waitMCP {
+		s.mcp.Start(context.Background(), cfg.MCPServers, toolRegistry)
+	} else {
+		go s.mcp.Start(context.Background(), cfg.MCPServers, toolRegistry)
+	}
+
+	// 初始化本地知识库，向量模型不可用时只禁用知识库功能
+	embedder, embeddingModel, err := knowledge.NewEmbedder(context.Background(), &cfg.Knowledge.Embedding)
+	if err != nil {
+		log.Printf("初始化知识库失败: %v", err)
+	} else {
+		s.knowledge = knowledge.NewService(db, embedder, embeddingModel, &cfg.Knowledge)
+		s.ai.SetRetriever(s.knowledge)
+	}
+
+	return s, nil
+}
+
+// Close 关闭 MCP 服务和数据库
+func (s *services) Close() {
+	if err := s.mcp.Close(); err != nil {
+		log.Print(err)
+	}
+	if err := s.db.Close(); err != nil {
+		log.Printf("关闭数据库失败: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// Ask 执行 gochat ask：单次提问并将回复流式输出到标准输出
+// 默认不保存，-s 继续已有会话、-save 保存为新会话；管道输入作为附加内容随问题发送
+func Ask(ctx context.Context, env *Env, args []string) error {
+	flags := flag.NewFlagSet("ask", flag.ContinueOnError)
+	flags.SetOutput(env.Stderr)
+	sessionID := flags.String("s", "", "继续指定的会话并保存本次问答")
+	save := flags.Bool("save", false, "将本次问答保存为新会话")
+	modelName := flags.String("m", "", "本次使用的模型，默认使用会话或配置文件中的模型")
+	systemPrompt := flags.String("system", "", "本次使用的系统提示词")
+	autoApprove := flags.Bool("y", false, "自动允许模型发起的工具调用")
+	flags.Usage = func() {
+		fmt.Fprintln(env.Stderr, "用法: gochat ask [选项] <问题>\n\n示例:\n  gochat ask \"解释一下 Go 的 context\"\n  git diff | gochat ask \"帮我写提交说明\"\n\n选项:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	prompt := strings.TrimSpace(strings.Join(flags.Args(), " "))
+	piped, err := readPipedInput(env.Stdin)
+	if err != nil {
+		return err
+	}
+
+	// 只有管道输入时直接作为问题，否则作为附件附在问题后
+	var attachments []*models.Attachment
+	switch {
+	case prompt == "" && strings.TrimSpace(piped) == "":
+		flags.Usage()
+		return errors.New("缺少问题")
+	case prompt == "":
+		prompt = piped
+	case strings.TrimSpace(piped) != "":
+		attachments = append(attachments, models.NewAttachment("stdin", "text/plain", []byte(piped)))
+	}
+
+	session := models.NewSession()
+	persist := *save || *sessionID != ""
+	switch {
+	case *sessionID != "":
+		if session, err = findSession(env.DB, *sessionID); err != nil {
+			return err
+		}
+	case *save:
+		if err := env.DB.SaveSession(session); err != nil {
+			return err
+		}
+	}
+
+	// 命令行选项只影响本次提问，不写回会话设置
+	if *modelName != "" || *systemPrompt != "" {
+		override := *session
+		if *modelName != "" {
+			override.Model = *modelName
+		}
+		if *systemPrompt != "" {
+			override.SystemPrompt = *systemPrompt
+		}
+		session = &override
+	}
+
+	conv, err := newConversation(env, session, persist)
+	if err != nil {
+		return err
+	}
+	conv.autoApprove = *autoApprove
+	defer conv.wait()
+
+	if err := conv.send(ctx, prompt, attachments); err != nil {
+		return err
+	}
+
+	if *save {
+		fmt.Fprintf(env.Stderr, "已保存到会话 %s\n", session.ID)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// chatHelp 交互式对话中可用的命令
+const chatHelp = `命令:
+  /new      开始新会话
+  /history  显示当前会话的消息
+  /help     显示帮助
+  /exit     退出（也可以按 Ctrl+D）
+行尾输入 \ 可以继续输入下一行；生成回复时按 Ctrl+C 停止生成`
+
+// Chat 执行 gochat chat：在终端中交互式对话，会话与图形界面共用 gochat.db
+func Chat(ctx context.Context, env *Env, args []string) error {
+	flags := flag.NewFlagSet("chat", flag.ContinueOnError)
+	flags.SetOutput(env.Stderr)
+	sessionID := flags.String("s", "", "继续指定的会话")
+	continueLast := flags.Bool("c", false, "继续最近的会话")
+	autoApprove := flags.Bool("y", false, "自动允许模型发起的工具调用")
+	flags.Usage = func() {
+		fmt.Fprintln(env.Stderr, "用法: gochat chat [选项]\n\n选项:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	session, created, err := chatSession(env, *sessionID, *continueLast)
+	if err != nil {
+		return err
+	}
+
+	input := bufio.NewReader(env.Stdin)
+	for {
+		conv, err := newConversation(env, session, true)
+		if err != nil {
+			return err
+		}
+		conv.unsaved = !created
+		conv.autoApprove = *autoApprove
+		conv.input = input
+
+		fmt.Fprintf(env.Stdout, "会话: %s (%s)，已有 %d 条消息，输入 /help 查看命令\n\n", session.Title, session.ID, len(conv.state.History()))
+
+		next, err := chatLoop(ctx, env, conv, input)
+		conv.wait()
+		if err != nil || next == nil {
+			return err
+		}
+		session, created = next, false
+	}
+}
+
+// chatSession 返回要继续的会话，created 表示会话已在数据库中；
+// 未指定时返回尚未保存的新会话，发送第一条消息时才写入数据库
+func chatSession(env *Env, sessionID string, continueLast bool) (session *models.Session, created bool, err error) {
+	if sessionID != "" {
+		session, err := findSession(env.DB, sessionID)
+		return session, err == nil, err
+	}
+
+	if continueLast {
+		sessions, err := env.DB.ListSessions()
+		if err != nil {
+			return nil, false, err
+		}
+		if len(sessions) > 0 {
+			return sessions[0], true, nil
+		}
+	}
+
+	return models.NewSession(), false, nil
+}
+
+// chatLoop 读取用户输入并逐条发送，输入 /new 时返回尚未保存的新会话，退出时返回 nil
+func chatLoop(ctx context.Context, env *Env, conv *conversation, input *bufio.Reader) (*models.Session, error) {
+	for {
+		line, err := readInput(env.Stdout, input)
+		if errors.Is(err, io.EOF) && line == "" {
+			fmt.Fprintln(env.Stdout)
+			return nil, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("读取输入失败: %w", err)
+		}
+
+		switch line {
+		case "":
+			continue
+		case "/exit", "/quit":
+			return nil, nil
+		case "/help":
+			fmt.Fprintln(env.Stdout, chatHelp)
+			continue
+		case "/history":
+			for _, msg := range conv.state.History() {
+				printMessage(env.Stdout, msg)
+			}
+			continue
+		case "/new":
+			return models.NewSession(), nil
+		}
+
+		// 生成期间 Ctrl+C 只停止本次回复
+		replyCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		err = conv.send(replyCtx, line, nil)
+		stop()
+		if err != nil {
+			fmt.Fprintf(env.Stderr, "错误: %v\n", err)
+		}
+		fmt.Fprintln(env.Stdout)
+	}
+}
+
+// readInput 读取一条输入，行尾为 \ 时继续读取下一行
+func readInput(w io.Writer, input *bufio.Reader) (string, error) {
+	var lines []string
+	prompt := "> "
+	for {
+		fmt.Fprint(w, prompt)
+		line, err := input.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+
+		if strings.HasSuffix(line, "\\") && err == nil {
+			lines = append(lines, strings.TrimSuffix(line, "\\"))
+			prompt = "… "
+			continue
+		}
+
+		lines = append(lines, line)
+		return strings.TrimSpace(strings.Join(lines, "\n")), err
+	}
+}
@@ -0,0 +1,105 @@
+// Package cli 实现无界面的命令行模式：单次提问、交互式对话、会话管理、导出和导入
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/ai"
+	"github.com/wangle201210/gochat/internal/service/assistant"
+	"github.com/wangle201210/gochat/internal/storage"
+)
+
+// Env 命令行模式使用的服务和输入输出
+type Env struct {
+	AI        *ai.Service
+	Assistant *assistant.Service
+	DB        *storage.Database
+	Stdin     *os.File
+	Stdout    io.Writer
+	Stderr    io.Writer
+}
+
+// Usage 输出命令行用法
+func Usage(w io.Writer) {
+	fmt.Fprint(w, `用法:
+  gochat                          启动图形界面
+  gochat ask [选项] <问题>        单次提问，回复流式输出到标准输出；管道输入会作为附加内容
+  gochat chat [选项]              在终端中交互式对话，会话保存到 gochat.db
+  gochat tui [选项]               启动全屏终端界面，与图形界面共用会话
+  gochat serve [选项]             启动 OpenAI 兼容的本地 HTTP 服务（/v1/chat/completions、/v1/models）
+  gochat sessions list [-n 数量]  列出会话
+  gochat sessions show <会话ID>   显示会话当前分支的消息
+  gochat sessions delete <会话ID> 删除会话
+  gochat export [选项] <会话ID>...  导出会话为 Markdown、JSON 或 HTML，-all 导出所有会话
+  gochat import <文件>...         导入 ChatGPT、GoChat 或其他客户端导出的对话（JSON、Markdown、.zip）
+
+使用 "gochat <命令> -h" 查看各命令的选项
+`)
+}
+
+// readPipedInput 标准输入为管道或文件时读取全部内容，终端输入时返回空
+func readPipedInput(stdin *os.File) (string, error) {
+	if stdin == nil {
+		return "", nil
+	}
+	info, err := stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice != 0 {
+		return "", nil
+	}
+
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", fmt.Errorf("读取标准输入失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// findSession 按 ID 查找会话
+func findSession(db *storage.Database, id string) (*models.Session, error) {
+	session, err := db.GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, fmt.Errorf("会话不存在: %s", id)
+	}
+	return session, nil
+}
+
+// printMessage 以纯文本格式输出一条消息
+func printMessage(w io.Writer, msg *models.Message) {
+	timestamp := msg.Timestamp.Format(time.DateTime)
+	switch msg.Role {
+	case models.RoleUser:
+		fmt.Fprintf(w, "[我] %s\n", timestamp)
+	case models.RoleAssistant:
+		fmt.Fprintf(w, "[助手] %s\n", timestamp)
+	case models.RoleTool:
+		fmt.Fprintf(w, "[工具结果: %s] %s\n", msg.ToolName, timestamp)
+	default:
+		fmt.Fprintf(w, "[%s] %s\n", msg.Role, timestamp)
+	}
+
+	for _, a := range msg.Attachments {
+		fmt.Fprintf(w, "📎 %s (%d 字节)\n", a.Name, a.Size)
+	}
+	if msg.Content != "" {
+		fmt.Fprintln(w, strings.TrimRight(msg.Content, "\n"))
+	}
+	for _, call := range msg.ToolCalls {
+		fmt.Fprintf(w, "🔧 调用工具 %s %s\n", call.Name, call.Arguments)
+	}
+
+	switch msg.Status {
+	case models.StatusInterrupted:
+		fmt.Fprintln(w, "⏹ 已中断")
+	case models.StatusFailed:
+		fmt.Fprintln(w, "⚠ 生成失败")
+	}
+	fmt.Fprintln(w)
+}
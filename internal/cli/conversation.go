@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/ai"
+)
+
+// titleTimeout 生成会话标题的超时时间
+const titleTimeout = 30 * time.Second
+
+// conversation 在终端中进行的一次对话，负责流式输出、工具确认和消息持久化
+type conversation struct {
+	env     *Env
+	session *models.Session
+	state   *ai.Conversation // 发送给模型的会话设置和当前分支
+	persist bool             // 为 false 时只在内存中对话，不写入数据库
+	unsaved bool             // 会话尚未写入数据库，保存第一条消息时创建
+
+	autoApprove bool            // 自动允许所有工具调用
+	input       *bufio.Reader   // 用于确认工具调用，为空时无法交互确认
+	allowed     map[string]bool // 本次对话中无需再确认的工具
+
+	titled  bool           // 是否已开始生成标题
+	titleWG sync.WaitGroup // 等待后台生成的会话标题写入数据库
+}
+
+// newConversation 创建对话并载入会话的当前分支
+func newConversation(env *Env, session *models.Session, persist bool) (*conversation, error) {
+	history := make([]*models.Message, 0)
+	if persist {
+		messages, err := env.DB.GetActivePath(session.ID)
+		if err != nil {
+			return nil, err
+		}
+		history = messages
+	}
+
+	return &conversation{
+		env:     env,
+		session: session,
+		state:   ai.NewConversation(session, history),
+		persist: persist,
+		allowed: make(map[string]bool),
+	}, nil
+}
+
+// send 发送一条用户消息，把回复流式输出到标准输出
+func (c *conversation) send(ctx context.Context, content string, attachments []*models.Attachment) error {
+	userMsg := models.NewMessage(models.RoleUser, content)
+	userMsg.Attachments = attachments
+	if history := c.state.History(); len(history) > 0 {
+		userMsg.ParentID = history[len(history)-1].ID
+	}
+	if err := c.save(userMsg); err != nil {
+		return err
+	}
+
+	var streamed bool
+	reply, err := c.env.AI.StreamChat(ctx, c.state, userMsg, ai.StreamHandler{
+		OnChunk: func(chunk string) error {
+			streamed = true
+			_, err := fmt.Fprint(c.env.Stdout, chunk)
+			return err
+		},
+		OnMessage: func(msg *models.Message) {
+			// 工具调用过程输出到标准错误，不混入回复内容
+			if streamed {
+				fmt.Fprintln(c.env.Stdout)
+				streamed = false
+			}
+			c.printToolMessage(msg)
+			if err := c.save(msg); err != nil {
+				log.Printf("保存工具调用消息失败: %v", err)
+			}
+		},
+		Approve: c.approve,
+	})
+	if streamed || reply != nil {
+		fmt.Fprintln(c.env.Stdout)
+	}
+
+	var streamErr *ai.StreamError
+	switch {
+	case errors.Is(err, context.Canceled) && reply != nil:
+		fmt.Fprintln(c.env.Stderr, "⏹ 已中断")
+		return c.save(reply)
+	case errors.As(err, &streamErr):
+		if saveErr := c.save(streamErr.Partial); saveErr != nil {
+			log.Printf("保存失败消息失败: %v", saveErr)
+		}
+		return err
+	case err != nil:
+		return err
+	}
+
+	if err := c.save(reply); err != nil {
+		return err
+	}
+	c.printCitations(reply)
+
+	// 第一轮对话后在后台生成标题
+	if c.persist && !c.titled && c.session.Title == models.DefaultSessionTitle {
+		c.generateTitle()
+	}
+	return nil
+}
+
+// save 持久化消息，只在内存中对话时忽略；会话尚未保存时先创建会话
+func (c *conversation) save(msg *models.Message) error {
+	if !c.persist {
+		return nil
+	}
+	if c.unsaved {
+		if err := c.env.DB.SaveSession(c.session); err != nil {
+			return err
+		}
+		c.unsaved = false
+	}
+	return c.env.DB.SaveMessage(c.session.ID, msg)
+}
+
+// approve 确认工具调用：自动允许、交互确认，或在无法交互时拒绝
+func (c *conversation) approve(ctx context.Context, call models.ToolCall) bool {
+	if c.autoApprove || c.allowed[call.Name] {
+		return true
+	}
+	if c.input == nil {
+		fmt.Fprintf(c.env.Stderr, "已拒绝工具调用 %s（使用 -y 自动允许）\n", call.Name)
+		return false
+	}
+
+	fmt.Fprintf(c.env.Stderr, "模型请求调用工具 %s，参数: %s\n允许执行吗？[y]是 [n]否 [a]本次对话不再询问: ", call.Name, call.Arguments)
+	line, err := c.input.ReadString('\n')
+	if err != nil || ctx.Err() != nil {
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	case "a", "always":
+		c.allowed[call.Name] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// printToolMessage 输出工具调用过程
+func (c *conversation) printToolMessage(msg *models.Message) {
+	switch msg.Role {
+	case models.RoleAssistant:
+		for _, call := range msg.ToolCalls {
+			fmt.Fprintf(c.env.Stderr, "🔧 调用工具 %s %s\n", call.Name, call.Arguments)
+		}
+	case models.RoleTool:
+		result := msg.Content
+		if runes := []rune(result); len(runes) > 200 {
+			result = string(runes[:200]) + "…"
+		}
+		fmt.Fprintf(c.env.Stderr, "🔧 工具结果 %s: %s\n", msg.ToolName, result)
+	}
+}
+
+// printCitations 输出回复引用的知识库片段
+func (c *conversation) printCitations(reply *models.Message) {
+	if len(reply.Citations) == 0 {
+		return
+	}
+	fmt.Fprintln(c.env.Stderr, "📎 参考资料:")
+	for _, citation := range reply.Citations {
+		fmt.Fprintf(c.env.Stderr, "  [%d] %s（相似度 %.2f）\n", citation.Index, citation.Source, citation.Score)
+	}
+}
+
+// generateTitle 在后台根据最近的对话生成会话标题
+func (c *conversation) generateTitle() {
+	if c.env.Assistant == nil {
+		return
+	}
+
+	messages := c.state.History()
+	if len(messages) > 8 {
+		messages = messages[len(messages)-8:]
+	}
+
+	c.titled = true
+	c.titleWG.Add(1)
+	go func() {
+		defer c.titleWG.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+		defer cancel()
+
+		title, err := c.env.Assistant.GenerateTitle(ctx, messages)
+		if err != nil {
+			log.Printf("生成会话标题失败: %v", err)
+			return
+		}
+		if err := c.env.DB.UpdateSessionTitle(c.session.ID, title); err != nil {
+			log.Printf("更新会话标题失败: %v", err)
+		}
+	}()
+}
+
+// wait 等待后台任务完成
+func (c *conversation) wait() {
+	c.titleWG.Wait()
+}
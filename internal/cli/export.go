@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/wangle201210/gochat/internal/exporter"
+)
+
+// Export 执行 gochat export，将会话导出为 Markdown、JSON 或 HTML
+// 导出单个会话且未指定 -o 时输出到标准输出；导出多个会话时 -o 为目录，每个会话一个文件
+func Export(env *Env, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(env.Stderr)
+	formatName := flags.String("f", "md", "导出格式：md、json 或 html")
+	output := flags.String("o", "", "输出文件或目录，导出多个会话时默认为当前目录")
+	all := flags.Bool("all", false, "导出所有会话")
+	flags.Usage = func() {
+		fmt.Fprintln(env.Stderr, "用法: gochat export [选项] <会话ID>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// 未指定 -f 时按输出文件的扩展名判断格式
+	formatSet := false
+	flags.Visit(func(f *flag.Flag) { formatSet = formatSet || f.Name == "f" })
+	if !formatSet && *output != "" {
+		if format, err := exporter.ParseFormat(filepath.Ext(*output)); err == nil {
+			*formatName = string(format)
+		}
+	}
+	format, err := exporter.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	ids := flags.Args()
+	if *all {
+		if len(ids) > 0 {
+			return errors.New("-all 不能与会话ID同时使用")
+		}
+		sessions, err := env.DB.ListSessions()
+		if err != nil {
+			return err
+		}
+		for _, session := range sessions {
+			ids = append(ids, session.ID)
+		}
+		if len(ids) == 0 {
+			fmt.Fprintln(env.Stderr, "没有可导出的会话")
+			return nil
+		}
+	}
+	if len(ids) == 0 {
+		flags.Usage()
+		return errors.New("缺少会话ID")
+	}
+
+	if len(ids) == 1 && !*all && !isDir(*output) {
+		return exportOne(env, ids[0], format, *output)
+	}
+
+	dir := *output
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	for _, id := range ids {
+		conv, err := exporter.Load(env.DB, id)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, exporter.FileName(conv.Session, format))
+		if err := writeExport(path, conv, format); err != nil {
+			return err
+		}
+		fmt.Fprintf(env.Stderr, "已导出: %s\n", path)
+	}
+	fmt.Fprintf(env.Stderr, "共导出 %d 个会话到 %s\n", len(ids), dir)
+	return nil
+}
+
+// exportOne 导出单个会话，path 为空时输出到标准输出
+func exportOne(env *Env, id string, format exporter.Format, path string) error {
+	conv, err := exporter.Load(env.DB, id)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return exporter.Write(env.Stdout, conv, format)
+	}
+	if err := writeExport(path, conv, format); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Stderr, "已导出: %s\n", path)
+	return nil
+}
+
+// writeExport 将会话写入文件
+func writeExport(path string, conv *exporter.Conversation, format exporter.Format) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建导出文件失败: %w", err)
+	}
+	if err := exporter.Write(file, conv, format); err != nil {
+		file.Close()
+		return fmt.Errorf("写入导出文件失败: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("写入导出文件失败: %w", err)
+	}
+	return nil
+}
+
+// isDir 判断路径是否为已存在的目录
+func isDir(path string) bool {
+	if path == "" {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/wangle201210/gochat/internal/importer"
+)
+
+// Import 执行 gochat import，从 ChatGPT 等客户端的导出文件导入会话，重复导入时只追加新消息
+func Import(ctx context.Context, env *Env, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(env.Stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(env.Stderr, "用法: gochat import <文件>...")
+		fmt.Fprintln(env.Stderr, "支持 ChatGPT 导出的 conversations.json 或 .zip、gochat export 导出的 JSON、通用 JSON 消息列表和 Markdown 对话记录")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("缺少导入文件")
+	}
+
+	for _, path := range flags.Args() {
+		sessions, err := importer.ParseFile(path)
+		if err != nil {
+			return err
+		}
+
+		result, err := importer.Import(ctx, env.DB, sessions, nil)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(env.Stdout, "%s: 新建 %d 个会话，更新 %d 个，跳过已导入的 %d 个，共写入 %d 条消息\n",
+			path, result.Created, result.Updated, result.Skipped, result.Messages)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+	"time"
+)
+
+// Sessions 执行 gochat sessions list/show/delete
+func Sessions(env *Env, args []string) error {
+	if len(args) == 0 {
+		Usage(env.Stderr)
+		return errors.New("缺少子命令")
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return listSessions(env, args[1:])
+	case "show":
+		if len(args) != 2 {
+			return errors.New("用法: gochat sessions show <会话ID>")
+		}
+		return showSession(env, args[1])
+	case "delete", "rm":
+		if len(args) != 2 {
+			return errors.New("用法: gochat sessions delete <会话ID>")
+		}
+		return deleteSession(env, args[1])
+	default:
+		Usage(env.Stderr)
+		return fmt.Errorf("未知的子命令: %s", args[0])
+	}
+}
+
+// listSessions 按更新时间倒序列出会话
+func listSessions(env *Env, args []string) error {
+	flags := flag.NewFlagSet("sessions list", flag.ContinueOnError)
+	flags.SetOutput(env.Stderr)
+	limit := flags.Int("n", 20, "最多显示的会话数，0 表示全部")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	sessions, err := env.DB.ListSessions()
+	if err != nil {
+		return err
+	}
+	if *limit > 0 && len(sessions) > *limit {
+		sessions = sessions[:*limit]
+	}
+
+	w := tabwriter.NewWriter(env.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\t更新时间\t标题")
+	for _, session := range sessions {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", session.ID, session.UpdatedAt.Format(time.DateTime), session.Title)
+	}
+	return w.Flush()
+}
+
+// showSession 输出会话当前分支的消息
+func showSession(env *Env, id string) error {
+	session, err := findSession(env.DB, id)
+	if err != nil {
+		return err
+	}
+
+	messages, err := env.DB.GetActivePath(session.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(env.Stdout, "# %s\n\n", session.Title)
+	for _, msg := range messages {
+		printMessage(env.Stdout, msg)
+	}
+	return nil
+}
+
+// deleteSession 删除会话及其消息
+func deleteSession(env *Env, id string) error {
+	session, err := findSession(env.DB, id)
+	if err != nil {
+		return err
+	}
+
+	if err := env.DB.DeleteSession(session.ID); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Stdout, "已删除会话: %s (%s)\n", session.Title, session.ID)
+	return nil
+}
@@ -12,22 +12,111 @@ type Config struct {
 	AI        AIConfig        `json:"ai"`
 	Assistant AssistantConfig `json:"assistant"`
 	UI        UIConfig        `json:"ui"`
+	Pricing   PricingConfig   `json:"pricing"`
+
+	MCPServers []MCPServerConfig `json:"mcp_servers,omitempty"`
+	Knowledge  KnowledgeConfig   `json:"knowledge"`
+	Server     ServerConfig      `json:"server"`
+	Queue      QueueConfig       `json:"queue"`
 }
 
-// AIConfig AI 相关配置
-type AIConfig struct {
-	Provider string `json:"provider"` // 例如: "ark", "openai", "anthropic"
+// QueueConfig 图形界面中多个会话同时生成回复时的排队配置
+type QueueConfig struct {
+	MaxConcurrent int            `json:"max_concurrent,omitempty"` // 同时进行的生成数，默认 3
+	RateLimits    map[string]int `json:"rate_limits,omitempty"`    // 各 provider 每分钟最多开始的生成数，键为 provider 名称，未配置表示不限
+}
+
+// ServerConfig gochat serve 的配置
+type ServerConfig struct {
+	Addr         string   `json:"addr,omitempty"`          // 监听地址，默认 127.0.0.1:8080
+	SaveSessions bool     `json:"save_sessions,omitempty"` // 是否把经过接口的对话记录为会话
+	APITokens    []string `json:"api_tokens,omitempty"`    // 访问令牌，配置后启用 REST 接口，所有接口都需携带其中之一
+}
+
+// KnowledgeConfig 本地知识库配置
+type KnowledgeConfig struct {
+	Embedding    EmbeddingConfig `json:"embedding"`
+	ChunkSize    int             `json:"chunk_size,omitempty"`    // 每个片段的最大字符数，默认 800
+	ChunkOverlap int             `json:"chunk_overlap,omitempty"` // 相邻片段重叠的字符数，默认 100
+	TopK         int             `json:"top_k,omitempty"`         // 每次检索的片段数，默认 4
+	MinScore     float64         `json:"min_score,omitempty"`     // 最低相似度，低于该值的片段不使用，默认 0.3
+}
+
+// EmbeddingConfig 向量模型配置
+type EmbeddingConfig struct {
+	Provider string `json:"provider"` // "ollama"（默认）、"openai" 或 "local"
+	Model    string `json:"model"`    // 向量模型名称，ollama 默认 nomic-embed-text
+	APIKey   string `json:"api_key"`  // API Key，ollama 和 local 不需要
+	BaseURL  string `json:"base_url"` // API Base URL，留空时使用 provider 默认地址
+}
+
+// MCPServerConfig 通过 stdio 启动的 MCP 服务配置
+type MCPServerConfig struct {
+	Name     string            `json:"name"`               // 服务名称，用作工具名前缀和会话开关
+	Command  string            `json:"command"`            // 启动命令，例如 "npx"
+	Args     []string          `json:"args,omitempty"`     // 命令参数
+	Env      map[string]string `json:"env,omitempty"`      // 额外的环境变量
+	Disabled bool              `json:"disabled,omitempty"` // 为 true 时不启动
+}
+
+// ModelConfig 模型连接配置（AI 与助手模型共用）
+type ModelConfig struct {
+	Provider string `json:"provider"` // 例如: "openai", "ollama", "anthropic", "ark", "gemini"
 	Model    string `json:"model"`    // 模型名称
 	APIKey   string `json:"api_key"`  // API Key
-	BaseURL  string `json:"base_url"` // API Base URL
+	BaseURL  string `json:"base_url"` // API Base URL，留空时使用 provider 默认地址
+
+	// 各 provider 的专属配置，只需填写所用 provider 对应的块
+	OpenAI    *OpenAIOptions    `json:"openai,omitempty"`
+	Ollama    *OllamaOptions    `json:"ollama,omitempty"`
+	Anthropic *AnthropicOptions `json:"anthropic,omitempty"`
+	Ark       *ArkOptions       `json:"ark,omitempty"`
+	Gemini    *GeminiOptions    `json:"gemini,omitempty"`
+}
+
+// OpenAIOptions OpenAI 专属配置
+type OpenAIOptions struct {
+	ByAzure    bool   `json:"by_azure"`    // 是否使用 Azure OpenAI
+	APIVersion string `json:"api_version"` // Azure API 版本
+	Timeout    int    `json:"timeout"`     // 请求超时（秒）
+}
+
+// OllamaOptions Ollama 专属配置
+type OllamaOptions struct {
+	Host    string `json:"host"`    // Ollama 服务地址，默认 http://localhost:11434
+	Timeout int    `json:"timeout"` // 请求超时（秒）
+}
+
+// AnthropicOptions Anthropic 专属配置
+type AnthropicOptions struct {
+	MaxTokens      int `json:"max_tokens"`      // 单次回复的最大 token 数，默认 4096
+	ThinkingBudget int `json:"thinking_budget"` // 扩展思考的 token 预算，0 表示关闭
+	Timeout        int `json:"timeout"`         // 请求超时（秒）
+}
+
+// ArkOptions 火山方舟专属配置
+type ArkOptions struct {
+	Region  string `json:"region"`  // 服务地域，默认 cn-beijing
+	Timeout int    `json:"timeout"` // 请求超时（秒）
+}
+
+// GeminiOptions Gemini 专属配置
+type GeminiOptions struct {
+	ReasoningEffort string `json:"reasoning_effort"` // 思考强度: "low", "medium", "high"
+	Timeout         int    `json:"timeout"`          // 请求超时（秒）
+}
+
+// AIConfig AI 相关配置
+type AIConfig struct {
+	ModelConfig
+	ContextWindow int `json:"context_window,omitempty"` // 模型上下文窗口大小（token），默认 32768
+	ReplyReserve  int `json:"reply_reserve,omitempty"`  // 为回复预留的 token 数，默认 4096
+	MaxToolSteps  int `json:"max_tool_steps,omitempty"` // 单次回复中工具调用的最大轮数，默认 8
 }
 
 // AssistantConfig 助手模型配置（用于生成会话标题等辅助任务）
 type AssistantConfig struct {
-	Provider string `json:"provider"` // 例如: "ark", "openai", "anthropic"
-	Model    string `json:"model"`    // 模型名称
-	APIKey   string `json:"api_key"`  // API Key
-	BaseURL  string `json:"base_url"` // API Base URL
+	ModelConfig
 }
 
 // UIConfig UI 相关配置
@@ -36,21 +125,50 @@ type UIConfig struct {
 	WindowHeight int `json:"window_height"`
 }
 
+// PricingConfig 模型价格表，用于估算费用
+type PricingConfig struct {
+	Currency string                `json:"currency"` // 货币符号，默认 "$"
+	Models   map[string]ModelPrice `json:"models"`   // 键为模型名称
+}
+
+// ModelPrice 模型价格，单位为每百万 token
+type ModelPrice struct {
+	Input  float64 `json:"input"`  // 输入（提示词）价格
+	Output float64 `json:"output"` // 输出（回复）价格
+}
+
+// Cost 估算一次调用的费用，价格表中没有该模型时返回 false
+func (p *PricingConfig) Cost(model string, promptTokens, completionTokens int) (float64, bool) {
+	price, ok := p.Models[model]
+	if !ok {
+		return 0, false
+	}
+	return (float64(promptTokens)*price.Input + float64(completionTokens)*price.Output) / 1e6, true
+}
+
+// CurrencySymbol 返回货币符号
+func (p *PricingConfig) CurrencySymbol() string {
+	if p.Currency == "" {
+		return "$"
+	}
+	return p.Currency
+}
+
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
-		AI: AIConfig{
+		AI: AIConfig{ModelConfig: ModelConfig{
 			Provider: "openai",
 			Model:    "gpt-3.5-turbo",
 			BaseURL:  "https://api.openai.com/v1",
 			APIKey:   "APIKey",
-		},
-		Assistant: AssistantConfig{
+		}},
+		Assistant: AssistantConfig{ModelConfig{
 			Provider: "openai",
 			Model:    "gpt-3.5-turbo",
 			BaseURL:  "https://api.openai.com/v1",
 			APIKey:   "APIKey",
-		},
+		}},
 		UI: UIConfig{
 			WindowWidth:  800,
 			WindowHeight: 600,
@@ -0,0 +1,165 @@
+// Package exporter 将会话导出为 Markdown、JSON 或独立的 HTML 文件
+// Markdown 和 HTML 只包含当前分支，便于阅读和分享；JSON 包含所有分支和附件内容，可完整还原会话
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/storage"
+)
+
+// Format 导出格式
+type Format string
+
+const (
+	Markdown Format = "md"
+	JSON     Format = "json"
+	HTML     Format = "html"
+)
+
+// Formats 支持的导出格式
+var Formats = []Format{Markdown, JSON, HTML}
+
+// maxFileNameRunes 导出文件名中标题部分的最大长度
+const maxFileNameRunes = 50
+
+// ParseFormat 解析导出格式名称，大小写不敏感
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "md", "markdown":
+		return Markdown, nil
+	case "json":
+		return JSON, nil
+	case "html", "htm":
+		return HTML, nil
+	default:
+		return "", fmt.Errorf("不支持的导出格式: %s（可选 md、json、html）", name)
+	}
+}
+
+// Label 返回格式的显示名称
+func (f Format) Label() string {
+	switch f {
+	case Markdown:
+		return "Markdown"
+	case JSON:
+		return "JSON"
+	case HTML:
+		return "HTML"
+	default:
+		return string(f)
+	}
+}
+
+// Conversation 待导出的会话及其消息
+type Conversation struct {
+	Session    *models.Session
+	Messages   []*models.Message // 所有分支的消息，按时间排序
+	ActivePath []*models.Message // 当前分支从根到末端的消息
+}
+
+// Load 从数据库读取会话的所有消息和当前分支
+func Load(db *storage.Database, sessionID string) (*Conversation, error) {
+	session, err := db.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, fmt.Errorf("会话不存在: %s", sessionID)
+	}
+
+	messages, err := db.GetMessages(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	path, err := db.GetActivePath(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &Conversation{Session: session, Messages: messages, ActivePath: path}, nil
+}
+
+// Write 按指定格式写出会话
+func Write(w io.Writer, conv *Conversation, format Format) error {
+	switch format {
+	case Markdown:
+		return WriteMarkdown(w, conv)
+	case JSON:
+		return WriteJSON(w, conv)
+	case HTML:
+		return WriteHTML(w, conv)
+	default:
+		return fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// FileName 返回会话导出文件的默认文件名：标题-会话ID.扩展名
+func FileName(session *models.Session, format Format) string {
+	title := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(session.Title))
+	if runes := []rune(title); len(runes) > maxFileNameRunes {
+		title = string(runes[:maxFileNameRunes])
+	}
+	title = strings.Trim(title, ". ")
+	if title == "" {
+		return fmt.Sprintf("%s.%s", session.ID, format)
+	}
+	return fmt.Sprintf("%s-%s.%s", title, session.ID, format)
+}
+
+// roleLabel 返回消息角色的显示名称
+func roleLabel(msg *models.Message) string {
+	switch msg.Role {
+	case models.RoleUser:
+		return "我"
+	case models.RoleAssistant:
+		if msg.Usage != nil && msg.Usage.Model != "" {
+			return "助手 (" + msg.Usage.Model + ")"
+		}
+		return "助手"
+	case models.RoleTool:
+		return "工具结果: " + msg.ToolName
+	case models.RoleSystem:
+		return "系统"
+	default:
+		return string(msg.Role)
+	}
+}
+
+// statusNote 返回未正常完成的消息的提示
+func statusNote(msg *models.Message) string {
+	switch msg.Status {
+	case models.StatusInterrupted:
+		return "⏹ 已中断"
+	case models.StatusFailed:
+		return "⚠ 生成失败"
+	default:
+		return ""
+	}
+}
+
+// formatTime 格式化导出文件中的时间
+func formatTime(t time.Time) string {
+	return t.Local().Format(time.DateTime)
+}
+
+// formatSize 格式化附件大小
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
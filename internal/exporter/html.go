@@ -0,0 +1,145 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"io"
+
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// markdown 渲染消息内容的 Markdown 解析器，内容中的原始 HTML 不会输出
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// htmlTemplate 独立 HTML 文件的模板，样式内联，图片和附件以 data URL 嵌入
+var htmlTemplate = template.Must(template.New("session").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 860px; margin: 0 auto; padding: 24px; font-family: -apple-system, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; line-height: 1.6; color: #1f2328; background: #f6f8fa; }
+header { margin-bottom: 24px; }
+header h1 { margin-bottom: 4px; }
+.meta { color: #656d76; font-size: 14px; }
+.message { margin: 16px 0; padding: 12px 16px; border-radius: 8px; background: #fff; border: 1px solid #d0d7de; }
+.message.user { background: #eef4ff; }
+.message.tool { background: #f6f8fa; }
+.role { font-weight: 600; }
+.time { color: #656d76; font-size: 13px; margin-left: 8px; }
+.status { color: #9a6700; font-style: italic; }
+.attachments img { max-width: 100%; border-radius: 6px; margin: 8px 0; display: block; }
+.attachments a { display: inline-block; margin: 4px 8px 4px 0; }
+pre { background: #f6f8fa; padding: 12px; border-radius: 6px; overflow-x: auto; }
+code { font-family: ui-monospace, "SFMono-Regular", Consolas, monospace; font-size: 90%; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d0d7de; padding: 4px 10px; }
+blockquote { margin: 0; padding-left: 12px; border-left: 4px solid #d0d7de; color: #656d76; }
+details summary { cursor: pointer; color: #656d76; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<div class="meta">创建时间: {{.CreatedAt}} · 更新时间: {{.UpdatedAt}}{{with .Model}} · 模型: {{.}}{{end}}</div>
+{{with .SystemPrompt}}<details><summary>系统提示词</summary><pre>{{.}}</pre></details>{{end}}
+</header>
+{{range .Messages}}<section class="message {{.Role}}">
+<div><span class="role">{{.Label}}</span><span class="time">{{.Time}}</span></div>
+{{- if .Attachments}}
+<div class="attachments">{{range .Attachments}}{{if .Image}}<img src="{{.URL}}" alt="{{.Name}}">{{else}}<a href="{{.URL}}" download="{{.Name}}">📎 {{.Name}} ({{.Size}})</a>{{end}}{{end}}</div>
+{{- end}}
+{{if .Plain}}<details><summary>内容</summary><pre>{{.Plain}}</pre></details>{{else}}{{.Content}}{{end}}
+{{- range .ToolCalls}}
+<div>🔧 调用工具 <code>{{.Name}}</code></div><pre>{{.Arguments}}</pre>
+{{- end}}
+{{- if .Citations}}
+<div class="meta">参考资料</div><ol>{{range .Citations}}<li value="{{.Index}}">{{.Source}}</li>{{end}}</ol>
+{{- end}}
+{{- with .Status}}
+<div class="status">{{.}}</div>
+{{- end}}
+</section>
+{{end}}</body>
+</html>
+`))
+
+// htmlPage 模板数据
+type htmlPage struct {
+	Title        string
+	CreatedAt    string
+	UpdatedAt    string
+	Model        string
+	SystemPrompt string
+	Messages     []*htmlMessage
+}
+
+// htmlMessage 模板中的一条消息
+type htmlMessage struct {
+	Role        models.Role
+	Label       string
+	Time        string
+	Content     template.HTML // 渲染后的 Markdown
+	Plain       string        // 工具结果按原文显示
+	Attachments []*htmlAttachment
+	ToolCalls   []models.ToolCall
+	Citations   []models.Citation
+	Status      string
+}
+
+// htmlAttachment 模板中的附件
+type htmlAttachment struct {
+	Name  string
+	Size  string
+	Image bool
+	URL   template.URL
+}
+
+// WriteHTML 将会话当前分支导出为独立的 HTML 文件
+func WriteHTML(w io.Writer, conv *Conversation) error {
+	session := conv.Session
+	page := &htmlPage{
+		Title:        session.Title,
+		CreatedAt:    formatTime(session.CreatedAt),
+		UpdatedAt:    formatTime(session.UpdatedAt),
+		Model:        session.Model,
+		SystemPrompt: session.SystemPrompt,
+	}
+
+	for _, msg := range conv.ActivePath {
+		hm := &htmlMessage{
+			Role:      msg.Role,
+			Label:     roleLabel(msg),
+			Time:      formatTime(msg.Timestamp),
+			ToolCalls: msg.ToolCalls,
+			Citations: msg.Citations,
+			Status:    statusNote(msg),
+		}
+
+		if msg.Role == models.RoleTool {
+			hm.Plain = msg.Content
+		} else {
+			var buf bytes.Buffer
+			if err := markdown.Convert([]byte(msg.Content), &buf); err != nil {
+				return err
+			}
+			hm.Content = template.HTML(buf.String())
+		}
+
+		for _, a := range msg.Attachments {
+			hm.Attachments = append(hm.Attachments, &htmlAttachment{
+				Name:  a.Name,
+				Size:  formatSize(a.Size),
+				Image: a.IsImage(),
+				URL:   template.URL("data:" + a.MimeType + ";base64," + base64.StdEncoding.EncodeToString(a.Data)),
+			})
+		}
+		page.Messages = append(page.Messages, hm)
+	}
+
+	return htmlTemplate.Execute(w, page)
+}
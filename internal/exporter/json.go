@@ -0,0 +1,109 @@
+package exporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+const (
+	// jsonFormatName JSON 导出文件的格式标识
+	jsonFormatName = "gochat.session"
+
+	// jsonFormatVersion JSON 导出文件的格式版本
+	jsonFormatVersion = 1
+)
+
+// sessionFile JSON 导出文件的结构
+type sessionFile struct {
+	Format       string          `json:"format"`
+	Version      int             `json:"version"`
+	ExportedAt   time.Time       `json:"exported_at"`
+	Session      *models.Session `json:"session"`
+	ActiveLeafID string          `json:"active_leaf_id,omitempty"` // 当前分支的末端消息
+	Messages     []*fileMessage  `json:"messages"`                 // 所有分支的消息，按时间排序
+}
+
+// fileMessage 导出的消息，附件包含文件内容
+type fileMessage struct {
+	*models.Message
+	Attachments []*fileAttachment `json:"attachments,omitempty"`
+}
+
+// fileAttachment 导出的附件，Data 以 base64 编码
+type fileAttachment struct {
+	*models.Attachment
+	Data []byte `json:"data"`
+}
+
+// WriteJSON 将会话的所有分支和附件导出为 JSON
+func WriteJSON(w io.Writer, conv *Conversation) error {
+	file := &sessionFile{
+		Format:     jsonFormatName,
+		Version:    jsonFormatVersion,
+		ExportedAt: time.Now(),
+		Session:    conv.Session,
+		Messages:   make([]*fileMessage, 0, len(conv.Messages)),
+	}
+	if n := len(conv.ActivePath); n > 0 {
+		file.ActiveLeafID = conv.ActivePath[n-1].ID
+	}
+
+	for _, msg := range conv.Messages {
+		fm := &fileMessage{Message: msg}
+		for _, a := range msg.Attachments {
+			fm.Attachments = append(fm.Attachments, &fileAttachment{Attachment: a, Data: a.Data})
+		}
+		file.Messages = append(file.Messages, fm)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(file)
+}
+
+// ReadJSON 读取 WriteJSON 导出的文件，按 active_leaf_id 还原当前分支
+func ReadJSON(r io.Reader) (*Conversation, error) {
+	var file sessionFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("解析导出文件失败: %w", err)
+	}
+	if file.Format != jsonFormatName || file.Session == nil {
+		return nil, errors.New("不是 GoChat 导出的会话文件")
+	}
+	if file.Version > jsonFormatVersion {
+		return nil, fmt.Errorf("导出文件版本 %d 高于程序支持的版本 %d，请升级 GoChat", file.Version, jsonFormatVersion)
+	}
+
+	conv := &Conversation{Session: file.Session}
+	byID := make(map[string]*models.Message, len(file.Messages))
+	for _, fm := range file.Messages {
+		if fm.Message == nil {
+			continue
+		}
+		msg := fm.Message
+		msg.Attachments = nil
+		for _, fa := range fm.Attachments {
+			if fa.Attachment == nil {
+				continue
+			}
+			fa.Attachment.Data = fa.Data
+			msg.Attachments = append(msg.Attachments, fa.Attachment)
+		}
+		conv.Messages = append(conv.Messages, msg)
+		byID[msg.ID] = msg
+	}
+
+	// 从末端沿父消息回溯到根
+	for msg := byID[file.ActiveLeafID]; msg != nil; msg = byID[msg.ParentID] {
+		conv.ActivePath = append([]*models.Message{msg}, conv.ActivePath...)
+		if len(conv.ActivePath) > len(conv.Messages) {
+			return nil, errors.New("导出文件中的消息存在循环引用")
+		}
+	}
+	return conv, nil
+}
@@ -0,0 +1,91 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// WriteMarkdown 将会话当前分支导出为 Markdown，附件只列出文件名和大小
+func WriteMarkdown(w io.Writer, conv *Conversation) error {
+	session := conv.Session
+
+	header := []string{
+		"# " + session.Title,
+		fmt.Sprintf("- 创建时间: %s\n- 更新时间: %s", formatTime(session.CreatedAt), formatTime(session.UpdatedAt)),
+	}
+	if session.Model != "" {
+		header[1] += "\n- 模型: " + session.Model
+	}
+	if session.SystemPrompt != "" {
+		header = append(header, "**系统提示词**", quote(session.SystemPrompt))
+	}
+
+	sections := []string{strings.Join(header, "\n\n")}
+	for _, msg := range conv.ActivePath {
+		sections = append(sections, strings.Join(markdownBlocks(msg), "\n\n"))
+	}
+
+	_, err := io.WriteString(w, strings.Join(sections, "\n\n---\n\n")+"\n")
+	return err
+}
+
+// markdownBlocks 返回一条消息的各个段落
+func markdownBlocks(msg *models.Message) []string {
+	blocks := []string{fmt.Sprintf("### %s · %s", roleLabel(msg), formatTime(msg.Timestamp))}
+
+	for _, a := range msg.Attachments {
+		blocks = append(blocks, fmt.Sprintf("📎 %s (%s)", a.Name, formatSize(a.Size)))
+	}
+
+	if content := strings.Trim(msg.Content, "\n"); strings.TrimSpace(content) != "" {
+		if msg.Role == models.RoleTool {
+			// 工具结果通常是 JSON 或纯文本，放进代码块避免被当作 Markdown 解析
+			content = codeBlock(content)
+		}
+		blocks = append(blocks, content)
+	}
+
+	for _, call := range msg.ToolCalls {
+		blocks = append(blocks, fmt.Sprintf("🔧 调用工具 `%s`", call.Name), codeBlock(call.Arguments))
+	}
+
+	if len(msg.Citations) > 0 {
+		sources := make([]string, 0, len(msg.Citations))
+		for _, c := range msg.Citations {
+			sources = append(sources, fmt.Sprintf("%d. %s", c.Index, c.Source))
+		}
+		blocks = append(blocks, "**参考资料**", strings.Join(sources, "\n"))
+	}
+
+	if note := statusNote(msg); note != "" {
+		blocks = append(blocks, "*"+note+"*")
+	}
+	return blocks
+}
+
+// quote 将文本转换为 Markdown 引用块
+func quote(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// codeBlock 将文本放进代码块，围栏比内容中最长的连续反引号更长
+func codeBlock(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + "\n" + strings.TrimRight(text, "\n") + "\n" + fence
+}
@@ -0,0 +1,188 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// chatGPTConversation ChatGPT 数据导出中 conversations.json 的一个会话
+type chatGPTConversation struct {
+	ID             string                  `json:"id"`
+	ConversationID string                  `json:"conversation_id"`
+	Title          string                  `json:"title"`
+	CreateTime     float64                 `json:"create_time"`
+	UpdateTime     float64                 `json:"update_time"`
+	Mapping        map[string]*chatGPTNode `json:"mapping"`
+	CurrentNode    string                  `json:"current_node"`
+}
+
+// chatGPTNode 消息树的节点，根节点和部分节点没有消息
+type chatGPTNode struct {
+	ID       string          `json:"id"`
+	Message  *chatGPTMessage `json:"message"`
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+}
+
+// chatGPTMessage 节点上的消息
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime *float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`
+		Language    string            `json:"language"`
+	} `json:"content"`
+	Recipient string `json:"recipient"`
+	Metadata  struct {
+		Hidden bool `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// parseChatGPT 解析 ChatGPT 的 conversations.json：保留消息树中的所有分支，
+// 系统消息、隐藏消息和调用内部工具（浏览、代码执行）的中间消息不导入
+func parseChatGPT(data []byte) ([]*models.ImportedSession, error) {
+	var conversations []*chatGPTConversation
+	if err := json.Unmarshal(data, &conversations); err != nil {
+		return nil, fmt.Errorf("解析 ChatGPT 导出文件失败: %w", err)
+	}
+
+	var sessions []*models.ImportedSession
+	for _, conv := range conversations {
+		if conv == nil || len(conv.Mapping) == 0 {
+			continue
+		}
+		sessions = append(sessions, convertChatGPT(conv))
+	}
+	return sessions, nil
+}
+
+// convertChatGPT 将一个 ChatGPT 会话转换为待导入的会话
+func convertChatGPT(conv *chatGPTConversation) *models.ImportedSession {
+	id := conv.ConversationID
+	if id == "" {
+		id = conv.ID
+	}
+	sourceKey := "chatgpt:" + id
+
+	created := unixTime(conv.CreateTime)
+	updated := unixTime(conv.UpdateTime)
+	if updated.IsZero() {
+		updated = created
+	}
+
+	session := models.NewSession()
+	if title := strings.TrimSpace(conv.Title); title != "" {
+		session.Title = title
+	}
+	if !created.IsZero() {
+		session.CreatedAt, session.UpdatedAt = created, updated
+	}
+	imp := &models.ImportedSession{SourceKey: sourceKey, Session: session}
+
+	// 跳过的节点不导入，其子节点接到最近的已导入祖先上
+	kept := make(map[string]bool)
+	for nodeID, node := range conv.Mapping {
+		msg := chatGPTToMessage(node.Message)
+		if msg == nil {
+			continue
+		}
+		if msg.Timestamp.IsZero() {
+			msg.Timestamp = session.CreatedAt
+		}
+		kept[nodeID] = true
+		imp.Messages = append(imp.Messages, &models.ImportedMessage{
+			SourceKey: sourceKey + "/" + nodeID,
+			ParentKey: node.Parent,
+			Message:   msg,
+		})
+	}
+
+	keyOf := func(nodeID string) string {
+		for steps := 0; nodeID != "" && steps <= len(conv.Mapping); steps++ {
+			if kept[nodeID] {
+				return sourceKey + "/" + nodeID
+			}
+			node := conv.Mapping[nodeID]
+			if node == nil {
+				break
+			}
+			nodeID = node.Parent
+		}
+		return ""
+	}
+	for _, im := range imp.Messages {
+		im.ParentKey = keyOf(im.ParentKey)
+	}
+	imp.ActiveKey = keyOf(conv.CurrentNode)
+
+	// mapping 是无序的，按时间排列后再按消息树排序，使同级分支按创建时间排列
+	sortByTimestamp(imp.Messages)
+	return imp
+}
+
+// chatGPTToMessage 转换节点上的消息，不需要导入时返回 nil
+func chatGPTToMessage(m *chatGPTMessage) *models.Message {
+	if m == nil || m.Metadata.Hidden {
+		return nil
+	}
+
+	var role models.Role
+	switch m.Author.Role {
+	case "user":
+		role = models.RoleUser
+	case "assistant":
+		if m.Recipient != "" && m.Recipient != "all" {
+			return nil
+		}
+		role = models.RoleAssistant
+	default:
+		return nil
+	}
+
+	var content string
+	switch m.Content.ContentType {
+	case "text", "multimodal_text":
+		var texts []string
+		for _, part := range m.Content.Parts {
+			var text string
+			if json.Unmarshal(part, &text) == nil {
+				texts = append(texts, text)
+			} else {
+				texts = append(texts, "[图片]")
+			}
+		}
+		content = strings.Join(texts, "\n")
+	case "code":
+		content = "```" + m.Content.Language + "\n" + m.Content.Text + "\n```"
+	default:
+		return nil
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil
+	}
+
+	msg := models.NewMessage(role, content)
+	msg.Timestamp = time.Time{}
+	if m.CreateTime != nil {
+		msg.Timestamp = unixTime(*m.CreateTime)
+	}
+	return msg
+}
+
+// unixTime 将带小数的 Unix 秒数转换为时间，0 表示未知
+func unixTime(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
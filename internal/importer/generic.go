@@ -0,0 +1,287 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// 通用 JSON 格式中各字段可能使用的名称，兼容 OpenAI 消息格式、Claude.ai 导出等常见客户端
+var (
+	idFields       = []string{"id", "uuid", "conversation_id"}
+	titleFields    = []string{"title", "name", "subject"}
+	messagesFields = []string{"messages", "chat_messages"}
+	roleFields     = []string{"role", "sender", "author", "from"}
+	contentFields  = []string{"content", "text", "message", "parts"}
+	createdFields  = []string{"created_at", "create_time", "createdAt", "timestamp", "time", "date"}
+	updatedFields  = []string{"updated_at", "update_time", "updatedAt"}
+)
+
+// timeLayouts 字符串时间的常见格式，没有时区的按本地时间解析
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999", time.DateTime, time.DateOnly}
+
+// textPartTypes 作为文本读取的内容块类型
+var textPartTypes = map[string]bool{"": true, "text": true, "input_text": true, "output_text": true}
+
+// systemRoles 作为会话系统提示词导入的角色
+var systemRoles = map[string]bool{"system": true, "developer": true}
+
+// errNoMessages 无法识别的 JSON 导出文件
+var errNoMessages = errors.New("无法识别的导入文件：JSON 中没有 messages 消息列表")
+
+// jsonObject 字段未知的 JSON 对象
+type jsonObject map[string]json.RawMessage
+
+// parseGeneric 解析通用 JSON 导出：一个或一组包含 messages 的会话对象，或者直接是一个消息数组
+func parseGeneric(data []byte) ([]*models.ImportedSession, error) {
+	var items []jsonObject
+	if err := json.Unmarshal(data, &items); err != nil {
+		var single jsonObject
+		if err := json.Unmarshal(data, &single); err != nil {
+			return nil, errNoMessages
+		}
+		items = []jsonObject{single}
+	}
+
+	// 数组元素本身是消息时，整个数组是一个会话
+	if len(items) > 0 && items[0].raw(messagesFields...) == nil && items[0].raw(roleFields...) != nil {
+		items = []jsonObject{{"messages": data}}
+	}
+
+	var sessions []*models.ImportedSession
+	for _, item := range items {
+		if item.raw(messagesFields...) == nil {
+			continue
+		}
+		imp, err := convertGeneric(item)
+		if err != nil {
+			return nil, err
+		}
+		if len(imp.Messages) > 0 {
+			sessions = append(sessions, imp)
+		}
+	}
+	if len(sessions) == 0 && len(items) > 0 {
+		return nil, errNoMessages
+	}
+	return sessions, nil
+}
+
+// convertGeneric 将一个会话对象转换为待导入的会话，消息按数组顺序组成一条分支
+func convertGeneric(item jsonObject) (*models.ImportedSession, error) {
+	var rawMessages []jsonObject
+	if err := json.Unmarshal(item.raw(messagesFields...), &rawMessages); err != nil {
+		return nil, fmt.Errorf("解析消息列表失败: %w", err)
+	}
+
+	session := models.NewSession()
+	if title := item.text(titleFields...); title != "" {
+		session.Title = title
+	}
+	if created := item.timestamp(createdFields...); !created.IsZero() {
+		session.CreatedAt, session.UpdatedAt = created, created
+	}
+	if updated := item.timestamp(updatedFields...); !updated.IsZero() {
+		session.UpdatedAt = updated
+	}
+
+	var history []*models.Message
+	var messageIDs []string
+	var systemPrompts []string
+	for _, raw := range rawMessages {
+		role := raw.role()
+		content := raw.content()
+		if systemRoles[role] {
+			systemPrompts = append(systemPrompts, content)
+			continue
+		}
+		msgRole, ok := messageRole(role)
+		if !ok || strings.TrimSpace(content) == "" {
+			continue
+		}
+
+		msg := models.NewMessage(msgRole, content)
+		msg.Timestamp = raw.timestamp(createdFields...)
+		history = append(history, msg)
+		messageIDs = append(messageIDs, raw.text(idFields...))
+	}
+	session.SystemPrompt = strings.Join(systemPrompts, "\n\n")
+
+	return linearSession("json", item.text(idFields...), session, history, messageIDs), nil
+}
+
+// linearSession 将一条消息链转换为待导入的会话，来源标识优先使用导出文件中的 ID，没有时按内容生成
+func linearSession(kind, id string, session *models.Session, history []*models.Message, messageIDs []string) *models.ImportedSession {
+	if id == "" {
+		var first string
+		if len(history) > 0 {
+			first = string(history[0].Role) + "\x00" + history[0].Content + "\x00" + history[0].Timestamp.String()
+		}
+		id = contentKey(session.Title, first)
+	}
+	sourceKey := kind + ":" + id
+	imp := &models.ImportedSession{SourceKey: sourceKey, Session: session}
+
+	var parentKey string
+	for i, msg := range history {
+		if msg.Timestamp.IsZero() {
+			msg.Timestamp = session.CreatedAt
+		}
+
+		key := sourceKey + "/" + contentKey(parentKey, string(msg.Role), msg.Content)
+		if i < len(messageIDs) && messageIDs[i] != "" {
+			key = sourceKey + "/" + messageIDs[i]
+		}
+		imp.Messages = append(imp.Messages, &models.ImportedMessage{SourceKey: key, ParentKey: parentKey, Message: msg})
+		parentKey = key
+	}
+	return imp
+}
+
+// messageRole 将各客户端的角色名称转换为消息角色
+func messageRole(name string) (models.Role, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "user", "human", "me", "you", "我", "用户":
+		return models.RoleUser, true
+	case "assistant", "ai", "bot", "model", "gpt", "chatgpt", "claude", "gemini", "助手":
+		return models.RoleAssistant, true
+	default:
+		return "", false
+	}
+}
+
+// raw 返回第一个存在且不为 null 的字段
+func (o jsonObject) raw(names ...string) json.RawMessage {
+	for _, name := range names {
+		if v, ok := o[name]; ok && string(v) != "null" {
+			return v
+		}
+	}
+	return nil
+}
+
+// text 返回第一个字符串或数字类型的字段
+func (o jsonObject) text(names ...string) string {
+	for _, name := range names {
+		v := o.raw(name)
+		var s string
+		if json.Unmarshal(v, &s) == nil && strings.TrimSpace(s) != "" {
+			return strings.TrimSpace(s)
+		}
+		var n json.Number
+		if json.Unmarshal(v, &n) == nil {
+			return n.String()
+		}
+	}
+	return ""
+}
+
+// timestamp 解析第一个可识别的时间字段，支持 Unix 秒、毫秒和常见的字符串格式
+func (o jsonObject) timestamp(names ...string) time.Time {
+	for _, name := range names {
+		v := o.raw(name)
+		var n float64
+		if json.Unmarshal(v, &n) == nil {
+			if n > 1e12 {
+				n /= 1000
+			}
+			if t := unixTime(n); !t.IsZero() {
+				return t
+			}
+			continue
+		}
+		var s string
+		if json.Unmarshal(v, &s) != nil {
+			continue
+		}
+		if n, err := strconv.ParseFloat(s, 64); err == nil && n > 0 {
+			return unixTime(n)
+		}
+		for _, layout := range timeLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// role 读取消息的角色，author 可能是 {"role": "..."} 形式的对象
+func (o jsonObject) role() string {
+	if role := o.text(roleFields...); role != "" {
+		return strings.ToLower(role)
+	}
+	var author jsonObject
+	if json.Unmarshal(o.raw(roleFields...), &author) == nil {
+		return strings.ToLower(author.text("role", "name"))
+	}
+	return ""
+}
+
+// content 读取消息内容：字符串、字符串数组或 [{"type": "text", "text": "..."}] 形式的内容块
+func (o jsonObject) content() string {
+	for _, name := range contentFields {
+		if text := partsText(o.raw(name)); strings.TrimSpace(text) != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// partsText 提取内容中的文本，非文本内容块（图片等）以占位符代替
+func partsText(v json.RawMessage) string {
+	if v == nil {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(v, &s) == nil {
+		return s
+	}
+
+	var object jsonObject
+	if json.Unmarshal(v, &object) == nil {
+		// ChatGPT 风格的 {"parts": [...]} 或单个内容块
+		if parts := object.raw("parts"); parts != nil {
+			return partsText(parts)
+		}
+		return partsText(jsonObjectArray(object))
+	}
+
+	var parts []json.RawMessage
+	if json.Unmarshal(v, &parts) != nil {
+		return ""
+	}
+	var texts []string
+	for _, part := range parts {
+		if json.Unmarshal(part, &s) == nil {
+			texts = append(texts, s)
+			continue
+		}
+		var block jsonObject
+		if json.Unmarshal(part, &block) != nil {
+			continue
+		}
+		if textPartTypes[block.text("type")] {
+			if text := block.text("text"); text != "" {
+				texts = append(texts, text)
+			}
+		} else if strings.Contains(block.text("type"), "image") {
+			texts = append(texts, "[图片]")
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// jsonObjectArray 将单个内容块包装为数组
+func jsonObjectArray(object jsonObject) json.RawMessage {
+	data, err := json.Marshal([]jsonObject{object})
+	if err != nil {
+		return nil
+	}
+	return data
+}
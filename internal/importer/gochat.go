@@ -0,0 +1,37 @@
+package importer
+
+import (
+	"bytes"
+
+	"github.com/wangle201210/gochat/internal/exporter"
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// parseGoChat 解析 gochat export -f json 导出的会话，保留原有的会话和消息 ID
+func parseGoChat(data []byte) ([]*models.ImportedSession, error) {
+	conv, err := exporter.ReadJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	sourceKey := "gochat:" + conv.Session.ID
+	keyOf := func(messageID string) string {
+		if messageID == "" {
+			return ""
+		}
+		return sourceKey + "/" + messageID
+	}
+
+	imp := &models.ImportedSession{SourceKey: sourceKey, Session: conv.Session}
+	for _, msg := range conv.Messages {
+		imp.Messages = append(imp.Messages, &models.ImportedMessage{
+			SourceKey: keyOf(msg.ID),
+			ParentKey: keyOf(msg.ParentID),
+			Message:   msg,
+		})
+	}
+	if n := len(conv.ActivePath); n > 0 {
+		imp.ActiveKey = keyOf(conv.ActivePath[n-1].ID)
+	}
+	return []*models.ImportedSession{imp}, nil
+}
@@ -0,0 +1,210 @@
+// Package importer 从 ChatGPT 等客户端的导出文件导入会话：解析 ChatGPT 的 conversations.json
+// （含消息树和分支）、GoChat 自己的 JSON 导出、通用的 JSON 消息列表和 Markdown 对话记录，
+// 保留原始标题和时间写入数据库；每个会话和消息按来源中的标识记录，重复导入时只追加新消息
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/storage"
+)
+
+// Progress 导入进度
+type Progress struct {
+	Done  int    // 已处理的会话数
+	Total int    // 会话总数
+	Title string // 正在导入的会话
+}
+
+// Result 一次导入的统计
+type Result struct {
+	Created  int // 新建的会话数
+	Updated  int // 追加了新消息的已导入会话数
+	Skipped  int // 没有新消息而跳过的会话数
+	Messages int // 新写入的消息数
+}
+
+// ParseFile 读取并解析导出文件，支持 .json、.md 以及 ChatGPT 导出的 .zip
+func ParseFile(path string) ([]*models.ImportedSession, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取导入文件失败: %w", err)
+	}
+	return Parse(filepath.Base(path), data)
+}
+
+// Parse 按文件名和内容判断格式并解析出会话
+func Parse(name string, data []byte) ([]*models.ImportedSession, error) {
+	var (
+		sessions []*models.ImportedSession
+		err      error
+	)
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".zip":
+		sessions, err = parseZip(data)
+	case ".md", ".markdown", ".txt":
+		sessions, err = parseMarkdown(name, string(data))
+	default:
+		sessions, err = parseJSON(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, fmt.Errorf("%s 中没有可导入的会话", name)
+	}
+	return sessions, nil
+}
+
+// parseJSON 识别 JSON 导出的格式：GoChat 导出、ChatGPT 的 conversations.json 或通用消息列表
+func parseJSON(data []byte) ([]*models.ImportedSession, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var probe struct {
+		Format  string          `json:"format"`
+		Mapping json.RawMessage `json:"mapping"`
+	}
+	var list []struct {
+		Mapping json.RawMessage `json:"mapping"`
+	}
+
+	switch {
+	case json.Unmarshal(data, &list) == nil:
+		if len(list) > 0 && list[0].Mapping != nil {
+			return parseChatGPT(data)
+		}
+		return parseGeneric(data)
+	case json.Unmarshal(data, &probe) == nil:
+		switch {
+		case probe.Format != "":
+			return parseGoChat(data)
+		case probe.Mapping != nil:
+			return parseChatGPT(append(append([]byte("["), data...), ']'))
+		default:
+			return parseGeneric(data)
+		}
+	default:
+		return nil, errors.New("无法识别的导入文件：不是有效的 JSON")
+	}
+}
+
+// parseZip 解析 ChatGPT 数据导出的压缩包，读取其中的 conversations.json
+func parseZip(data []byte) ([]*models.ImportedSession, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("读取压缩包失败: %w", err)
+	}
+	for _, file := range archive.File {
+		if filepath.Base(file.Name) != "conversations.json" {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("读取压缩包失败: %w", err)
+		}
+		defer reader.Close()
+
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("读取压缩包失败: %w", err)
+		}
+		return parseChatGPT(content)
+	}
+	return nil, errors.New("压缩包中没有 conversations.json")
+}
+
+// Import 将解析出的会话依次写入数据库，progress 可为空；取消时已导入的会话会保留
+func Import(ctx context.Context, db *storage.Database, sessions []*models.ImportedSession, progress func(Progress)) (*Result, error) {
+	result := &Result{}
+	for i, imp := range sessions {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if progress != nil {
+			progress(Progress{Done: i, Total: len(sessions), Title: imp.Session.Title})
+		}
+
+		imp.Messages = sortByParent(imp.Messages)
+		r, err := db.ImportSession(imp)
+		if err != nil {
+			return result, fmt.Errorf("导入会话 %s 失败: %w", imp.Session.Title, err)
+		}
+
+		switch {
+		case r.Created:
+			result.Created++
+		case r.Added > 0:
+			result.Updated++
+		default:
+			result.Skipped++
+		}
+		result.Messages += r.Added
+	}
+	if progress != nil {
+		progress(Progress{Done: len(sessions), Total: len(sessions)})
+	}
+	return result, nil
+}
+
+// sortByParent 按消息树深度优先排列，使父消息排在子消息之前，同级消息保持原有顺序；
+// 父消息不在列表中的消息视为根消息，环上的消息会被丢弃
+func sortByParent(messages []*models.ImportedMessage) []*models.ImportedMessage {
+	keys := make(map[string]bool, len(messages))
+	for _, im := range messages {
+		keys[im.SourceKey] = true
+	}
+
+	children := make(map[string][]*models.ImportedMessage)
+	var roots []*models.ImportedMessage
+	for _, im := range messages {
+		if !keys[im.ParentKey] || im.ParentKey == im.SourceKey {
+			im.ParentKey = ""
+			roots = append(roots, im)
+			continue
+		}
+		children[im.ParentKey] = append(children[im.ParentKey], im)
+	}
+
+	sorted := make([]*models.ImportedMessage, 0, len(messages))
+	var visit func(im *models.ImportedMessage)
+	visit = func(im *models.ImportedMessage) {
+		sorted = append(sorted, im)
+		for _, child := range children[im.SourceKey] {
+			visit(child)
+		}
+	}
+	for _, root := range roots {
+		visit(root)
+	}
+	return sorted
+}
+
+// sortByTimestamp 按消息时间稳定排序
+func sortByTimestamp(messages []*models.ImportedMessage) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Message.Timestamp.Before(messages[j].Message.Timestamp)
+	})
+}
+
+// contentKey 根据内容生成来源标识，用于没有 ID 的导出格式
+func contentKey(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		io.WriteString(h, part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
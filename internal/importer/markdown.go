@@ -0,0 +1,151 @@
+package importer
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// parseMarkdown 解析 Markdown 对话记录：以角色名开头的标题（如 "## User"、"### 助手 · 2024-01-02 10:00:00"）
+// 开始一条消息，直到下一个角色标题；第一个一级标题作为会话标题，没有时使用文件名。
+// gochat export 导出的 Markdown 中的创建时间、系统提示词和消息时间会一并还原
+func parseMarkdown(name, text string) ([]*models.ImportedSession, error) {
+	session := models.NewSession()
+	session.Title = strings.TrimSuffix(name, filepath.Ext(name))
+
+	var (
+		history    []*models.Message
+		current    *models.Message
+		body       []string
+		preamble   []string
+		titleFound bool
+		skipping   bool // 正在跳过不导入的消息（如工具结果）
+		inCode     bool
+	)
+	flush := func() {
+		if current != nil {
+			current.Content = trimSection(body)
+			if strings.TrimSpace(current.Content) != "" {
+				history = append(history, current)
+			}
+		}
+		current, body = nil, nil
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		// 代码块中的 # 不是标题
+		if heading, level := markdownHeading(line); level > 0 && !inCode {
+			if level == 1 && !titleFound && current == nil && len(history) == 0 {
+				session.Title = heading
+				titleFound = true
+				continue
+			}
+			if role, timestamp, ok := headingRole(heading); ok {
+				flush()
+				skipping = role == ""
+				if !skipping {
+					current = models.NewMessage(role, "")
+					current.Timestamp = timestamp
+				}
+				continue
+			}
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+		}
+
+		switch {
+		case current != nil:
+			body = append(body, line)
+		case !skipping && len(history) == 0:
+			preamble = append(preamble, line)
+		}
+	}
+	flush()
+	if len(history) == 0 {
+		return nil, nil
+	}
+
+	if first := history[0].Timestamp; !first.IsZero() {
+		session.CreatedAt = first
+	}
+	if last := history[len(history)-1].Timestamp; !last.IsZero() {
+		session.UpdatedAt = last
+	}
+	applyPreamble(session, preamble)
+	return []*models.ImportedSession{linearSession("md", "", session, history, nil)}, nil
+}
+
+// markdownHeading 返回标题行的文本和级别，不是标题时级别为 0
+func markdownHeading(line string) (string, int) {
+	trimmed := strings.TrimLeft(line, "#")
+	level := len(line) - len(trimmed)
+	if level == 0 || level > 6 || !strings.HasPrefix(trimmed, " ") {
+		return "", 0
+	}
+	return strings.TrimSpace(trimmed), level
+}
+
+// headingRole 判断标题是否为角色标题，返回角色和标题中的时间；工具结果等不导入的消息返回空角色
+func headingRole(heading string) (models.Role, time.Time, bool) {
+	label, rest, _ := strings.Cut(heading, " · ")
+	label = strings.TrimFunc(label, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if strings.HasPrefix(label, "工具结果") || strings.EqualFold(label, "tool") {
+		return "", time.Time{}, true
+	}
+	// "助手 (gpt-4o)" 之类带模型名的标题
+	if name, _, found := strings.Cut(label, " ("); found {
+		label = name
+	}
+
+	role, ok := messageRole(label)
+	if !ok {
+		return "", time.Time{}, false
+	}
+	timestamp, _ := time.ParseInLocation(time.DateTime, strings.TrimSpace(rest), time.Local)
+	return role, timestamp, true
+}
+
+// applyPreamble 从标题与第一条消息之间的内容中读取 gochat export 写入的时间和系统提示词
+func applyPreamble(session *models.Session, preamble []string) {
+	var systemPrompt []string
+	inPrompt := false
+	for _, line := range preamble {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "- 创建时间:"):
+			if t, err := time.ParseInLocation(time.DateTime, strings.TrimSpace(strings.TrimPrefix(trimmed, "- 创建时间:")), time.Local); err == nil {
+				session.CreatedAt = t
+			}
+		case strings.HasPrefix(trimmed, "- 更新时间:"):
+			if t, err := time.ParseInLocation(time.DateTime, strings.TrimSpace(strings.TrimPrefix(trimmed, "- 更新时间:")), time.Local); err == nil {
+				session.UpdatedAt = t
+			}
+		case trimmed == "**系统提示词**":
+			inPrompt = true
+		case inPrompt && strings.HasPrefix(trimmed, ">"):
+			systemPrompt = append(systemPrompt, strings.TrimPrefix(strings.TrimPrefix(trimmed, ">"), " "))
+		}
+	}
+	session.SystemPrompt = strings.Join(systemPrompt, "\n")
+}
+
+// trimSection 去掉消息正文首尾的空行和分隔线
+func trimSection(lines []string) string {
+	for len(lines) > 0 {
+		last := strings.TrimSpace(lines[len(lines)-1])
+		if last != "" && last != "---" && last != "***" {
+			break
+		}
+		lines = lines[:len(lines)-1]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Attachment 表示随用户消息发送的文件
+type Attachment struct {
+	ID        string    `json:"id"`
+	MessageID string    `json:"message_id"`
+	Name      string    `json:"name"`
+	MimeType  string    `json:"mime_type"`
+	Size      int64     `json:"size"`
+	Data      []byte    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewAttachment 创建附件
+func NewAttachment(name, mimeType string, data []byte) *Attachment {
+	return &Attachment{
+		ID:        NewID(),
+		Name:      name,
+		MimeType:  mimeType,
+		Size:      int64(len(data)),
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+}
+
+// IsImage 判断附件是否为图片，图片以多模态内容发送给模型
+func (a *Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MimeType, "image/")
+}
+
+// IsText 判断附件是否为文本，文本附件的内容直接附在提示词中
+func (a *Attachment) IsText() bool {
+	return strings.HasPrefix(a.MimeType, "text/") ||
+		a.MimeType == "application/json" ||
+		a.MimeType == "application/xml" ||
+		a.MimeType == "application/x-yaml"
+}
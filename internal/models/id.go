@@ -0,0 +1,9 @@
+package models
+
+import "github.com/google/uuid"
+
+// NewID 生成会话、消息等记录的 ID：UUIDv7 以毫秒时间戳开头，同一进程内严格递增，
+// 按字符串排序即为创建顺序，同一毫秒内创建多条记录也不会重复
+func NewID() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
@@ -0,0 +1,23 @@
+package models
+
+// ImportedSession 从其他客户端导出文件中解析出的会话
+type ImportedSession struct {
+	SourceKey string             // 会话在来源中的唯一标识，重复导入时据此找到已导入的会话
+	Session   *Session           // 标题、时间等会话信息，ID 在首次导入时使用
+	Messages  []*ImportedMessage // 父消息排在子消息之前
+	ActiveKey string             // 当前分支末端消息的 SourceKey，为空时使用最后一条消息
+}
+
+// ImportedMessage 待导入的消息
+type ImportedMessage struct {
+	SourceKey string // 消息在来源中的唯一标识，已导入的消息不会重复写入
+	ParentKey string // 父消息的 SourceKey，为空表示会话的第一条消息
+	Message   *Message
+}
+
+// ImportResult 导入一个会话的结果
+type ImportResult struct {
+	SessionID string // 导入到的会话
+	Created   bool   // 是否新建了会话，否则为追加到之前导入的会话
+	Added     int    // 新写入的消息数
+}
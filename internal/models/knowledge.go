@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// KnowledgeBase 表示一个由本地文件夹构建的知识库
+type KnowledgeBase struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	Path           string    `json:"path"`            // 文件夹的绝对路径
+	EmbeddingModel string    `json:"embedding_model"` // 建立索引时使用的向量模型，变化后需要重建索引
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// NewKnowledgeBase 创建新知识库
+func NewKnowledgeBase(name, path string) *KnowledgeBase {
+	now := time.Now()
+	return &KnowledgeBase{
+		ID:        NewID(),
+		Name:      name,
+		Path:      path,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// KnowledgeDocument 表示知识库中已索引的一个文件
+type KnowledgeDocument struct {
+	Path    string    `json:"path"` // 相对于知识库文件夹的路径
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Chunks  int       `json:"chunks"`
+}
+
+// KnowledgeChunk 表示文件切分后的一个片段及其向量
+type KnowledgeChunk struct {
+	DocumentPath string    `json:"document_path"`
+	Seq          int       `json:"seq"` // 在文件中的序号
+	Content      string    `json:"content"`
+	Embedding    []float32 `json:"-"`
+}
+
+// Citation 表示回复引用的知识库片段
+type Citation struct {
+	Index   int     `json:"index"`   // 在提示词中的编号，对应回复中的 [n]
+	Source  string  `json:"source"`  // 文件相对路径
+	Score   float64 `json:"score"`   // 与问题的相似度
+	Content string  `json:"content"` // 片段内容（保存时只保留摘录）
+}
@@ -1,9 +1,6 @@
 package models
 
-import (
-	"fmt"
-	"time"
-)
+import "time"
 
 // Role 表示消息角色
 type Role string
@@ -12,27 +9,57 @@ const (
 	RoleUser      Role = "user"
 	RoleAssistant Role = "assistant"
 	RoleSystem    Role = "system"
+	RoleTool      Role = "tool" // 工具调用的结果
 )
 
+// MessageStatus 表示消息的生成状态
+type MessageStatus string
+
+const (
+	StatusComplete    MessageStatus = ""            // 正常完成
+	StatusInterrupted MessageStatus = "interrupted" // 生成过程中被用户中止
+	StatusFailed      MessageStatus = "failed"      // 生成过程中出错
+)
+
+// ToolCall 表示模型发起的一次工具调用
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON 格式的参数
+}
+
 // Message 表示一条聊天消息
 type Message struct {
-	ID        string    `json:"id"`
-	Role      Role      `json:"role"`
-	Content   string    `json:"content"`
-	Timestamp time.Time `json:"timestamp"`
+	ID        string        `json:"id"`
+	ParentID  string        `json:"parent_id,omitempty"` // 上一条消息，为空表示会话的第一条消息
+	Role      Role          `json:"role"`
+	Content   string        `json:"content"`
+	Timestamp time.Time     `json:"timestamp"`
+	Status    MessageStatus `json:"status,omitempty"`
+	Usage     *Usage        `json:"usage,omitempty"` // 模型调用的用量，仅助手消息有值
+
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // 助手消息发起的工具调用
+	ToolCallID string     `json:"tool_call_id,omitempty"` // 工具结果对应的调用 ID
+	ToolName   string     `json:"tool_name,omitempty"`    // 工具结果对应的工具名称
+
+	Citations   []Citation    `json:"citations,omitempty"`   // 回复引用的知识库片段
+	Attachments []*Attachment `json:"attachments,omitempty"` // 用户消息携带的文件
+}
+
+// BranchInfo 消息在同一父消息下的兄弟分支中的位置
+type BranchInfo struct {
+	Index  int    // 从 0 开始的位置，按创建时间排序
+	Count  int    // 兄弟分支总数（包括自身）
+	PrevID string // 前一个分支的消息 ID，第一个分支为空
+	NextID string // 后一个分支的消息 ID，最后一个分支为空
 }
 
 // NewMessage 创建新消息
 func NewMessage(role Role, content string) *Message {
 	return &Message{
-		ID:        generateID(),
+		ID:        NewID(),
 		Role:      role,
 		Content:   content,
 		Timestamp: time.Now(),
 	}
 }
-
-// generateID 生成消息 ID
-func generateID() string {
-	return fmt.Sprintf("%d", time.Now().UnixMilli())
-}
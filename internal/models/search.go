@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// SearchResult 表示一条全文搜索命中结果
+type SearchResult struct {
+	SessionID    string    `json:"session_id"`
+	SessionTitle string    `json:"session_title"`
+	MessageID    string    `json:"message_id,omitempty"` // 命中会话标题时为空
+	Role         Role      `json:"role,omitempty"`
+	Snippet      string    `json:"snippet"` // 命中片段，关键词以 SnippetMarkStart/SnippetMarkEnd 包裹
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// 搜索片段中关键词的包裹标记
+const (
+	SnippetMarkStart = "\x02"
+	SnippetMarkEnd   = "\x03"
+)
@@ -8,14 +8,37 @@ type Session struct {
 	Title     string    `json:"title"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// 会话级模型设置，为空时使用配置文件中的默认值
+	SystemPrompt string   `json:"system_prompt,omitempty"` // 系统提示词
+	Model        string   `json:"model,omitempty"`         // 模型名称
+	Temperature  *float32 `json:"temperature,omitempty"`   // 采样温度
+	TopP         *float32 `json:"top_p,omitempty"`         // 核采样概率
+	MaxTokens    *int     `json:"max_tokens,omitempty"`    // 单次回复的最大 token 数
+
+	DisabledMCPServers []string `json:"disabled_mcp_servers,omitempty"` // 本会话中禁用的 MCP 服务
+	KnowledgeBaseID    string   `json:"knowledge_base_id,omitempty"`    // 关联的知识库
+}
+
+// MCPServerEnabled 判断 MCP 服务在本会话中是否启用
+func (s *Session) MCPServerEnabled(name string) bool {
+	for _, disabled := range s.DisabledMCPServers {
+		if disabled == name {
+			return false
+		}
+	}
+	return true
 }
 
+// DefaultSessionTitle 新会话在生成标题前使用的标题
+const DefaultSessionTitle = "新会话"
+
 // NewSession 创建新会话
 func NewSession() *Session {
 	now := time.Now()
 	return &Session{
-		ID:        generateID(),
-		Title:     "新会话",
+		ID:        NewID(),
+		Title:     DefaultSessionTitle,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
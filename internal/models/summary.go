@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Summary 表示会话较早部分的滚动摘要
+type Summary struct {
+	SessionID     string    `json:"session_id"`
+	UpToMessageID string    `json:"up_to_message_id"` // 摘要覆盖到的最后一条消息
+	Content       string    `json:"content"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
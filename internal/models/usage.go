@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Usage 表示一次模型调用的用量信息
+type Usage struct {
+	Model            string        `json:"model"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	Latency          time.Duration `json:"latency"`
+	FinishReason     string        `json:"finish_reason,omitempty"`
+}
+
+// TotalTokens 返回输入与输出 token 之和
+func (u *Usage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// UsageStat 表示按模型汇总的用量统计
+type UsageStat struct {
+	Day              string `json:"day,omitempty"` // 统计日期（YYYY-MM-DD），按会话汇总时为空
+	Model            string `json:"model"`
+	Messages         int    `json:"messages"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// requireToken 校验请求头 Authorization: Bearer <令牌>，令牌须与配置中的某一个一致
+func (s *Server) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !s.validToken(strings.TrimSpace(token)) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gochat"`)
+			writeError(w, http.StatusUnauthorized, "缺少或无效的访问令牌")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validToken 以恒定时间比较令牌，避免通过响应时间猜测令牌
+func (s *Server) validToken(token string) bool {
+	valid := false
+	for _, expected := range s.tokens {
+		if expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -0,0 +1,328 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/ai"
+)
+
+// maxRequestBody 请求体的大小上限（图片以 data URL 传入时请求体较大）
+const maxRequestBody = 32 << 20
+
+// handleModels 返回已配置的模型
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	list := modelList{Object: "list", Data: make([]modelEntry, 0, len(s.models))}
+	for _, m := range s.models {
+		list.Data = append(list.Data, modelEntry{ID: m.id, Object: "model", Created: s.created.Unix(), OwnedBy: m.owner})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleChatCompletions 将对话请求转发给模型，stream 为 true 时以 SSE 流式返回
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req chatRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeError(w, http.StatusBadRequest, "messages 不能为空")
+		return
+	}
+
+	messages, err := toSchemaMessages(req.Messages)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts, err := req.modelOptions()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	target, modelOpts := s.resolveModel(req.Model)
+	opts = append(modelOpts, opts...)
+
+	ex := &exchange{
+		id:       "chatcmpl-" + randomID(),
+		created:  time.Now(),
+		model:    target.id,
+		request:  &req,
+		messages: messages,
+	}
+	if s.recorder != nil {
+		// 在调用模型前转换历史，保证历史消息的时间早于回复
+		ex.history, ex.systemPrompt = convertHistory(req.Messages)
+	}
+	if req.Stream {
+		s.streamCompletion(r.Context(), w, target, ex, opts)
+	} else {
+		s.generateCompletion(r.Context(), w, target, ex, opts)
+	}
+}
+
+// generateCompletion 一次性返回完整回复
+func (s *Server) generateCompletion(ctx context.Context, w http.ResponseWriter, target *chatModel, ex *exchange, opts []model.Option) {
+	resp, err := target.model.Generate(ctx, ex.messages, opts...)
+	if err != nil {
+		ex.finish("", nil, nil, failureStatus(ctx))
+		s.record(ex)
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("模型调用失败: %v", err))
+		return
+	}
+
+	ex.finish(resp.Content, resp.ToolCalls, resp.ResponseMeta, models.StatusComplete)
+	s.record(ex)
+
+	content := resp.Content
+	finishReason := ex.finishReason()
+	writeJSON(w, http.StatusOK, chatCompletion{
+		ID:      ex.id,
+		Object:  "chat.completion",
+		Created: ex.created.Unix(),
+		Model:   ex.model,
+		Choices: []choice{{
+			Message: &responseMessage{
+				Role:      "assistant",
+				Content:   &content,
+				ToolCalls: toResponseToolCalls(resp.ToolCalls, false),
+			},
+			FinishReason: &finishReason,
+		}},
+		Usage: ex.usageReport(),
+	})
+}
+
+// streamCompletion 以 SSE 流式返回回复，格式与 OpenAI 一致，以 data: [DONE] 结束
+func (s *Server) streamCompletion(ctx context.Context, w http.ResponseWriter, target *chatModel, ex *exchange, opts []model.Option) {
+	reader, err := target.model.Stream(ctx, ex.messages, opts...)
+	if err != nil {
+		ex.finish("", nil, nil, failureStatus(ctx))
+		s.record(ex)
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("模型调用失败: %v", err))
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	sse := &sseWriter{w: w}
+
+	var content strings.Builder
+	var toolChunks []*schema.Message
+	meta := &schema.ResponseMeta{}
+	first := true
+	for {
+		chunk, err := reader.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			ex.finish(content.String(), mergeToolCalls(toolChunks), meta, failureStatus(ctx))
+			s.record(ex)
+			sse.send(errorResponse{Error: errorBody{Message: fmt.Sprintf("模型调用失败: %v", err), Type: "server_error"}})
+			sse.done()
+			return
+		}
+
+		content.WriteString(chunk.Content)
+		ai.MergeResponseMeta(meta, chunk.ResponseMeta)
+		if len(chunk.ToolCalls) > 0 {
+			toolChunks = append(toolChunks, chunk)
+		}
+		if chunk.Content == "" && len(chunk.ToolCalls) == 0 && !first {
+			continue
+		}
+
+		delta := &responseMessage{ToolCalls: toResponseToolCalls(chunk.ToolCalls, true)}
+		if first {
+			delta.Role = "assistant"
+			first = false
+		}
+		if chunk.Content != "" || delta.Role != "" {
+			text := chunk.Content
+			delta.Content = &text
+		}
+		sse.send(ex.chunk(delta, nil))
+	}
+
+	ex.finish(content.String(), mergeToolCalls(toolChunks), meta, models.StatusComplete)
+	s.record(ex)
+
+	finishReason := ex.finishReason()
+	sse.send(ex.chunk(&responseMessage{}, &finishReason))
+	if opts := ex.request.StreamOptions; opts != nil && opts.IncludeUsage {
+		sse.send(chatCompletion{
+			ID:      ex.id,
+			Object:  "chat.completion.chunk",
+			Created: ex.created.Unix(),
+			Model:   ex.model,
+			Choices: []choice{},
+			Usage:   ex.usageReport(),
+		})
+	}
+	sse.done()
+}
+
+// failureStatus 返回未完成回复的状态：客户端断开连接时为中断，否则为失败
+func failureStatus(ctx context.Context) models.MessageStatus {
+	if ctx.Err() != nil {
+		return models.StatusInterrupted
+	}
+	return models.StatusFailed
+}
+
+// record 把对话写入数据库，未开启记录时忽略
+func (s *Server) record(ex *exchange) {
+	if s.recorder == nil {
+		return
+	}
+	if err := s.recorder.record(ex); err != nil {
+		log.Printf("记录会话失败: %v", err)
+	}
+}
+
+// exchange 一次请求及其回复
+type exchange struct {
+	id       string
+	created  time.Time
+	model    string
+	request  *chatRequest
+	messages []*schema.Message // 转发给模型的消息
+
+	// 记录会话时使用：请求中的历史消息（系统消息合并为系统提示词）和模型的回复
+	history      []*models.Message
+	systemPrompt string
+	reply        *models.Message
+}
+
+// finish 生成回复消息和用量，provider 未返回用量时按 ai.NewUsage 的规则估算
+func (ex *exchange) finish(content string, calls []schema.ToolCall, meta *schema.ResponseMeta, status models.MessageStatus) {
+	reply := models.NewMessage(models.RoleAssistant, content)
+	reply.Status = status
+	for _, call := range calls {
+		reply.ToolCalls = append(reply.ToolCalls, models.ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+	reply.Usage = ai.NewUsage(ex.model, ex.created, meta, ex.messages, content)
+	ex.reply = reply
+}
+
+// finishReason 返回回复的结束原因，provider 未返回时按是否调用工具推断
+func (ex *exchange) finishReason() string {
+	if reason := ex.reply.Usage.FinishReason; reason != "" {
+		return reason
+	}
+	if len(ex.reply.ToolCalls) > 0 {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+// usageReport 返回响应中的用量
+func (ex *exchange) usageReport() *usageReport {
+	usage := ex.reply.Usage
+	return &usageReport{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens(),
+	}
+}
+
+// chunk 生成一个流式分块
+func (ex *exchange) chunk(delta *responseMessage, finishReason *string) chatCompletion {
+	return chatCompletion{
+		ID:      ex.id,
+		Object:  "chat.completion.chunk",
+		Created: ex.created.Unix(),
+		Model:   ex.model,
+		Choices: []choice{{Delta: delta, FinishReason: finishReason}},
+	}
+}
+
+// mergeToolCalls 合并流式分块中的工具调用
+func mergeToolCalls(chunks []*schema.Message) []schema.ToolCall {
+	if len(chunks) == 0 {
+		return nil
+	}
+	merged, err := schema.ConcatMessages(chunks)
+	if err != nil {
+		log.Printf("合并工具调用失败: %v", err)
+		return nil
+	}
+	return merged.ToolCalls
+}
+
+// sseWriter 写入 Server-Sent Events
+type sseWriter struct {
+	w http.ResponseWriter
+}
+
+// send 写入一个 data 事件并立即发送
+func (s *sseWriter) send(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("序列化流式响应失败: %v", err)
+		return
+	}
+	fmt.Fprintf(s.w, "data: %s\n\n", data)
+	s.flush()
+}
+
+// done 写入流结束标记
+func (s *sseWriter) done() {
+	fmt.Fprint(s.w, "data: [DONE]\n\n")
+	s.flush()
+}
+
+func (s *sseWriter) flush() {
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// writeJSON 写入 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("写入响应失败: %v", err)
+	}
+}
+
+// writeError 写入 OpenAI 格式的错误响应，REST 接口也使用同样的格式
+func writeError(w http.ResponseWriter, status int, message string) {
+	var errType string
+	switch {
+	case status == http.StatusUnauthorized:
+		errType = "authentication_error"
+	case status == http.StatusNotFound:
+		errType = "not_found_error"
+	case status >= http.StatusInternalServerError:
+		errType = "server_error"
+	default:
+		errType = "invalid_request_error"
+	}
+	writeJSON(w, status, errorResponse{Error: errorBody{Message: message, Type: errType}})
+}
+
+// randomID 生成响应 ID 的随机部分
+func randomID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,318 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+)
+
+// chatRequest /v1/chat/completions 的请求体（只解析转发需要的字段）
+type chatRequest struct {
+	Model               string          `json:"model"`
+	Messages            []chatMessage   `json:"messages"`
+	Stream              bool            `json:"stream"`
+	StreamOptions       *streamOptions  `json:"stream_options,omitempty"`
+	Temperature         *float32        `json:"temperature,omitempty"`
+	TopP                *float32        `json:"top_p,omitempty"`
+	MaxTokens           *int            `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int            `json:"max_completion_tokens,omitempty"`
+	Stop                stringList      `json:"stop,omitempty"`
+	Tools               []chatTool      `json:"tools,omitempty"`
+	ToolChoice          json.RawMessage `json:"tool_choice,omitempty"`
+}
+
+// streamOptions 流式请求的附加选项
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// chatMessage 请求中的一条消息
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    messageContent `json:"content"`
+	Name       string         `json:"name,omitempty"`
+	ToolCalls  []toolCall     `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+// messageContent 消息内容，可以是字符串或由文本、图片组成的数组
+type messageContent struct {
+	Text  string
+	Parts []contentPart
+}
+
+// contentPart 多模态内容中的一段
+type contentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+// imageURL 图片地址，可以是 http(s) 链接或 data URL
+type imageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// UnmarshalJSON 兼容字符串、数组和 null 三种写法
+func (c *messageContent) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return nil
+	case len(data) > 0 && data[0] == '"':
+		return json.Unmarshal(data, &c.Text)
+	default:
+		return json.Unmarshal(data, &c.Parts)
+	}
+}
+
+// text 返回内容中的全部文本
+func (c messageContent) text() string {
+	if len(c.Parts) == 0 {
+		return c.Text
+	}
+	var texts []string
+	for _, part := range c.Parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// images 返回内容中的图片地址
+func (c messageContent) images() []string {
+	var urls []string
+	for _, part := range c.Parts {
+		if part.Type == "image_url" && part.ImageURL != nil {
+			urls = append(urls, part.ImageURL.URL)
+		}
+	}
+	return urls
+}
+
+// stringList 兼容字符串和字符串数组两种写法
+type stringList []string
+
+// UnmarshalJSON 将单个字符串视为只有一个元素的数组
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = stringList{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+// chatTool 调用方提供给模型的工具
+type chatTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+// toolCall 模型发起的工具调用，流式响应中按 Index 分块
+type toolCall struct {
+	Index    *int   `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// chatCompletion 非流式响应和流式分块共用的结构
+type chatCompletion struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []choice     `json:"choices"`
+	Usage   *usageReport `json:"usage,omitempty"`
+}
+
+// choice 响应中的一个候选回复
+type choice struct {
+	Index        int              `json:"index"`
+	Message      *responseMessage `json:"message,omitempty"`
+	Delta        *responseMessage `json:"delta,omitempty"`
+	FinishReason *string          `json:"finish_reason"`
+}
+
+// responseMessage 响应中的助手消息或流式增量
+type responseMessage struct {
+	Role      string     `json:"role,omitempty"`
+	Content   *string    `json:"content,omitempty"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+}
+
+// usageReport 响应中的 token 用量
+type usageReport struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// modelList /v1/models 的响应
+type modelList struct {
+	Object string       `json:"object"`
+	Data   []modelEntry `json:"data"`
+}
+
+// modelEntry 模型列表中的一项
+type modelEntry struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// errorResponse OpenAI 格式的错误响应
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+// errorBody 错误详情
+type errorBody struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// toSchemaMessages 将请求消息转换为 Eino 格式
+func toSchemaMessages(messages []chatMessage) ([]*schema.Message, error) {
+	result := make([]*schema.Message, 0, len(messages))
+	for i, msg := range messages {
+		converted := &schema.Message{Content: msg.Content.text(), Name: msg.Name}
+		switch msg.Role {
+		case "system", "developer":
+			converted.Role = schema.System
+		case "user":
+			converted.Role = schema.User
+			if images := msg.Content.images(); len(images) > 0 {
+				converted.Content = ""
+				converted.MultiContent = toMultiContent(msg.Content.Parts)
+			}
+		case "assistant":
+			converted.Role = schema.Assistant
+			for _, call := range msg.ToolCalls {
+				converted.ToolCalls = append(converted.ToolCalls, schema.ToolCall{
+					ID:       call.ID,
+					Type:     "function",
+					Function: schema.FunctionCall{Name: call.Function.Name, Arguments: call.Function.Arguments},
+				})
+			}
+		case "tool":
+			converted.Role = schema.Tool
+			converted.ToolCallID = msg.ToolCallID
+			converted.ToolName = msg.Name
+		default:
+			return nil, fmt.Errorf("messages[%d] 的 role 无效: %q", i, msg.Role)
+		}
+		result = append(result, converted)
+	}
+	return result, nil
+}
+
+// toMultiContent 将多模态内容转换为 Eino 格式
+func toMultiContent(parts []contentPart) []schema.ChatMessagePart {
+	result := make([]schema.ChatMessagePart, 0, len(parts))
+	for _, part := range parts {
+		switch {
+		case part.Type == "text":
+			result = append(result, schema.ChatMessagePart{Type: schema.ChatMessagePartTypeText, Text: part.Text})
+		case part.Type == "image_url" && part.ImageURL != nil:
+			result = append(result, schema.ChatMessagePart{
+				Type: schema.ChatMessagePartTypeImageURL,
+				ImageURL: &schema.ChatMessageImageURL{
+					URL:    part.ImageURL.URL,
+					Detail: schema.ImageURLDetail(part.ImageURL.Detail),
+				},
+			})
+		}
+	}
+	return result
+}
+
+// modelOptions 将请求中的采样参数和工具转换为 Eino 调用选项
+func (r *chatRequest) modelOptions() ([]model.Option, error) {
+	var opts []model.Option
+	if r.Temperature != nil {
+		opts = append(opts, model.WithTemperature(*r.Temperature))
+	}
+	if r.TopP != nil {
+		opts = append(opts, model.WithTopP(*r.TopP))
+	}
+	if r.MaxCompletionTokens != nil {
+		opts = append(opts, model.WithMaxTokens(*r.MaxCompletionTokens))
+	} else if r.MaxTokens != nil {
+		opts = append(opts, model.WithMaxTokens(*r.MaxTokens))
+	}
+	if len(r.Stop) > 0 {
+		opts = append(opts, model.WithStop(r.Stop))
+	}
+
+	if len(r.Tools) > 0 {
+		infos := make([]*schema.ToolInfo, 0, len(r.Tools))
+		for _, tool := range r.Tools {
+			info := &schema.ToolInfo{Name: tool.Function.Name, Desc: tool.Function.Description}
+			if len(tool.Function.Parameters) > 0 {
+				params := &jsonschema.Schema{}
+				if err := json.Unmarshal(tool.Function.Parameters, params); err != nil {
+					return nil, fmt.Errorf("工具 %s 的参数定义无效: %w", tool.Function.Name, err)
+				}
+				info.ParamsOneOf = schema.NewParamsOneOfByJSONSchema(params)
+			}
+			infos = append(infos, info)
+		}
+		opts = append(opts, model.WithTools(infos))
+	}
+
+	// tool_choice 只支持字符串形式，指定具体工具时按 "required" 处理
+	if len(r.ToolChoice) > 0 {
+		var choice string
+		if err := json.Unmarshal(r.ToolChoice, &choice); err != nil {
+			choice = "required"
+		}
+		switch choice {
+		case "none":
+			opts = append(opts, model.WithToolChoice(schema.ToolChoiceForbidden))
+		case "required":
+			opts = append(opts, model.WithToolChoice(schema.ToolChoiceForced))
+		case "auto":
+			opts = append(opts, model.WithToolChoice(schema.ToolChoiceAllowed))
+		}
+	}
+	return opts, nil
+}
+
+// toResponseToolCalls 将 Eino 的工具调用转换为响应格式，withIndex 为 true 时保留流式分块序号
+func toResponseToolCalls(calls []schema.ToolCall, withIndex bool) []toolCall {
+	result := make([]toolCall, 0, len(calls))
+	for i, call := range calls {
+		converted := toolCall{ID: call.ID}
+		if call.ID != "" {
+			converted.Type = "function"
+		}
+		converted.Function.Name = call.Function.Name
+		converted.Function.Arguments = call.Function.Arguments
+		if withIndex {
+			index := i
+			if call.Index != nil {
+				index = *call.Index
+			}
+			converted.Index = &index
+		}
+		result = append(result, converted)
+	}
+	return result
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/storage"
+)
+
+const (
+	// maxTrackedConversations 内存中记录的对话数上限，超出后清空重新开始
+	maxTrackedConversations = 1000
+
+	// maxTitleRunes 会话标题的最大长度，取自第一条用户消息
+	maxTitleRunes = 30
+)
+
+// recorder 将经过服务的对话记录为会话
+// 接口本身无状态，客户端每次都会发送完整历史；若请求的历史与之前记录过的对话（含回复）一致，
+// 新消息追加到同一会话，否则创建新会话。对应关系只保存在内存中，重启服务后会开始新会话
+type recorder struct {
+	db *storage.Database
+
+	mu            sync.Mutex
+	conversations map[string]trackedConversation // 键为对话内容的指纹
+}
+
+// trackedConversation 已记录的对话在数据库中的位置
+type trackedConversation struct {
+	sessionID     string
+	lastMessageID string
+}
+
+// newRecorder 创建会话记录器
+func newRecorder(db *storage.Database) *recorder {
+	return &recorder{db: db, conversations: make(map[string]trackedConversation)}
+}
+
+// record 保存请求中尚未记录的消息和模型的回复
+func (r *recorder) record(ex *exchange) error {
+	history := ex.history
+	prefixes := prefixFingerprints(history)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 找到已记录的最长前缀，只保存之后的新消息
+	var session *models.Session
+	var parentID string
+	start := 0
+	for k := len(history) - 1; k > 0; k-- {
+		tracked, ok := r.conversations[prefixes[k]]
+		if !ok {
+			continue
+		}
+		existing, err := r.db.GetSession(tracked.sessionID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			session, parentID, start = existing, tracked.lastMessageID, k
+		}
+		break
+	}
+
+	if session == nil {
+		session = models.NewSession()
+		session.Title = sessionTitle(history)
+		session.SystemPrompt = ex.systemPrompt
+		session.Model = ex.model
+		if err := r.db.SaveSession(session); err != nil {
+			return err
+		}
+	}
+
+	reply := ex.reply
+	for _, msg := range append(history[start:], reply) {
+		msg.ParentID = parentID
+		if err := r.db.SaveMessage(session.ID, msg); err != nil {
+			return err
+		}
+		parentID = msg.ID
+	}
+
+	// 客户端会把正常完成的回复放进下一次请求的历史
+	if reply.Status == models.StatusComplete {
+		if len(r.conversations) >= maxTrackedConversations {
+			r.conversations = make(map[string]trackedConversation)
+		}
+		key := chainFingerprint(prefixes[len(history)], reply)
+		r.conversations[key] = trackedConversation{sessionID: session.ID, lastMessageID: reply.ID}
+	}
+	return nil
+}
+
+// convertHistory 将请求消息转换为内部格式，系统消息合并为会话的系统提示词
+// 需在调用模型前转换：消息的时间和 ID 在此生成，存储按时间排序，历史消息须早于之后生成的回复
+func convertHistory(messages []chatMessage) ([]*models.Message, string) {
+	var history []*models.Message
+	var systemPrompts []string
+	for _, msg := range messages {
+		var converted *models.Message
+		switch msg.Role {
+		case "system", "developer":
+			systemPrompts = append(systemPrompts, msg.Content.text())
+			continue
+		case "user":
+			converted = models.NewMessage(models.RoleUser, msg.Content.text())
+			converted.Attachments = imageAttachments(msg.Content.images())
+		case "assistant":
+			converted = models.NewMessage(models.RoleAssistant, msg.Content.text())
+			for _, call := range msg.ToolCalls {
+				converted.ToolCalls = append(converted.ToolCalls, models.ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+			}
+		case "tool":
+			converted = models.NewMessage(models.RoleTool, msg.Content.text())
+			converted.ToolCallID = msg.ToolCallID
+			converted.ToolName = msg.Name
+		default:
+			continue
+		}
+		history = append(history, converted)
+	}
+	return history, strings.Join(systemPrompts, "\n\n")
+}
+
+// prefixFingerprints 返回历史每个前缀的指纹，第 k 项对应前 k 条消息
+func prefixFingerprints(history []*models.Message) []string {
+	prefixes := make([]string, len(history)+1)
+	for i, msg := range history {
+		prefixes[i+1] = chainFingerprint(prefixes[i], msg)
+	}
+	return prefixes
+}
+
+// chainFingerprint 在前缀指纹的基础上加入一条消息，只使用客户端会原样回传的字段
+func chainFingerprint(prefix string, msg *models.Message) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00", prefix, msg.Role, msg.Content, msg.ToolCallID)
+	for _, call := range msg.ToolCalls {
+		fmt.Fprintf(h, "%s\x00%s\x00", call.Name, call.Arguments)
+	}
+	for _, a := range msg.Attachments {
+		fmt.Fprintf(h, "%x\x00", sha256.Sum256(a.Data))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// sessionTitle 取第一条用户消息的首行作为会话标题
+func sessionTitle(history []*models.Message) string {
+	for _, msg := range history {
+		if msg.Role != models.RoleUser {
+			continue
+		}
+		line, _, _ := strings.Cut(strings.TrimSpace(msg.Content), "\n")
+		if runes := []rune(line); len(runes) > maxTitleRunes {
+			line = string(runes[:maxTitleRunes]) + "…"
+		}
+		if line != "" {
+			return line
+		}
+	}
+	return models.DefaultSessionTitle
+}
+
+// imageAttachments 将 data URL 形式的图片保存为附件，http(s) 链接的图片不下载
+func imageAttachments(urls []string) []*models.Attachment {
+	var attachments []*models.Attachment
+	for i, url := range urls {
+		header, encoded, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+		if !ok || !strings.HasPrefix(url, "data:") || !strings.HasSuffix(header, ";base64") {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		mimeType := strings.TrimSuffix(header, ";base64")
+		name := fmt.Sprintf("image-%d.%s", i+1, strings.TrimPrefix(mimeType, "image/"))
+		attachments = append(attachments, models.NewAttachment(name, mimeType, data))
+	}
+	return attachments
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+func TestRecorderSavesHistoryBeforeReply(t *testing.T) {
+	ts, db := newTestServer(t, Options{SaveSessions: true})
+
+	first := map[string]any{
+		"model": "echo",
+		"messages": []map[string]any{
+			{"role": "system", "content": "你是助手"},
+			{"role": "user", "content": "你好"},
+		},
+	}
+	var completion chatCompletion
+	if code := doJSON(t, ts, http.MethodPost, "/v1/chat/completions", first, &completion); code != http.StatusOK {
+		t.Fatalf("状态码 %d", code)
+	}
+	reply := *completion.Choices[0].Message.Content
+
+	// 第二次请求带上之前的回复，以流式返回，应追加到同一会话
+	second := map[string]any{
+		"model":  "echo",
+		"stream": true,
+		"messages": []map[string]any{
+			{"role": "system", "content": "你是助手"},
+			{"role": "user", "content": "你好"},
+			{"role": "assistant", "content": reply},
+			{"role": "user", "content": "再见"},
+		},
+	}
+	if code := doJSON(t, ts, http.MethodPost, "/v1/chat/completions", second, nil); code != http.StatusOK {
+		t.Fatalf("状态码 %d", code)
+	}
+
+	sessions, err := db.ListSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("会话数 = %d，期望 1", len(sessions))
+	}
+	if sessions[0].SystemPrompt != "你是助手" {
+		t.Errorf("系统提示词 = %q", sessions[0].SystemPrompt)
+	}
+
+	want := []struct {
+		role    models.Role
+		content string
+	}{
+		{models.RoleUser, "你好"},
+		{models.RoleAssistant, "回声: 你好"},
+		{models.RoleUser, "再见"},
+		{models.RoleAssistant, "回声: 再见"},
+	}
+	messages, err := db.GetMessages(sessions[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("消息数 = %d，期望 %d", len(messages), len(want))
+	}
+	for i, msg := range messages {
+		if msg.Role != want[i].role || msg.Content != want[i].content {
+			t.Errorf("第 %d 条消息 = %s %q，期望 %s %q", i, msg.Role, msg.Content, want[i].role, want[i].content)
+		}
+		if i > 0 {
+			if msg.ParentID != messages[i-1].ID {
+				t.Errorf("第 %d 条消息的父消息不是前一条", i)
+			}
+			if msg.Timestamp.Before(messages[i-1].Timestamp) {
+				t.Errorf("第 %d 条消息的时间早于前一条", i)
+			}
+		}
+	}
+
+	path, err := db.GetActivePath(sessions[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != len(want) || path[len(path)-1].Content != "回声: 再见" {
+		t.Errorf("当前分支应以最后的回复结束，共 %d 条", len(path))
+	}
+}
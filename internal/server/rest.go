@@ -0,0 +1,314 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/ai"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// page 分页响应，Total 未知时（如搜索）用 HasMore 表示是否还有下一页
+type page struct {
+	Data    any  `json:"data"`
+	Limit   int  `json:"limit"`
+	Offset  int  `json:"offset"`
+	Total   *int `json:"total,omitempty"`
+	HasMore bool `json:"has_more"`
+}
+
+// createSessionRequest 创建会话的请求体
+type createSessionRequest struct {
+	Title        string   `json:"title"`
+	SystemPrompt string   `json:"system_prompt"`
+	Model        string   `json:"model"`
+	Temperature  *float32 `json:"temperature"`
+	TopP         *float32 `json:"top_p"`
+	MaxTokens    *int     `json:"max_tokens"`
+}
+
+// appendMessageRequest 追加消息的请求体
+type appendMessageRequest struct {
+	Role     models.Role `json:"role"`
+	Content  string      `json:"content"`
+	ParentID string      `json:"parent_id"` // 为空时接在当前分支末尾
+	Reply    bool        `json:"reply"`     // 为 true 时调用模型生成回复，成功后与消息一起保存，只能用于 user 消息
+
+	AllowTools bool `json:"allow_tools"` // 生成回复时允许模型调用工具，默认拒绝（接口无法逐个确认）
+}
+
+// appendMessageResponse 请求生成回复时追加消息的响应
+type appendMessageResponse struct {
+	Message *models.Message   `json:"message"`
+	Steps   []*models.Message `json:"steps,omitempty"` // 工具调用过程中的中间消息
+	Reply   *models.Message   `json:"reply"`
+}
+
+// registerREST 注册会话和消息的 REST 接口
+func (s *Server) registerREST() {
+	s.mux.HandleFunc("GET /api/sessions", s.handleListSessions)
+	s.mux.HandleFunc("POST /api/sessions", s.handleCreateSession)
+	s.mux.HandleFunc("GET /api/sessions/{id}", s.handleGetSession)
+	s.mux.HandleFunc("DELETE /api/sessions/{id}", s.handleDeleteSession)
+	s.mux.HandleFunc("GET /api/sessions/{id}/messages", s.handleListMessages)
+	s.mux.HandleFunc("POST /api/sessions/{id}/messages", s.handleAppendMessage)
+	s.mux.HandleFunc("GET /api/search", s.handleSearch)
+}
+
+// handleListSessions 分页列出会话，按更新时间倒序
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sessions, total, err := s.db.ListSessionsPage(limit, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, page{Data: sessions, Limit: limit, Offset: offset, Total: &total, HasMore: offset+len(sessions) < total})
+}
+
+// handleCreateSession 创建会话
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var req createSessionRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	session := models.NewSession()
+	if title := strings.TrimSpace(req.Title); title != "" {
+		session.Title = title
+	}
+	session.SystemPrompt = req.SystemPrompt
+	session.Model = req.Model
+	session.Temperature = req.Temperature
+	session.TopP = req.TopP
+	session.MaxTokens = req.MaxTokens
+
+	if err := s.db.SaveSession(session); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, session)
+}
+
+// handleGetSession 获取会话
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.findSession(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+// handleDeleteSession 删除会话及其消息
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.findSession(w, r)
+	if !ok {
+		return
+	}
+	if err := s.db.DeleteSession(session.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListMessages 分页列出会话当前分支的消息，all=true 时列出所有分支的消息（按时间排序）
+func (s *Server) handleListMessages(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.findSession(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, err := pagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var messages []*models.Message
+	if r.URL.Query().Get("all") == "true" {
+		messages, err = s.db.GetMessages(session.ID)
+	} else {
+		messages, err = s.db.GetActivePath(session.ID)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	total := len(messages)
+	start := min(offset, total)
+	end := min(start+limit, total)
+	writeJSON(w, http.StatusOK, page{Data: messages[start:end], Limit: limit, Offset: offset, Total: &total, HasMore: end < total})
+}
+
+// handleAppendMessage 向会话追加一条消息，默认只保存；reply 为 true 时通过 AI 服务生成回复，
+// 与图形界面和命令行一样按上下文窗口组装历史、使用摘要和知识库，成功后消息与回复一起保存，
+// 失败时什么都不保存，客户端可以直接重试
+func (s *Server) handleAppendMessage(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.findSession(w, r)
+	if !ok {
+		return
+	}
+
+	var req appendMessageRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Role != models.RoleUser && req.Role != models.RoleAssistant {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("role 只能是 user 或 assistant: %q", req.Role))
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeError(w, http.StatusBadRequest, "content 不能为空")
+		return
+	}
+	if req.Reply && req.Role != models.RoleUser {
+		writeError(w, http.StatusBadRequest, "只有 user 消息可以请求生成回复")
+		return
+	}
+
+	// 新消息接在 parent_id 或当前分支末尾之后，history 为从根到父消息的分支
+	var history []*models.Message
+	var err error
+	if req.ParentID == "" {
+		history, err = s.db.GetActivePath(session.ID)
+	} else {
+		history, err = s.db.GetPath(session.ID, req.ParentID)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if req.ParentID != "" && len(history) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("parent_id 不是该会话中的消息: %s", req.ParentID))
+		return
+	}
+
+	msg := models.NewMessage(req.Role, req.Content)
+	if len(history) > 0 {
+		msg.ParentID = history[len(history)-1].ID
+	}
+
+	if !req.Reply {
+		if err := s.db.SaveMessage(session.ID, msg); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, msg)
+		return
+	}
+
+	messages, err := s.generateReply(r.Context(), session, history, msg, req.AllowTools)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("模型调用失败: %v", err))
+		return
+	}
+	if err := s.db.SaveMessages(session.ID, messages); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, appendMessageResponse{
+		Message: msg,
+		Steps:   messages[1 : len(messages)-1],
+		Reply:   messages[len(messages)-1],
+	})
+}
+
+// generateReply 在 history 之后发送 msg 并生成回复，返回新产生的消息：msg、工具调用过程和最终回复
+// 出错或被取消时不返回已生成的部分内容
+func (s *Server) generateReply(ctx context.Context, session *models.Session, history []*models.Message, msg *models.Message, allowTools bool) ([]*models.Message, error) {
+	conv := ai.NewConversation(session, history)
+	_, err := s.ai.StreamChat(ctx, conv, msg, ai.StreamHandler{
+		Approve: func(ctx context.Context, call models.ToolCall) bool { return allowTools },
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conv.History()[len(history):], nil
+}
+
+// handleSearch 在会话标题和消息内容中搜索，命中的关键词以 ** 包裹
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "缺少搜索关键词 q")
+		return
+	}
+	limit, offset, err := pagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 多取一条判断是否还有下一页
+	results, err := s.db.Search(query, limit+1, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	hasMore := len(results) > limit
+	results = results[:min(len(results), limit)]
+
+	marks := strings.NewReplacer(models.SnippetMarkStart, "**", models.SnippetMarkEnd, "**")
+	for _, result := range results {
+		result.Snippet = marks.Replace(result.Snippet)
+	}
+	writeJSON(w, http.StatusOK, page{Data: results, Limit: limit, Offset: offset, HasMore: hasMore})
+}
+
+// findSession 按路径中的 id 查找会话，不存在时写入 404
+func (s *Server) findSession(w http.ResponseWriter, r *http.Request) (*models.Session, bool) {
+	session, err := s.db.GetSession(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	if session == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("会话不存在: %s", r.PathValue("id")))
+		return nil, false
+	}
+	return session, true
+}
+
+// pagination 读取 limit 和 offset 参数，limit 默认 50、最大 200
+func pagination(r *http.Request) (limit, offset int, err error) {
+	limit, offset = defaultPageLimit, 0
+	query := r.URL.Query()
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			return 0, 0, fmt.Errorf("limit 必须是正整数: %q", v)
+		}
+		limit = min(limit, maxPageLimit)
+	}
+	if v := query.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset 必须是非负整数: %q", v)
+		}
+	}
+	return limit, offset, nil
+}
+
+// decodeBody 解析 JSON 请求体，失败时写入 400
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+		return false
+	}
+	return true
+}
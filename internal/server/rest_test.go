@@ -0,0 +1,349 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// testPage 分页响应，Data 按接口再解析
+type testPage struct {
+	Data    json.RawMessage `json:"data"`
+	Limit   int             `json:"limit"`
+	Offset  int             `json:"offset"`
+	Total   *int            `json:"total"`
+	HasMore bool            `json:"has_more"`
+}
+
+func TestRESTRequiresToken(t *testing.T) {
+	ts, _ := newTestServer(t, Options{APITokens: []string{testToken}})
+
+	for _, path := range []string{"/api/sessions", "/v1/models"} {
+		for name, header := range map[string]string{
+			"缺少令牌": "",
+			"错误令牌": "Bearer wrong-token",
+			"格式错误": testToken,
+		} {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+			resp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("%s %s: 状态码 %d，期望 401", path, name, resp.StatusCode)
+			}
+			if resp.Header.Get("WWW-Authenticate") == "" {
+				t.Errorf("%s %s: 缺少 WWW-Authenticate 响应头", path, name)
+			}
+		}
+
+		if code := doJSON(t, ts, http.MethodGet, path, nil, nil); code != http.StatusOK {
+			t.Errorf("%s 正确令牌: 状态码 %d", path, code)
+		}
+	}
+}
+
+func TestRESTListSessionsPagination(t *testing.T) {
+	ts, _ := newTestServer(t, Options{APITokens: []string{testToken}})
+
+	for i := range 5 {
+		if code := doJSON(t, ts, http.MethodPost, "/api/sessions", map[string]any{"title": fmt.Sprintf("会话 %d", i)}, nil); code != http.StatusCreated {
+			t.Fatalf("创建会话: 状态码 %d", code)
+		}
+	}
+
+	tests := []struct {
+		query   string
+		count   int
+		hasMore bool
+	}{
+		{"?limit=2", 2, true},
+		{"?limit=2&offset=2", 2, true},
+		{"?limit=2&offset=4", 1, false},
+		{"?offset=10", 0, false},
+		{"", 5, false},
+	}
+	for _, tt := range tests {
+		var p testPage
+		if code := doJSON(t, ts, http.MethodGet, "/api/sessions"+tt.query, nil, &p); code != http.StatusOK {
+			t.Fatalf("%s: 状态码 %d", tt.query, code)
+		}
+		var sessions []*models.Session
+		if err := json.Unmarshal(p.Data, &sessions); err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != tt.count || p.HasMore != tt.hasMore || p.Total == nil || *p.Total != 5 {
+			t.Errorf("%s: %d 个会话，has_more=%v，total=%v；期望 %d 个，has_more=%v，total=5", tt.query, len(sessions), p.HasMore, p.Total, tt.count, tt.hasMore)
+		}
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=abc", "?offset=-1"} {
+		if code := doJSON(t, ts, http.MethodGet, "/api/sessions"+query, nil, nil); code != http.StatusBadRequest {
+			t.Errorf("%s: 状态码 %d，期望 400", query, code)
+		}
+	}
+}
+
+func TestRESTSessionLifecycle(t *testing.T) {
+	ts, _ := newTestServer(t, Options{APITokens: []string{testToken}})
+
+	var created models.Session
+	body := map[string]any{"title": "周报", "system_prompt": "简洁回答", "temperature": 0.2}
+	if code := doJSON(t, ts, http.MethodPost, "/api/sessions", body, &created); code != http.StatusCreated {
+		t.Fatalf("创建会话: 状态码 %d", code)
+	}
+	if created.ID == "" || created.Title != "周报" || created.SystemPrompt != "简洁回答" || created.Temperature == nil {
+		t.Fatalf("创建的会话不正确: %+v", created)
+	}
+	path := "/api/sessions/" + created.ID
+
+	var fetched models.Session
+	if code := doJSON(t, ts, http.MethodGet, path, nil, &fetched); code != http.StatusOK {
+		t.Fatalf("获取会话: 状态码 %d", code)
+	}
+	if fetched.Title != "周报" || fetched.SystemPrompt != "简洁回答" {
+		t.Errorf("获取的会话不正确: %+v", fetched)
+	}
+
+	if code := doJSON(t, ts, http.MethodDelete, path, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("删除会话: 状态码 %d", code)
+	}
+	if code := doJSON(t, ts, http.MethodGet, path, nil, nil); code != http.StatusNotFound {
+		t.Errorf("删除后获取: 状态码 %d，期望 404", code)
+	}
+	if code := doJSON(t, ts, http.MethodDelete, path, nil, nil); code != http.StatusNotFound {
+		t.Errorf("重复删除: 状态码 %d，期望 404", code)
+	}
+}
+
+func TestRESTMessages(t *testing.T) {
+	ts, _ := newTestServer(t, Options{APITokens: []string{testToken}})
+
+	var session models.Session
+	if code := doJSON(t, ts, http.MethodPost, "/api/sessions", map[string]any{"title": "饮料"}, &session); code != http.StatusCreated {
+		t.Fatalf("创建会话: 状态码 %d", code)
+	}
+	messagesPath := "/api/sessions/" + session.ID + "/messages"
+
+	// 追加用户消息并生成回复
+	var appended appendMessageResponse
+	body := map[string]any{"role": "user", "content": "推荐一款橘子汽水", "reply": true}
+	if code := doJSON(t, ts, http.MethodPost, messagesPath, body, &appended); code != http.StatusCreated {
+		t.Fatalf("追加消息: 状态码 %d", code)
+	}
+	question, reply := appended.Message, appended.Reply
+	if question == nil || reply == nil {
+		t.Fatalf("响应缺少消息或回复: %+v", appended)
+	}
+	if reply.Role != models.RoleAssistant || reply.Content != "回声: 推荐一款橘子汽水" || reply.ParentID != question.ID {
+		t.Errorf("回复不正确: %+v", reply)
+	}
+	if reply.Usage == nil || reply.Usage.TotalTokens() != 15 {
+		t.Errorf("回复的用量不正确: %+v", reply.Usage)
+	}
+
+	// 只保存、不生成回复的消息：为同一问题追加另一个回答，成为新的当前分支
+	var alternative models.Message
+	body = map[string]any{"role": "assistant", "content": "试试柠檬汽水", "parent_id": question.ID}
+	if code := doJSON(t, ts, http.MethodPost, messagesPath, body, &alternative); code != http.StatusCreated {
+		t.Fatalf("追加消息: 状态码 %d", code)
+	}
+	if alternative.ParentID != question.ID {
+		t.Errorf("parent_id = %q，期望 %q", alternative.ParentID, question.ID)
+	}
+
+	for name, body := range map[string]map[string]any{
+		"无效角色":     {"role": "system", "content": "x"},
+		"空内容":      {"role": "user", "content": " "},
+		"助手消息请求回复": {"role": "assistant", "content": "x", "reply": true},
+		"未知父消息":    {"role": "user", "content": "x", "parent_id": "missing"},
+	} {
+		if code := doJSON(t, ts, http.MethodPost, messagesPath, body, nil); code != http.StatusBadRequest {
+			t.Errorf("%s: 状态码 %d，期望 400", name, code)
+		}
+	}
+
+	listMessages := func(query string) ([]*models.Message, testPage) {
+		t.Helper()
+		var p testPage
+		if code := doJSON(t, ts, http.MethodGet, messagesPath+query, nil, &p); code != http.StatusOK {
+			t.Fatalf("列出消息 %s: 状态码 %d", query, code)
+		}
+		var messages []*models.Message
+		if err := json.Unmarshal(p.Data, &messages); err != nil {
+			t.Fatal(err)
+		}
+		return messages, p
+	}
+	contents := func(messages []*models.Message) string {
+		parts := make([]string, len(messages))
+		for i, msg := range messages {
+			parts[i] = msg.Content
+		}
+		return strings.Join(parts, " | ")
+	}
+
+	// 默认只列出当前分支
+	active, _ := listMessages("")
+	if got, want := contents(active), "推荐一款橘子汽水 | 试试柠檬汽水"; got != want {
+		t.Errorf("当前分支 = %s，期望 %s", got, want)
+	}
+
+	// all=true 按时间列出所有分支
+	all, _ := listMessages("?all=true")
+	if got, want := contents(all), "推荐一款橘子汽水 | 回声: 推荐一款橘子汽水 | 试试柠檬汽水"; got != want {
+		t.Errorf("所有消息 = %s，期望 %s", got, want)
+	}
+
+	paged, p := listMessages("?all=true&limit=2&offset=1")
+	if got, want := contents(paged), "回声: 推荐一款橘子汽水 | 试试柠檬汽水"; got != want || p.HasMore || *p.Total != 3 {
+		t.Errorf("分页 = %s（has_more=%v，total=%d），期望 %s", got, p.HasMore, *p.Total, want)
+	}
+
+	if code := doJSON(t, ts, http.MethodGet, "/api/sessions/missing/messages", nil, nil); code != http.StatusNotFound {
+		t.Errorf("不存在的会话: 状态码 %d，期望 404", code)
+	}
+}
+
+func TestRESTSearch(t *testing.T) {
+	ts, _ := newTestServer(t, Options{APITokens: []string{testToken}})
+
+	for i, content := range []string{"今天喝了橘子汽水", "橘子汽水配薯条", "只喝白开水"} {
+		var session models.Session
+		if code := doJSON(t, ts, http.MethodPost, "/api/sessions", map[string]any{"title": fmt.Sprintf("记录 %d", i)}, &session); code != http.StatusCreated {
+			t.Fatalf("创建会话: 状态码 %d", code)
+		}
+		body := map[string]any{"role": "user", "content": content}
+		if code := doJSON(t, ts, http.MethodPost, "/api/sessions/"+session.ID+"/messages", body, nil); code != http.StatusCreated {
+			t.Fatalf("追加消息: 状态码 %d", code)
+		}
+	}
+
+	search := func(query string) ([]*models.SearchResult, testPage) {
+		t.Helper()
+		var p testPage
+		if code := doJSON(t, ts, http.MethodGet, "/api/search"+query, nil, &p); code != http.StatusOK {
+			t.Fatalf("搜索 %s: 状态码 %d", query, code)
+		}
+		var results []*models.SearchResult
+		if err := json.Unmarshal(p.Data, &results); err != nil {
+			t.Fatal(err)
+		}
+		return results, p
+	}
+
+	results, p := search("?q=橘子汽水")
+	if len(results) != 2 || p.HasMore {
+		t.Fatalf("命中 %d 条（has_more=%v），期望 2 条", len(results), p.HasMore)
+	}
+	for _, result := range results {
+		if !strings.Contains(result.Snippet, "**橘子汽水**") || result.MessageID == "" {
+			t.Errorf("搜索结果不正确: %+v", result)
+		}
+	}
+
+	results, p = search("?q=橘子汽水&limit=1")
+	if len(results) != 1 || !p.HasMore {
+		t.Errorf("分页: 命中 %d 条（has_more=%v），期望 1 条且还有下一页", len(results), p.HasMore)
+	}
+
+	if results, _ := search("?q=可乐"); len(results) != 0 {
+		t.Errorf("无匹配时命中 %d 条", len(results))
+	}
+	if code := doJSON(t, ts, http.MethodGet, "/api/search", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("缺少 q: 状态码 %d，期望 400", code)
+	}
+}
+
+func TestRESTReplyFailureSavesNothing(t *testing.T) {
+	ts, db := newTestServer(t, Options{APITokens: []string{testToken}})
+
+	var session models.Session
+	if code := doJSON(t, ts, http.MethodPost, "/api/sessions", map[string]any{"title": "重试"}, &session); code != http.StatusCreated {
+		t.Fatalf("创建会话: 状态码 %d", code)
+	}
+	messagesPath := "/api/sessions/" + session.ID + "/messages"
+
+	// 模型调用失败：返回 502，消息和回复都不保存，重试不会在树中留下重复的消息
+	body := map[string]any{"role": "user", "content": failPrompt, "reply": true}
+	for range 2 {
+		if code := doJSON(t, ts, http.MethodPost, messagesPath, body, nil); code != http.StatusBadGateway {
+			t.Fatalf("模型失败: 状态码 %d，期望 502", code)
+		}
+	}
+	if messages, err := db.GetMessages(session.ID); err != nil || len(messages) != 0 {
+		t.Fatalf("失败后保存了 %d 条消息（err=%v）", len(messages), err)
+	}
+
+	// 重试成功后消息和回复一起保存，回复成为当前分支的末端
+	var appended appendMessageResponse
+	body["content"] = "再试一次"
+	if code := doJSON(t, ts, http.MethodPost, messagesPath, body, &appended); code != http.StatusCreated {
+		t.Fatalf("重试: 状态码 %d", code)
+	}
+	path, err := db.GetActivePath(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 2 || path[0].ID != appended.Message.ID || path[1].ID != appended.Reply.ID {
+		t.Errorf("当前分支 = %v，期望消息和回复", path)
+	}
+}
+
+func TestRESTReplyOnBranch(t *testing.T) {
+	ts, _ := newTestServer(t, Options{APITokens: []string{testToken}})
+
+	var session models.Session
+	if code := doJSON(t, ts, http.MethodPost, "/api/sessions", map[string]any{"title": "分支"}, &session); code != http.StatusCreated {
+		t.Fatalf("创建会话: 状态码 %d", code)
+	}
+	messagesPath := "/api/sessions/" + session.ID + "/messages"
+
+	var first appendMessageResponse
+	if code := doJSON(t, ts, http.MethodPost, messagesPath, map[string]any{"role": "user", "content": "第一问", "reply": true}, &first); code != http.StatusCreated {
+		t.Fatalf("追加消息: 状态码 %d", code)
+	}
+	if code := doJSON(t, ts, http.MethodPost, messagesPath, map[string]any{"role": "user", "content": "第二问", "reply": true}, nil); code != http.StatusCreated {
+		t.Fatalf("追加消息: 状态码 %d", code)
+	}
+
+	// 在第一轮回复之后另开分支：模型只看到从根到父消息的分支，不包含第二问
+	var branch appendMessageResponse
+	body := map[string]any{"role": "user", "content": "换个问题", "reply": true, "parent_id": first.Reply.ID}
+	if code := doJSON(t, ts, http.MethodPost, messagesPath, body, &branch); code != http.StatusCreated {
+		t.Fatalf("追加消息: 状态码 %d", code)
+	}
+	if branch.Message.ParentID != first.Reply.ID || branch.Reply.ParentID != branch.Message.ID {
+		t.Errorf("分支消息的父消息不正确: %+v / %+v", branch.Message, branch.Reply)
+	}
+	if branch.Reply.Content != "回声: 换个问题" {
+		t.Errorf("回复 = %q", branch.Reply.Content)
+	}
+
+	var p testPage
+	if code := doJSON(t, ts, http.MethodGet, messagesPath, nil, &p); code != http.StatusOK {
+		t.Fatalf("列出消息: 状态码 %d", code)
+	}
+	var active []*models.Message
+	if err := json.Unmarshal(p.Data, &active); err != nil {
+		t.Fatal(err)
+	}
+	var contents []string
+	for _, msg := range active {
+		contents = append(contents, msg.Content)
+	}
+	if got, want := strings.Join(contents, " | "), "第一问 | 回声: 第一问 | 换个问题 | 回声: 换个问题"; got != want {
+		t.Errorf("当前分支 = %s，期望 %s", got, want)
+	}
+}
@@ -0,0 +1,160 @@
+// Package server 实现 gochat serve：在本地提供 OpenAI 兼容的 HTTP 接口，
+// 请求转发给配置文件中的模型，可选地把每次对话记录为 gochat.db 中的会话；
+// 配置了访问令牌时还提供读写会话和消息的 REST 接口
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/wangle201210/gochat/internal/config"
+	"github.com/wangle201210/gochat/internal/service/ai"
+	"github.com/wangle201210/gochat/internal/service/provider"
+	"github.com/wangle201210/gochat/internal/storage"
+)
+
+const (
+	// DefaultAddr 默认监听地址，只允许本机访问
+	DefaultAddr = "127.0.0.1:8080"
+
+	// shutdownTimeout 停止服务时等待进行中的请求结束的时间
+	shutdownTimeout = 10 * time.Second
+)
+
+// chatModel 可通过接口调用的模型
+type chatModel struct {
+	id    string // 模型名称，即请求中的 model 字段
+	owner string // provider 名称
+	model model.ToolCallingChatModel
+}
+
+// Options 服务选项
+type Options struct {
+	DB           *storage.Database // 记录会话和 REST 接口使用的数据库
+	SaveSessions bool              // 把经过 OpenAI 兼容接口的对话记录为会话
+	APITokens    []string          // 访问令牌，为空时不启用 REST 接口，OpenAI 兼容接口也不校验令牌
+	AI           *ai.Service       // REST 接口生成回复使用的 AI 服务，启用 REST 接口时必须设置
+}
+
+// Server OpenAI 兼容的 HTTP 服务
+type Server struct {
+	models   []*chatModel // 第一个为默认模型
+	recorder *recorder    // 为空时不记录会话
+	db       *storage.Database
+	ai       *ai.Service
+	tokens   []string
+	created  time.Time
+	mux      *http.ServeMux
+}
+
+// New 按配置创建主模型和助手模型并注册接口
+func New(ctx context.Context, cfg *config.Config, opts Options) (*Server, error) {
+	if (opts.SaveSessions || len(opts.APITokens) > 0) && opts.DB == nil {
+		return nil, errors.New("记录会话和 REST 接口需要数据库")
+	}
+	if len(opts.APITokens) > 0 && opts.AI == nil {
+		return nil, errors.New("REST 接口需要 AI 服务")
+	}
+	s := &Server{db: opts.DB, ai: opts.AI, tokens: opts.APITokens, created: time.Now(), mux: http.NewServeMux()}
+
+	for _, modelCfg := range []*config.ModelConfig{&cfg.AI.ModelConfig, &cfg.Assistant.ModelConfig} {
+		if modelCfg.Model == "" || s.findModel(modelCfg.Model) != nil {
+			continue
+		}
+		chat, err := provider.NewChatModel(ctx, modelCfg)
+		if err != nil {
+			return nil, fmt.Errorf("初始化模型 %s 失败: %w", modelCfg.Model, err)
+		}
+		owner := modelCfg.Provider
+		if owner == "" {
+			owner = "openai"
+		}
+		s.models = append(s.models, &chatModel{id: modelCfg.Model, owner: owner, model: chat})
+	}
+	if len(s.models) == 0 {
+		return nil, errors.New("配置文件中没有可用的模型")
+	}
+
+	if opts.SaveSessions {
+		s.recorder = newRecorder(opts.DB)
+	}
+
+	s.mux.HandleFunc("GET /v1/models", s.handleModels)
+	s.mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
+	if len(s.tokens) > 0 {
+		s.registerREST()
+	}
+	return s, nil
+}
+
+// Handler 返回服务的 HTTP 处理器，配置了访问令牌时所有接口都需要认证
+func (s *Server) Handler() http.Handler {
+	if len(s.tokens) == 0 {
+		return s.mux
+	}
+	return s.requireToken(s.mux)
+}
+
+// RESTEnabled 是否提供 REST 接口
+func (s *Server) RESTEnabled() bool {
+	return len(s.tokens) > 0
+}
+
+// ListenAndServe 在 addr 上提供服务，ctx 取消后等待进行中的请求结束再返回
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("监听 %s 失败: %w", addr, err)
+	}
+
+	httpServer := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.Serve(listener)
+	}()
+	log.Printf("OpenAI 兼容接口已启动: http://%s/v1", listener.Addr())
+	if s.RESTEnabled() {
+		log.Printf("REST 接口已启动: http://%s/api", listener.Addr())
+	}
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("HTTP 服务异常退出: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("停止 HTTP 服务失败: %w", err)
+	}
+	return nil
+}
+
+// findModel 按名称查找已配置的模型
+func (s *Server) findModel(id string) *chatModel {
+	for _, m := range s.models {
+		if m.id == id {
+			return m
+		}
+	}
+	return nil
+}
+
+// resolveModel 返回处理请求的模型：已配置的模型直接使用，
+// 其他名称交给默认模型的 provider 按该名称调用，为空时使用默认模型
+func (s *Server) resolveModel(id string) (*chatModel, []model.Option) {
+	if m := s.findModel(id); m != nil {
+		return m, nil
+	}
+	if id == "" {
+		return s.models[0], nil
+	}
+	return &chatModel{id: id, owner: s.models[0].owner, model: s.models[0].model}, []model.Option{model.WithModel(id)}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/wangle201210/gochat/internal/config"
+	"github.com/wangle201210/gochat/internal/service/ai"
+	"github.com/wangle201210/gochat/internal/service/provider"
+	"github.com/wangle201210/gochat/internal/storage"
+)
+
+// testToken 测试服务使用的访问令牌
+const testToken = "test-token"
+
+func init() {
+	provider.Register("fake", provider.Provider{New: func(ctx context.Context, cfg *config.ModelConfig) (model.ToolCallingChatModel, error) {
+		return echoModel{}, nil
+	}})
+}
+
+// failPrompt 让 echoModel 返回错误的消息内容
+const failPrompt = "请返回错误"
+
+// echoModel 把最后一条消息加上 "回声: " 前缀作为回复的模型，最后一条消息为 failPrompt 时返回错误
+type echoModel struct{}
+
+func (echoModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	if input[len(input)-1].Content == failPrompt {
+		return nil, errors.New("模型不可用")
+	}
+	reply := schema.AssistantMessage("回声: "+input[len(input)-1].Content, nil)
+	reply.ResponseMeta = &schema.ResponseMeta{
+		FinishReason: "stop",
+		Usage:        &schema.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}
+	return reply, nil
+}
+
+func (m echoModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	reply, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	content := []rune(reply.Content)
+	half := len(content) / 2
+	first := schema.AssistantMessage(string(content[:half]), nil)
+	last := schema.AssistantMessage(string(content[half:]), nil)
+	last.ResponseMeta = reply.ResponseMeta
+	return schema.StreamReaderFromArray([]*schema.Message{first, last}), nil
+}
+
+func (m echoModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// newTestServer 使用临时数据库和回声模型启动服务
+func newTestServer(t *testing.T, opts Options) (*httptest.Server, *storage.Database) {
+	t.Helper()
+
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "gochat.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := config.DefaultConfig()
+	cfg.AI.ModelConfig = config.ModelConfig{Provider: "fake", Model: "echo"}
+	cfg.Assistant.ModelConfig = config.ModelConfig{}
+
+	opts.DB = db
+	if opts.AI == nil {
+		if opts.AI, err = ai.NewService(&cfg.AI); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := New(context.Background(), cfg, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return ts, db
+}
+
+// doJSON 发送请求并把 JSON 响应解析到 out（out 为空时不解析），返回状态码
+func doJSON(t *testing.T, ts *httptest.Server, method, path string, body any, out any) int {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, ts.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != nil && len(bytes.TrimSpace(data)) > 0 && !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("%s %s: 解析响应失败: %v\n%s", method, path, err, data)
+		}
+	}
+	return resp.StatusCode
+}
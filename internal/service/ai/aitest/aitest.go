@@ -0,0 +1,87 @@
+// Package aitest 提供测试 AI 服务及其调用方使用的模型
+package aitest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// FirstChunk BlockingModel 立即发出的第一段回复
+	FirstChunk = "第一段"
+	// LastChunk BlockingModel 放行后发出的剩余回复
+	LastChunk = "，第二段"
+)
+
+// BlockingModel 流式发出第一段回复后阻塞，直到 Release 被调用或 ctx 取消；
+// 放行后的调用不再阻塞。记录每次调用的输入，方法可在多个 goroutine 中并发调用
+type BlockingModel struct {
+	release     chan struct{}
+	releaseOnce sync.Once
+
+	mu     sync.Mutex
+	inputs [][]*schema.Message
+}
+
+// NewBlockingModel 创建阻塞的模型
+func NewBlockingModel() *BlockingModel {
+	return &BlockingModel{release: make(chan struct{})}
+}
+
+// Release 放行所有阻塞中和之后的生成
+func (m *BlockingModel) Release() {
+	m.releaseOnce.Do(func() { close(m.release) })
+}
+
+// Inputs 返回每次调用收到的消息
+func (m *BlockingModel) Inputs() [][]*schema.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([][]*schema.Message(nil), m.inputs...)
+}
+
+// Generate 等到放行后返回完整回复
+func (m *BlockingModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.record(input)
+
+	select {
+	case <-m.release:
+		return schema.AssistantMessage(FirstChunk+LastChunk, nil), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Stream 立即发出第一段回复，放行后发出剩余回复，ctx 取消时以 ctx 的错误结束
+func (m *BlockingModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	m.record(input)
+
+	sr, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer sw.Close()
+		sw.Send(schema.AssistantMessage(FirstChunk, nil), nil)
+
+		select {
+		case <-m.release:
+			sw.Send(schema.AssistantMessage(LastChunk, nil), nil)
+		case <-ctx.Done():
+			sw.Send(nil, ctx.Err())
+		}
+	}()
+	return sr, nil
+}
+
+// WithTools 忽略工具，返回模型本身
+func (m *BlockingModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// record 记录一次调用的输入
+func (m *BlockingModel) record(input []*schema.Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inputs = append(m.inputs, input)
+}
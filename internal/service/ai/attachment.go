@@ -0,0 +1,58 @@
+package ai
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// imageTokenEstimate 单张图片按固定 token 数估算，实际用量取决于模型和图片尺寸
+const imageTokenEstimate = 1000
+
+// messageText 返回消息发送给模型的文本：正文后依次附上文本附件的内容
+func messageText(msg *models.Message) string {
+	var b strings.Builder
+	b.WriteString(msg.Content)
+	for _, a := range msg.Attachments {
+		if !a.IsText() {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "附件 %s:\n```\n%s\n```", a.Name, strings.TrimRight(string(a.Data), "\n"))
+	}
+	return b.String()
+}
+
+// imageParts 将图片附件转换为多模态内容，图片以 data URL 内联发送
+func imageParts(msg *models.Message) []schema.ChatMessagePart {
+	var parts []schema.ChatMessagePart
+	for _, a := range msg.Attachments {
+		if !a.IsImage() {
+			continue
+		}
+		parts = append(parts, schema.ChatMessagePart{
+			Type: schema.ChatMessagePartTypeImageURL,
+			ImageURL: &schema.ChatMessageImageURL{
+				URL:      fmt.Sprintf("data:%s;base64,%s", a.MimeType, base64.StdEncoding.EncodeToString(a.Data)),
+				MIMEType: a.MimeType,
+			},
+		})
+	}
+	return parts
+}
+
+// imageCount 返回消息中图片附件的数量
+func imageCount(msg *models.Message) int {
+	n := 0
+	for _, a := range msg.Attachments {
+		if a.IsImage() {
+			n++
+		}
+	}
+	return n
+}
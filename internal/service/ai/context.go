@@ -0,0 +1,179 @@
+package ai
+
+import (
+	"context"
+	"log"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+const (
+	defaultContextWindow = 32768
+	defaultReplyReserve  = 4096
+
+	// summaryPrefix 摘要作为系统消息发送时的引导语
+	summaryPrefix = "以下是本次对话较早部分的摘要，请结合摘要继续对话：\n\n"
+)
+
+// Summarizer 将较早的对话压缩为摘要
+type Summarizer interface {
+	// Summarize 在 previous 摘要的基础上合并 messages，返回新的摘要
+	Summarize(ctx context.Context, previous string, messages []*models.Message) (string, error)
+}
+
+// SummaryStore 持久化会话的滚动摘要，避免每轮对话重复生成
+type SummaryStore interface {
+	GetSummary(sessionID string) (*models.Summary, error)
+	SaveSummary(summary *models.Summary) error
+}
+
+// SetSummarizer 设置摘要生成器和摘要存储，未设置时超出上下文窗口的旧消息直接丢弃
+func (s *Service) SetSummarizer(summarizer Summarizer, store SummaryStore) {
+	s.summarizer = summarizer
+	s.summaryStore = store
+}
+
+// contextBudget 返回可用于输入消息的 token 预算
+func (s *Service) contextBudget(session *models.Session) int {
+	window := s.config.ContextWindow
+	if window <= 0 {
+		window = defaultContextWindow
+	}
+	reserve := s.config.ReplyReserve
+	if reserve <= 0 {
+		reserve = defaultReplyReserve
+	}
+	if session != nil && session.MaxTokens != nil && *session.MaxTokens > reserve {
+		reserve = *session.MaxTokens
+	}
+
+	// 预留过大时至少保留四分之一窗口给输入
+	return max(window-reserve, window/4)
+}
+
+// buildContext 按 token 预算组装发送给模型的消息：
+// 系统提示词 + 知识库资料 + 较早对话的摘要 + 预算内的最近消息
+func (s *Service) buildContext(ctx context.Context, session *models.Session, history []*models.Message, knowledge string) []*schema.Message {
+	history = contextMessages(history)
+
+	var systemPrompt, sessionID string
+	if session != nil {
+		systemPrompt, sessionID = session.SystemPrompt, session.ID
+	}
+
+	budget := s.contextBudget(session) - EstimateTokens(systemPrompt) - EstimateTokens(knowledge)
+	total := 0
+	for _, msg := range history {
+		total += estimateMessageTokens(msg)
+	}
+
+	var summary string
+	if total > budget {
+		// 超出预算：为摘要留出四分之一预算，其余尽量保留最近的消息
+		keepFrom := recentWindowStart(history, budget-budget/4)
+		// 工具结果必须紧跟发起调用的助手消息，不能单独留在窗口开头
+		for keepFrom < len(history)-1 && history[keepFrom].Role == models.RoleTool {
+			keepFrom++
+		}
+		summary = s.summarize(ctx, sessionID, history[:keepFrom], budget/2)
+		history = history[keepFrom:]
+	}
+
+	messages := make([]*schema.Message, 0, len(history)+3)
+	if systemPrompt != "" {
+		messages = append(messages, &schema.Message{Role: schema.System, Content: systemPrompt})
+	}
+	if knowledge != "" {
+		messages = append(messages, &schema.Message{Role: schema.System, Content: knowledge})
+	}
+	if summary != "" {
+		messages = append(messages, &schema.Message{Role: schema.System, Content: summaryPrefix + summary})
+	}
+	return append(messages, convertMessages(history)...)
+}
+
+// summarize 获取覆盖 older 的摘要：复用已保存的摘要，只对新移出窗口的消息增量合并
+// batchTokens 限制单次摘要请求的输入规模；生成失败时退化为直接丢弃旧消息
+func (s *Service) summarize(ctx context.Context, sessionID string, older []*models.Message, batchTokens int) string {
+	if s.summarizer == nil || len(older) == 0 {
+		return ""
+	}
+
+	// 已保存的摘要覆盖到的位置（必须位于当前分支上）
+	start, previous := 0, ""
+	if s.summaryStore != nil && sessionID != "" {
+		saved, err := s.summaryStore.GetSummary(sessionID)
+		if err != nil {
+			log.Printf("读取会话摘要失败: %v", err)
+		}
+		if saved != nil {
+			for i, msg := range older {
+				if msg.ID == saved.UpToMessageID {
+					start, previous = i+1, saved.Content
+					break
+				}
+			}
+		}
+	}
+
+	pending := older[start:]
+	if len(pending) == 0 {
+		return previous
+	}
+
+	// 分批合并，避免单次摘要请求超出上下文
+	for len(pending) > 0 {
+		n, used := 0, 0
+		for n < len(pending) && (n == 0 || used+estimateMessageTokens(pending[n]) <= batchTokens) {
+			used += estimateMessageTokens(pending[n])
+			n++
+		}
+
+		updated, err := s.summarizer.Summarize(ctx, previous, pending[:n])
+		if err != nil {
+			log.Printf("生成会话摘要失败: %v", err)
+			return previous
+		}
+		previous = updated
+		pending = pending[n:]
+	}
+
+	if s.summaryStore != nil && sessionID != "" {
+		err := s.summaryStore.SaveSummary(&models.Summary{
+			SessionID:     sessionID,
+			UpToMessageID: older[len(older)-1].ID,
+			Content:       previous,
+		})
+		if err != nil {
+			log.Printf("保存会话摘要失败: %v", err)
+		}
+	}
+
+	return previous
+}
+
+// recentWindowStart 从最新的消息往前累加，返回预算内可保留的第一条消息的位置
+// 最后一条消息无论长短总会保留
+func recentWindowStart(history []*models.Message, budget int) int {
+	used := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		used += estimateMessageTokens(history[i])
+		if used > budget && i < len(history)-1 {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// contextMessages 过滤不应作为上下文的消息：生成失败的回复和空回复（发起工具调用的除外）
+func contextMessages(history []*models.Message) []*models.Message {
+	messages := make([]*models.Message, 0, len(history))
+	for _, msg := range history {
+		if msg.Status == models.StatusFailed || (msg.Role == models.RoleAssistant && msg.Content == "" && len(msg.ToolCalls) == 0) {
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// Conversation 一个会话的对话状态：会话设置和当前分支的消息历史
+// Service 本身不保存会话状态，每次生成都在调用方传入的 Conversation 上进行；切换会话时换用新的
+// Conversation，进行中的生成仍写入原来的对象，回复不会串到其他会话。方法可在多个 goroutine 中并发调用
+type Conversation struct {
+	mu      sync.Mutex
+	session *models.Session // 会话设置的副本，调用方之后修改原对象不会影响进行中的生成
+	history []*models.Message
+}
+
+// NewConversation 创建对话，session 为空时使用全局默认设置
+func NewConversation(session *models.Session, history []*models.Message) *Conversation {
+	c := &Conversation{}
+	c.SetSession(session)
+	c.SetHistory(history)
+	return c
+}
+
+// Session 返回会话设置的副本，未设置时返回 nil
+func (c *Conversation) Session() *models.Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.session == nil {
+		return nil
+	}
+	copied := *c.session
+	return &copied
+}
+
+// SessionID 返回对话所属的会话 ID
+func (c *Conversation) SessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.session == nil {
+		return ""
+	}
+	return c.session.ID
+}
+
+// SetSession 更新会话设置，之后的请求使用新的系统提示词和模型参数
+func (c *Conversation) SetSession(session *models.Session) {
+	var copied *models.Session
+	if session != nil {
+		s := *session
+		s.DisabledMCPServers = slices.Clone(session.DisabledMCPServers)
+		copied = &s
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = copied
+}
+
+// History 返回消息历史的副本
+func (c *Conversation) History() []*models.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.history)
+}
+
+// SetHistory 用 messages 覆盖消息历史
+func (c *Conversation) SetHistory(messages []*models.Message) {
+	history := make([]*models.Message, len(messages))
+	copy(history, messages)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.history = history
+}
+
+// snapshot 返回组装一次请求所需的会话设置和消息历史；会话设置只会整体替换，可在锁外读取
+func (c *Conversation) snapshot() (*models.Session, []*models.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session, slices.Clone(c.history)
+}
+
+// append 将消息追加到历史，未指定父消息时接在历史末尾
+func (c *Conversation) append(msg *models.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if msg.ParentID == "" && len(c.history) > 0 {
+		msg.ParentID = c.history[len(c.history)-1].ID
+	}
+	c.history = append(c.history, msg)
+}
+
+// rewindToUser 丢弃历史末尾最后一条用户消息之后的消息
+func (c *Conversation) rewindToUser() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.history) > 0 && c.history[len(c.history)-1].Role != models.RoleUser {
+		c.history = c.history[:len(c.history)-1]
+	}
+}
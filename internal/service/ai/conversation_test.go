@@ -0,0 +1,126 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/wangle201210/gochat/internal/config"
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/ai/aitest"
+)
+
+// chatResult 一次 StreamChat 的结果
+type chatResult struct {
+	reply *models.Message
+	err   error
+}
+
+// streamChat 在后台调用 StreamChat，received 在收到第一段回复后关闭
+func streamChat(ctx context.Context, s *Service, conv *Conversation, userMsg *models.Message) (done <-chan chatResult, received <-chan struct{}) {
+	results := make(chan chatResult, 1)
+	chunked := make(chan struct{})
+	go func() {
+		first := true
+		reply, err := s.StreamChat(ctx, conv, userMsg, StreamHandler{OnChunk: func(string) error {
+			if first {
+				first = false
+				close(chunked)
+			}
+			return nil
+		}})
+		results <- chatResult{reply: reply, err: err}
+	}()
+	return results, chunked
+}
+
+// systemPrompt 返回模型输入中的系统提示词
+func systemPrompt(input []*schema.Message) string {
+	if len(input) > 0 && input[0].Role == schema.System {
+		return input[0].Content
+	}
+	return ""
+}
+
+func TestStreamChatKeepsSessionAfterSwitch(t *testing.T) {
+	tests := []struct {
+		name    string
+		finish  func(m *aitest.BlockingModel, cancel context.CancelFunc)
+		wantErr error
+		content string
+		status  models.MessageStatus
+	}{
+		{
+			name:    "完成",
+			finish:  func(m *aitest.BlockingModel, cancel context.CancelFunc) { m.Release() },
+			content: aitest.FirstChunk + aitest.LastChunk,
+			status:  models.StatusComplete,
+		},
+		{
+			name:    "中止",
+			finish:  func(m *aitest.BlockingModel, cancel context.CancelFunc) { cancel() },
+			wantErr: context.Canceled,
+			content: aitest.FirstChunk,
+			status:  models.StatusInterrupted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := aitest.NewBlockingModel()
+			s := NewServiceWithModel(&config.AIConfig{}, m)
+
+			sessionA := &models.Session{ID: "session-a", SystemPrompt: "A 的提示词"}
+			sessionB := &models.Session{ID: "session-b", SystemPrompt: "B 的提示词"}
+			convA := NewConversation(sessionA, nil)
+			convB := NewConversation(sessionB, nil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			userA := models.NewMessage(models.RoleUser, "你好")
+			doneA, receivedA := streamChat(ctx, s, convA, userA)
+
+			// 会话 A 生成到一半时切换到会话 B 并发送消息，同时修改 A 的会话对象
+			<-receivedA
+			sessionA.ID, sessionA.SystemPrompt = "session-b", "B 的提示词"
+			userB := models.NewMessage(models.RoleUser, "在吗")
+			doneB, receivedB := streamChat(context.Background(), s, convB, userB)
+			<-receivedB
+
+			tt.finish(m, cancel)
+			m.Release()
+
+			a := <-doneA
+			if !errors.Is(a.err, tt.wantErr) {
+				t.Fatalf("会话 A 的错误 = %v，期望 %v", a.err, tt.wantErr)
+			}
+			if a.reply == nil || a.reply.Content != tt.content || a.reply.Status != tt.status || a.reply.ParentID != userA.ID {
+				t.Fatalf("会话 A 的回复 = %+v，期望 {%q %q parent=%s}", a.reply, tt.content, tt.status, userA.ID)
+			}
+			b := <-doneB
+			if b.err != nil {
+				t.Fatalf("会话 B 生成失败: %v", b.err)
+			}
+
+			if history := convA.History(); len(history) != 2 || history[0] != userA || history[1] != a.reply {
+				t.Errorf("会话 A 的历史 = %v", history)
+			}
+			if history := convB.History(); len(history) != 2 || history[0] != userB || history[1] != b.reply {
+				t.Errorf("会话 B 的历史 = %v", history)
+			}
+			if id := convA.SessionID(); id != "session-a" {
+				t.Errorf("会话 A 的 ID 变为 %q", id)
+			}
+
+			// 两次请求各自使用所属会话的提示词
+			prompts := map[string]int{}
+			for _, input := range m.Inputs() {
+				prompts[systemPrompt(input)]++
+			}
+			if prompts["A 的提示词"] != 1 || prompts["B 的提示词"] != 1 {
+				t.Errorf("模型收到的系统提示词 = %v", prompts)
+			}
+		})
+	}
+}
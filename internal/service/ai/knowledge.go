@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+const (
+	// knowledgePrefix 检索到的资料作为系统消息发送时的引导语
+	knowledgePrefix = "以下是从知识库中检索到的资料。回答时请优先依据这些资料，并在引用处用 [编号] 标注来源；资料与问题无关时忽略即可。\n\n"
+
+	// citationExcerptRunes 回复中保存的引用摘录长度
+	citationExcerptRunes = 300
+)
+
+// Retriever 从会话关联的知识库中检索与问题相关的片段
+type Retriever interface {
+	Retrieve(ctx context.Context, kbID, query string) ([]models.Citation, error)
+}
+
+// SetRetriever 设置知识库检索器，未设置时不使用知识库
+func (s *Service) SetRetriever(retriever Retriever) {
+	s.retriever = retriever
+}
+
+// retrieve 用历史中最后一条用户消息检索会话的知识库，检索失败时记录日志并不使用知识库
+func (s *Service) retrieve(ctx context.Context, session *models.Session, history []*models.Message) []models.Citation {
+	if s.retriever == nil || session == nil || session.KnowledgeBaseID == "" {
+		return nil
+	}
+
+	var query string
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == models.RoleUser {
+			query = history[i].Content
+			break
+		}
+	}
+
+	citations, err := s.retriever.Retrieve(ctx, session.KnowledgeBaseID, query)
+	if err != nil {
+		log.Printf("检索知识库失败: %v", err)
+		return nil
+	}
+	return citations
+}
+
+// knowledgePrompt 将检索到的片段组装为系统消息内容
+func knowledgePrompt(citations []models.Citation) string {
+	if len(citations) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(knowledgePrefix)
+	for _, c := range citations {
+		fmt.Fprintf(&b, "[%d] 来源: %s\n%s\n\n", c.Index, c.Source, c.Content)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// citationExcerpts 返回只保留摘录的引用，随回复保存
+func citationExcerpts(citations []models.Citation) []models.Citation {
+	if len(citations) == 0 {
+		return nil
+	}
+
+	excerpts := make([]models.Citation, len(citations))
+	for i, c := range citations {
+		if runes := []rune(c.Content); len(runes) > citationExcerptRunes {
+			c.Content = string(runes[:citationExcerptRunes]) + "…"
+		}
+		excerpts[i] = c
+	}
+	return excerpts
+}
@@ -2,64 +2,75 @@ package ai
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
 
-	"github.com/cloudwego/eino-ext/components/model/openai"
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 	"github.com/wangle201210/gochat/internal/config"
 	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/provider"
+	"github.com/wangle201210/gochat/internal/service/tools"
 )
 
-// Service AI 服务
+// Service AI 服务，不保存会话状态：历史和会话设置由每次调用传入的 Conversation 提供，
+// 可同时为多个会话生成回复；Set 开头的配置方法需在开始对话前调用
 type Service struct {
-	chatModel model.ChatModel
+	chatModel model.ToolCallingChatModel
 	config    *config.AIConfig
-	history   []*models.Message
+
+	summarizer   Summarizer   // 超出上下文窗口时压缩旧消息
+	summaryStore SummaryStore // 持久化滚动摘要
+
+	tools     *tools.Registry // 可供模型调用的工具，为空时不启用工具调用
+	retriever Retriever       // 知识库检索器，为空时不使用知识库
 }
 
+// defaultMaxToolSteps 单次回复中工具调用的默认最大轮数
+const defaultMaxToolSteps = 8
+
 // NewService 创建 AI 服务
 func NewService(cfg *config.AIConfig) (*Service, error) {
-	var chatModel model.ChatModel
-	var err error
-
-	switch cfg.Provider {
-	// case "openai":
-	// 	chatModel, err = openai.NewChatModel(context.Background(), &openai.ChatModelConfig{
-	// 		BaseURL: cfg.BaseURL,
-	// 		Model:   cfg.Model,
-	// 		APIKey:  cfg.APIKey,
-	// 	})
-	default:
-		chatModel, err = openai.NewChatModel(context.Background(), &openai.ChatModelConfig{
-			BaseURL: cfg.BaseURL,
-			Model:   cfg.Model,
-			APIKey:  cfg.APIKey,
-		})
-	}
-
+	chatModel, err := provider.NewChatModel(context.Background(), &cfg.ModelConfig)
 	if err != nil {
 		return nil, fmt.Errorf("初始化 AI 模型失败: %w", err)
 	}
 
+	return NewServiceWithModel(cfg, chatModel), nil
+}
+
+// NewServiceWithModel 使用已创建的 ChatModel 创建 AI 服务，cfg 中的 provider 配置不再使用
+func NewServiceWithModel(cfg *config.AIConfig, chatModel model.ToolCallingChatModel) *Service {
 	return &Service{
 		chatModel: chatModel,
 		config:    cfg,
-		history:   make([]*models.Message, 0),
-	}, nil
+	}
+}
+
+// Provider 返回处理请求的 provider 规范名称，用于按 provider 限速
+// 会话只能覆盖模型名称，所有会话的请求都发往配置的 provider
+func (s *Service) Provider() string {
+	return provider.Name(s.config.Provider)
 }
 
-// Chat 发送消息并获取回复
-func (s *Service) Chat(ctx context.Context, userMessage string) (string, error) {
+// Chat 在对话中发送消息并获取回复
+func (s *Service) Chat(ctx context.Context, conv *Conversation, userMessage string) (string, error) {
 	// 添加用户消息到历史
 	userMsg := models.NewMessage(models.RoleUser, userMessage)
-	s.history = append(s.history, userMsg)
+	conv.append(userMsg)
 
-	// 转换消息历史为 Eino 格式
-	messages := s.convertMessages()
+	// 按上下文窗口组装消息
+	session, history := conv.snapshot()
+	citations := s.retrieve(ctx, session, history)
+	messages := s.buildContext(ctx, session, history, knowledgePrompt(citations))
 
 	// 调用 AI 模型
-	resp, err := s.chatModel.Generate(ctx, messages)
+	start := time.Now()
+	resp, err := s.chatModel.Generate(ctx, messages, modelOptions(session)...)
 	if err != nil {
 		return "", fmt.Errorf("AI 生成失败: %w", err)
 	}
@@ -69,74 +80,268 @@ func (s *Service) Chat(ctx context.Context, userMessage string) (string, error)
 
 	// 添加助手消息到历史
 	assistantMsg := models.NewMessage(models.RoleAssistant, assistantContent)
-	s.history = append(s.history, assistantMsg)
+	assistantMsg.Usage = NewUsage(s.modelName(session), start, resp.ResponseMeta, messages, assistantContent)
+	assistantMsg.Citations = citationExcerpts(citations)
+	conv.append(assistantMsg)
 
 	return assistantContent, nil
 }
 
-// StreamChat 流式发送消息并获取回复
-func (s *Service) StreamChat(ctx context.Context, userMessage string, callback func(string) error) error {
+// StreamError 流式生成失败时返回的错误，携带已生成的部分回复
+type StreamError struct {
+	Partial *models.Message // 已写入历史、标记为 StatusFailed 的部分回复
+	Err     error
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("AI 流式生成失败: %v", e.Err)
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// StreamHandler 接收流式生成过程中的回调，字段均可为空
+type StreamHandler struct {
+	// OnChunk 收到一段回复内容时调用，返回错误会中止生成
+	OnChunk func(content string) error
+	// OnMessage 工具调用过程中产生的中间消息（发起工具调用的助手消息、工具结果）写入历史后调用
+	OnMessage func(msg *models.Message)
+	// Approve 执行工具调用前调用，返回 false 表示拒绝执行；为空时直接执行
+	Approve func(ctx context.Context, call models.ToolCall) bool
+}
+
+// StreamChat 在对话中流式发送消息并获取回复
+// 返回写入 conv 历史的最终助手消息；若 ctx 在生成过程中被取消，已生成的部分内容会以
+// StatusInterrupted 标记写入历史并随 ctx.Err() 一同返回；若生成出错，
+// 部分内容以 StatusFailed 标记写入历史并通过 *StreamError 返回
+// userMsg 由调用方创建（便于先行持久化），未设置 ParentID 时自动接在历史末尾
+func (s *Service) StreamChat(ctx context.Context, conv *Conversation, userMsg *models.Message, handler StreamHandler) (*models.Message, error) {
 	// 添加用户消息到历史
-	userMsg := models.NewMessage(models.RoleUser, userMessage)
-	s.history = append(s.history, userMsg)
+	conv.append(userMsg)
 
-	// 转换消息历史为 Eino 格式
-	messages := s.convertMessages()
+	return s.StreamReply(ctx, conv, handler)
+}
+
+// StreamReply 基于当前历史流式生成一条助手回复（不追加用户消息），用于重试等场景
+// 设置了工具时按 ReAct 方式循环：模型发起工具调用 -> 执行工具 -> 将结果交给模型继续生成，
+// 直到模型给出不含工具调用的回复；会话关联了知识库时，先按最后一条用户消息检索资料并附在上下文中
+func (s *Service) StreamReply(ctx context.Context, conv *Conversation, handler StreamHandler) (*models.Message, error) {
+	maxSteps := s.config.MaxToolSteps
+	if maxSteps <= 0 {
+		maxSteps = defaultMaxToolSteps
+	}
+
+	session, history := conv.snapshot()
+	toolInfos := s.enabledTools(session)
+	citations := s.retrieve(ctx, session, history)
+	knowledge := knowledgePrompt(citations)
+	for step := 0; ; step++ {
+		// 达到步数上限后不再提供工具，要求模型直接作答
+		var infos []*schema.ToolInfo
+		if step < maxSteps {
+			infos = toolInfos
+		}
+
+		reply, err := s.streamStep(ctx, conv, handler, infos, knowledge)
+		if err != nil || len(reply.ToolCalls) == 0 {
+			if reply != nil {
+				reply.Citations = citationExcerpts(citations)
+			}
+			return reply, err
+		}
+
+		if handler.OnMessage != nil {
+			handler.OnMessage(reply)
+		}
+		if err := s.runToolCalls(ctx, conv, reply.ToolCalls, toolInfos, handler); err != nil {
+			return appendPartial(conv, "", models.StatusInterrupted, nil), err
+		}
+	}
+}
+
+// streamStep 调用一次流式模型，返回写入历史的助手消息（可能包含工具调用）
+// toolInfos 为本次提供给模型的工具，为空时不启用工具调用；knowledge 为附加的知识库资料
+func (s *Service) streamStep(ctx context.Context, conv *Conversation, handler StreamHandler, toolInfos []*schema.ToolInfo, knowledge string) (*models.Message, error) {
+	// 按上下文窗口组装消息
+	session, history := conv.snapshot()
+	messages := s.buildContext(ctx, session, history, knowledge)
+
+	opts := modelOptions(session)
+	if len(toolInfos) > 0 {
+		opts = append(opts, model.WithTools(toolInfos))
+	}
 
 	// 调用流式 AI 模型
-	streamReader, err := s.chatModel.Stream(ctx, messages)
+	start := time.Now()
+	streamReader, err := s.chatModel.Stream(ctx, messages, opts...)
 	if err != nil {
-		return fmt.Errorf("AI 流式生成失败: %w", err)
+		if ctx.Err() != nil {
+			return appendPartial(conv, "", models.StatusInterrupted, nil), ctx.Err()
+		}
+		return nil, &StreamError{Partial: appendPartial(conv, "", models.StatusFailed, nil), Err: err}
 	}
+	defer streamReader.Close()
 
-	var fullContent string
+	var fullContent strings.Builder
+	meta := &schema.ResponseMeta{}
+	var chunks []*schema.Message
 
 	// 读取流式响应
 	for {
 		chunk, err := streamReader.Recv()
-		if err != nil {
-			// 流结束
+		if errors.Is(err, io.EOF) {
+			// 流正常结束
 			break
 		}
+		if err != nil {
+			content := fullContent.String()
+			usage := NewUsage(s.modelName(session), start, meta, messages, content)
+			if ctx.Err() != nil {
+				return appendPartial(conv, content, models.StatusInterrupted, usage), ctx.Err()
+			}
+			return nil, &StreamError{Partial: appendPartial(conv, content, models.StatusFailed, usage), Err: err}
+		}
 
 		content := chunk.Content
-		fullContent += content
+		fullContent.WriteString(content)
+		MergeResponseMeta(meta, chunk.ResponseMeta)
+		if len(chunk.ToolCalls) > 0 {
+			chunks = append(chunks, chunk)
+		}
 
 		// 回调处理每个流式块
-		if callback != nil {
-			if err := callback(content); err != nil {
-				return err
+		if handler.OnChunk != nil && content != "" {
+			if err := handler.OnChunk(content); err != nil {
+				return nil, err
 			}
 		}
 	}
 
 	// 添加完整的助手消息到历史
-	assistantMsg := models.NewMessage(models.RoleAssistant, fullContent)
-	s.history = append(s.history, assistantMsg)
+	assistantMsg := models.NewMessage(models.RoleAssistant, fullContent.String())
+	assistantMsg.Usage = NewUsage(s.modelName(session), start, meta, messages, assistantMsg.Content)
 
-	return nil
+	// 工具调用的参数分散在多个流式块中，需要按序号合并
+	if len(chunks) > 0 {
+		merged, err := schema.ConcatMessages(chunks)
+		if err != nil {
+			return nil, &StreamError{Partial: appendPartial(conv, assistantMsg.Content, models.StatusFailed, assistantMsg.Usage), Err: err}
+		}
+		assistantMsg.ToolCalls = convertToolCalls(merged.ToolCalls)
+	}
+
+	conv.append(assistantMsg)
+
+	return assistantMsg, nil
+}
+
+// runToolCalls 依次执行工具调用并将结果写入历史，只执行本会话启用的工具
+// 工具执行失败或被拒绝时把原因作为结果交给模型；ctx 被取消时仍为每个调用写入结果，保证历史中调用与结果成对出现
+func (s *Service) runToolCalls(ctx context.Context, conv *Conversation, calls []models.ToolCall, enabled []*schema.ToolInfo, handler StreamHandler) error {
+	for _, call := range calls {
+		var content string
+		switch {
+		case ctx.Err() != nil:
+			content = "工具调用已取消"
+		case !slices.ContainsFunc(enabled, func(info *schema.ToolInfo) bool { return info.Name == call.Name }):
+			content = fmt.Sprintf("工具调用失败: 未知工具: %s", call.Name)
+		case handler.Approve != nil && !handler.Approve(ctx, call):
+			content = "用户拒绝了此次工具调用"
+			if ctx.Err() != nil {
+				content = "工具调用已取消"
+			}
+		default:
+			result, err := s.tools.Call(ctx, call.Name, call.Arguments)
+			if err != nil {
+				content = fmt.Sprintf("工具调用失败: %v", err)
+			} else {
+				content = result
+			}
+		}
+
+		msg := models.NewMessage(models.RoleTool, content)
+		msg.ToolCallID = call.ID
+		msg.ToolName = call.Name
+		conv.append(msg)
+
+		if handler.OnMessage != nil {
+			handler.OnMessage(msg)
+		}
+	}
+
+	return ctx.Err()
+}
+
+// RegenerateReply 丢弃历史末尾最后一条用户消息之后的回复（包括工具调用过程），基于相同的上下文重新生成
+// 新回复与被丢弃的回复共享父消息，调用方可将旧回复保留为另一个版本
+func (s *Service) RegenerateReply(ctx context.Context, conv *Conversation, handler StreamHandler) (*models.Message, error) {
+	conv.rewindToUser()
+
+	return s.StreamReply(ctx, conv, handler)
+}
+
+// appendPartial 将未正常完成的部分回复按指定状态写入历史
+// 请求未能发出时 usage 为 nil
+func appendPartial(conv *Conversation, content string, status models.MessageStatus, usage *models.Usage) *models.Message {
+	msg := models.NewMessage(models.RoleAssistant, content)
+	msg.Status = status
+	msg.Usage = usage
+	conv.append(msg)
+	return msg
+}
+
+// SetTools 设置可供模型调用的工具
+func (s *Service) SetTools(registry *tools.Registry) {
+	s.tools = registry
 }
 
-// GetHistory 获取消息历史
-func (s *Service) GetHistory() []*models.Message {
-	return s.history
+// ToolGroups 返回可按会话开关的工具分组（即已启动的 MCP 服务）
+func (s *Service) ToolGroups() []string {
+	if s.tools == nil {
+		return nil
+	}
+	return s.tools.Groups()
 }
 
-// ClearHistory 清空消息历史
-func (s *Service) ClearHistory() {
-	s.history = make([]*models.Message, 0)
+// enabledTools 返回会话启用的工具
+func (s *Service) enabledTools(session *models.Session) []*schema.ToolInfo {
+	if s.tools == nil {
+		return nil
+	}
+	if session == nil {
+		return s.tools.Infos()
+	}
+	return s.tools.Infos(session.DisabledMCPServers...)
 }
 
-// SetHistory 设置消息历史
-func (s *Service) SetHistory(messages []*models.Message) {
-	s.history = messages
+// modelOptions 将会话级模型参数转换为 Eino 调用选项
+func modelOptions(session *models.Session) []model.Option {
+	if session == nil {
+		return nil
+	}
+
+	opts := make([]model.Option, 0, 4)
+	if session.Model != "" {
+		opts = append(opts, model.WithModel(session.Model))
+	}
+	if session.Temperature != nil {
+		opts = append(opts, model.WithTemperature(*session.Temperature))
+	}
+	if session.TopP != nil {
+		opts = append(opts, model.WithTopP(*session.TopP))
+	}
+	if session.MaxTokens != nil {
+		opts = append(opts, model.WithMaxTokens(*session.MaxTokens))
+	}
+	return opts
 }
 
 // convertMessages 将内部消息格式转换为 Eino 格式
-func (s *Service) convertMessages() []*schema.Message {
-	messages := make([]*schema.Message, 0, len(s.history))
+func convertMessages(history []*models.Message) []*schema.Message {
+	messages := make([]*schema.Message, 0, len(history))
 
-	for _, msg := range s.history {
+	for _, msg := range history {
 		var role schema.RoleType
 		switch msg.Role {
 		case models.RoleUser:
@@ -145,15 +350,53 @@ func (s *Service) convertMessages() []*schema.Message {
 			role = schema.Assistant
 		case models.RoleSystem:
 			role = schema.System
+		case models.RoleTool:
+			role = schema.Tool
 		default:
 			role = schema.User
 		}
 
-		messages = append(messages, &schema.Message{
-			Role:    role,
-			Content: msg.Content,
-		})
+		message := &schema.Message{
+			Role:       role,
+			Content:    messageText(msg),
+			ToolCallID: msg.ToolCallID,
+			ToolName:   msg.ToolName,
+		}
+
+		// 带图片的消息改用多模态内容，文本作为第一部分
+		if images := imageParts(msg); len(images) > 0 {
+			if message.Content != "" {
+				message.MultiContent = append(message.MultiContent, schema.ChatMessagePart{
+					Type: schema.ChatMessagePartTypeText,
+					Text: message.Content,
+				})
+			}
+			message.MultiContent = append(message.MultiContent, images...)
+			message.Content = ""
+		}
+		for _, call := range msg.ToolCalls {
+			message.ToolCalls = append(message.ToolCalls, schema.ToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: schema.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+
+		messages = append(messages, message)
 	}
 
 	return messages
 }
+
+// convertToolCalls 将 Eino 的工具调用转换为内部格式
+func convertToolCalls(calls []schema.ToolCall) []models.ToolCall {
+	result := make([]models.ToolCall, 0, len(calls))
+	for _, call := range calls {
+		result = append(result, models.ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return result
+}
@@ -0,0 +1,30 @@
+package ai
+
+import (
+	"unicode"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// messageOverheadTokens 每条消息在角色、分隔符等格式上的额外开销
+const messageOverheadTokens = 4
+
+// EstimateTokens 估算文本的 token 数
+// 不依赖具体模型的分词表：中日韩字符按每字 1 个 token，其余字符按每 4 个字符 1 个 token 计算，
+// 对常见模型略偏保守
+func EstimateTokens(text string) int {
+	var cjk, other int
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// estimateMessageTokens 估算单条消息占用的 token 数，包括文本附件和图片
+func estimateMessageTokens(msg *models.Message) int {
+	return EstimateTokens(messageText(msg)) + imageCount(msg)*imageTokenEstimate + messageOverheadTokens
+}
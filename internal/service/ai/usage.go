@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// modelName 返回本次调用实际使用的模型名称
+func (s *Service) modelName(session *models.Session) string {
+	if session != nil && session.Model != "" {
+		return session.Model
+	}
+	return s.config.Model
+}
+
+// NewUsage 根据模型返回的元数据生成一次调用的用量信息，start 为开始调用的时间
+// provider 未返回 token 数时，按 EstimateTokens 估算输入和输出；gochat serve 转发请求时同样使用
+func NewUsage(modelName string, start time.Time, meta *schema.ResponseMeta, prompt []*schema.Message, completion string) *models.Usage {
+	usage := &models.Usage{
+		Model:   modelName,
+		Latency: time.Since(start),
+	}
+	if meta != nil {
+		usage.FinishReason = meta.FinishReason
+	}
+
+	if meta != nil && meta.Usage != nil && meta.Usage.TotalTokens > 0 {
+		usage.PromptTokens = meta.Usage.PromptTokens
+		usage.CompletionTokens = meta.Usage.CompletionTokens
+		return usage
+	}
+
+	for _, msg := range prompt {
+		usage.PromptTokens += EstimateTokens(msg.Content) + messageOverheadTokens
+		for _, part := range msg.MultiContent {
+			if part.Type == schema.ChatMessagePartTypeImageURL {
+				usage.PromptTokens += imageTokenEstimate
+			} else {
+				usage.PromptTokens += EstimateTokens(part.Text)
+			}
+		}
+	}
+	usage.CompletionTokens = EstimateTokens(completion)
+	return usage
+}
+
+// MergeResponseMeta 合并流式分块中的元数据，结束原因和用量通常只出现在最后的分块中
+func MergeResponseMeta(dst *schema.ResponseMeta, chunk *schema.ResponseMeta) {
+	if chunk == nil {
+		return
+	}
+	if chunk.FinishReason != "" {
+		dst.FinishReason = chunk.FinishReason
+	}
+	if chunk.Usage != nil {
+		dst.Usage = chunk.Usage
+	}
+}
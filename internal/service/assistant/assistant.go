@@ -4,35 +4,22 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/cloudwego/eino-ext/components/model/openai"
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 	"github.com/wangle201210/gochat/internal/config"
 	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/provider"
 )
 
 // Service 助手服务（用于生成标题等辅助任务）
 type Service struct {
-	chatModel model.ChatModel
+	chatModel model.BaseChatModel
 	config    *config.AssistantConfig
 }
 
 // NewService 创建助手服务
 func NewService(cfg *config.AssistantConfig) (*Service, error) {
-	var chatModel model.ChatModel
-	var err error
-
-	switch cfg.Provider {
-	case "openai":
-		chatModel, err = openai.NewChatModel(context.Background(), &openai.ChatModelConfig{
-			BaseURL: cfg.BaseURL,
-			Model:   cfg.Model,
-			APIKey:  cfg.APIKey,
-		})
-	default:
-		return nil, fmt.Errorf("不支持的助手模型 provider: %s", cfg.Provider)
-	}
-
+	chatModel, err := provider.NewChatModel(context.Background(), &cfg.ModelConfig)
 	if err != nil {
 		return nil, fmt.Errorf("初始化助手模型失败: %w", err)
 	}
@@ -90,3 +77,53 @@ func (s *Service) GenerateTitle(ctx context.Context, messages []*models.Message)
 
 	return title, nil
 }
+
+// Summarize 将较早的对话合并进已有摘要，用于压缩超出上下文窗口的历史
+// previous: 之前生成的摘要，可为空
+func (s *Service) Summarize(ctx context.Context, previous string, messages []*models.Message) (string, error) {
+	if len(messages) == 0 {
+		return previous, nil
+	}
+
+	// 构建 prompt
+	systemPrompt := "你是一个对话摘要助手。请将对话内容整理为简洁的摘要，保留关键事实、结论、用户的要求与偏好以及尚未解决的问题，省略寒暄和重复内容。只输出摘要，不要有其他内容。"
+
+	// 构建对话上下文
+	conversationText := ""
+	for _, msg := range messages {
+		if msg.Role == models.RoleUser {
+			conversationText += fmt.Sprintf("用户: %s\n", msg.Content)
+		} else if msg.Role == models.RoleAssistant {
+			conversationText += fmt.Sprintf("助手: %s\n", msg.Content)
+		}
+	}
+
+	userPrompt := fmt.Sprintf("请为以下对话生成摘要:\n\n%s", conversationText)
+	if previous != "" {
+		userPrompt = fmt.Sprintf("已有摘要:\n%s\n\n请将以下新的对话内容合并进摘要，输出更新后的完整摘要:\n\n%s", previous, conversationText)
+	}
+
+	// 构建消息列表
+	schemaMessages := []*schema.Message{
+		{
+			Role:    schema.System,
+			Content: systemPrompt,
+		},
+		{
+			Role:    schema.User,
+			Content: userPrompt,
+		},
+	}
+
+	// 调用模型
+	resp, err := s.chatModel.Generate(ctx, schemaMessages)
+	if err != nil {
+		return "", fmt.Errorf("生成摘要失败: %w", err)
+	}
+
+	if resp.Content == "" {
+		return "", fmt.Errorf("生成摘要失败: 模型返回空内容")
+	}
+
+	return resp.Content, nil
+}
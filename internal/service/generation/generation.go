@@ -0,0 +1,244 @@
+package generation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/ai"
+	"github.com/wangle201210/gochat/internal/service/queue"
+)
+
+// Store 保存生成过程中产生的消息
+type Store interface {
+	SaveMessage(sessionID string, msg *models.Message) error
+}
+
+// GenerateFunc 在 conv 上调用 AI 服务，返回写入历史的助手消息，如 ai.Service.StreamReply
+type GenerateFunc func(ctx context.Context, conv *ai.Conversation, handler ai.StreamHandler) (*models.Message, error)
+
+// Events 生成过程中的通知，均在调用 Run 的 goroutine 中调用，字段均可为空
+type Events struct {
+	// OnStart 离开生成队列、开始调用模型时调用
+	OnStart func()
+	// OnChunk 收到回复内容时调用，content 为当前这一步已生成的全部内容
+	OnChunk func(content string)
+	// OnMessage 工具调用过程中的中间消息写入对话历史后调用，需由调用方通过 Save 保存
+	OnMessage func(msg *models.Message)
+	// Approve 执行工具调用前调用，为空时直接执行
+	Approve func(ctx context.Context, call models.ToolCall) bool
+}
+
+// Generation 一个会话中正在进行的回复生成，方法可在多个 goroutine 中并发调用
+type Generation struct {
+	sessionID string
+	provider  string // 处理请求的 provider，用于生成队列限速
+	conv      *ai.Conversation
+	cancel    context.CancelFunc
+
+	mu        sync.Mutex
+	content   string // 当前这一步已生成的内容，切回会话时用于恢复占位消息
+	queued    bool   // 仍在生成队列中等待
+	discarded bool   // 会话已被删除，结束时不再保存
+}
+
+// SessionID 返回发起生成的会话 ID
+func (g *Generation) SessionID() string {
+	return g.sessionID
+}
+
+// Conversation 返回生成所在的对话
+func (g *Generation) Conversation() *ai.Conversation {
+	return g.conv
+}
+
+// Started 标记生成已离开队列、开始调用模型
+func (g *Generation) Started() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.queued = false
+}
+
+// Queued 生成是否仍在队列中等待，排队期间被停止的生成始终为 true
+func (g *Generation) Queued() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.queued
+}
+
+// SetContent 记录当前这一步已生成的内容，工具调用的中间消息保存后传入空字符串
+func (g *Generation) SetContent(content string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.content = content
+}
+
+// Content 返回当前这一步已生成的内容
+func (g *Generation) Content() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.content
+}
+
+// PlaceholderText 返回占位消息的内容，还没有生成内容时显示排队或思考中
+func (g *Generation) PlaceholderText() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch {
+	case g.content != "":
+		return g.content
+	case g.queued:
+		return "排队中..."
+	default:
+		return "正在思考..."
+	}
+}
+
+// Discarded 会话是否已被删除，生成结果不再保存
+func (g *Generation) Discarded() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.discarded
+}
+
+// Manager 按会话跟踪正在进行的生成：生成在后台持续到结束，回复保存到发起生成的会话，
+// 不受切换会话影响；排队和限速由 queue.Queue 负责，界面的显示状态由调用方维护。方法可在多个 goroutine 中并发调用
+//
+// 一次生成的流程：Start 登记，Run 在后台排队并调用模型，结束后 Finish 保存结果并移除登记
+type Manager struct {
+	mu     sync.Mutex
+	store  Store
+	queue  *queue.Queue
+	active map[string]*Generation
+}
+
+// NewManager 创建生成管理器，生成在 q 中排队，生成的消息保存到 store
+func NewManager(store Store, q *queue.Queue) *Manager {
+	return &Manager{
+		store:  store,
+		queue:  q,
+		active: make(map[string]*Generation),
+	}
+}
+
+// Start 登记会话新开始的生成，providerName 为处理请求的 provider；返回的 ctx 在生成被停止或结束时取消
+func (m *Manager) Start(sessionID string, conv *ai.Conversation, providerName string) (context.Context, *Generation) {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := &Generation{sessionID: sessionID, provider: providerName, conv: conv, cancel: cancel, queued: true}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active[sessionID] = g
+	return ctx, g
+}
+
+// Get 返回会话正在进行的生成，没有时返回 nil
+func (m *Manager) Get(sessionID string) *Generation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.active[sessionID]
+}
+
+// Conversation 返回加载会话时使用的对话：会话仍在生成回复时沿用生成中的对话，
+// 否则用 history 创建新的对话，避免同一会话同时存在两份历史
+func (m *Manager) Conversation(session *models.Session, history []*models.Message) *ai.Conversation {
+	if g := m.Get(session.ID); g != nil {
+		return g.conv
+	}
+	return ai.NewConversation(session, history)
+}
+
+// Run 在当前 goroutine 中执行生成：在生成队列中等待名额，然后在生成所在的对话上调用 generate，
+// 通过 events 通知进度；返回 generate 的结果，交给 Finish 保存。排队期间被停止时返回 ctx 的错误，
+// 会话被移出队列时返回 queue.ErrCleared，此时 Queued 仍为 true
+func (m *Manager) Run(ctx context.Context, g *Generation, generate GenerateFunc, events Events) (*models.Message, error) {
+	done, err := m.queue.Acquire(ctx, g.sessionID, g.provider)
+	if err != nil {
+		return nil, err
+	}
+	g.Started()
+	if events.OnStart != nil {
+		events.OnStart()
+	}
+
+	var content strings.Builder
+	reply, err := generate(ctx, g.conv, ai.StreamHandler{
+		OnChunk: func(chunk string) error {
+			content.WriteString(chunk)
+			g.SetContent(content.String())
+			if events.OnChunk != nil {
+				events.OnChunk(content.String())
+			}
+			return nil
+		},
+		OnMessage: func(msg *models.Message) {
+			// 工具调用的中间消息之后开始新的一步
+			content.Reset()
+			g.SetContent("")
+			if events.OnMessage != nil {
+				events.OnMessage(msg)
+			}
+		},
+		Approve: events.Approve,
+	})
+	done(err)
+	return reply, err
+}
+
+// Finish 生成结束后把结果保存到发起生成的会话并移除登记，返回保存的消息，没有可保存的内容时返回 nil：
+// 正常完成时保存回复，用户中止时保留已生成的部分内容，出错时保存标记为失败的部分内容；会话已被删除时不保存
+// reply 和 err 为 Run 的返回值
+func (m *Manager) Finish(g *Generation, reply *models.Message, err error) (*models.Message, error) {
+	defer m.unregister(g)
+
+	var result *models.Message
+	var streamErr *ai.StreamError
+	switch {
+	case err == nil || errors.Is(err, context.Canceled):
+		result = reply
+	case errors.As(err, &streamErr):
+		result = streamErr.Partial
+	}
+	if result == nil || g.Discarded() {
+		return nil, nil
+	}
+	if err := m.Save(g, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Stop 停止会话正在进行的生成，discard 为 true 时丢弃生成结果（会话已删除）
+func (m *Manager) Stop(sessionID string, discard bool) {
+	g := m.Get(sessionID)
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	g.discarded = g.discarded || discard
+	g.mu.Unlock()
+	g.cancel()
+}
+
+// Save 将生成的消息保存到发起生成的会话，会话已被删除时跳过
+func (m *Manager) Save(g *Generation, msg *models.Message) error {
+	if g.Discarded() {
+		return nil
+	}
+	return m.store.SaveMessage(g.sessionID, msg)
+}
+
+// unregister 移除生成的登记并释放其 ctx
+func (m *Manager) unregister(g *Generation) {
+	g.cancel()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active[g.sessionID] == g {
+		delete(m.active, g.sessionID)
+	}
+}
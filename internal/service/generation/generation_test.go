@@ -0,0 +1,286 @@
+package generation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/wangle201210/gochat/internal/config"
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/ai"
+	"github.com/wangle201210/gochat/internal/service/ai/aitest"
+	"github.com/wangle201210/gochat/internal/service/queue"
+)
+
+// memoryStore 按会话记录保存的消息
+type memoryStore struct {
+	mu    sync.Mutex
+	saved map[string][]*models.Message
+}
+
+func (s *memoryStore) SaveMessage(sessionID string, msg *models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved[sessionID] = append(s.saved[sessionID], msg)
+	return nil
+}
+
+func (s *memoryStore) messages(sessionID string) []*models.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saved[sessionID]
+}
+
+// testEnv 使用 aitest.BlockingModel 的 AI 服务和生成管理器
+type testEnv struct {
+	model   *aitest.BlockingModel
+	service *ai.Service
+	store   *memoryStore
+	queue   *queue.Queue
+	manager *Manager
+}
+
+func newTestEnv(cfg *config.QueueConfig) *testEnv {
+	m := aitest.NewBlockingModel()
+	store := &memoryStore{saved: make(map[string][]*models.Message)}
+	q := queue.New(cfg)
+	return &testEnv{
+		model:   m,
+		service: ai.NewServiceWithModel(&config.AIConfig{}, m),
+		store:   store,
+		queue:   q,
+		manager: NewManager(store, q),
+	}
+}
+
+// result 一次生成的结果
+type result struct {
+	saved *models.Message
+	err   error
+}
+
+// start 按界面发送消息的方式开始生成，见 run
+func (e *testEnv) start(session *models.Session, conv *ai.Conversation, content string) (g *Generation, done <-chan result, received <-chan struct{}) {
+	userMsg := models.NewMessage(models.RoleUser, content)
+	return e.run(session, conv, func(ctx context.Context, conv *ai.Conversation, handler ai.StreamHandler) (*models.Message, error) {
+		return e.service.StreamChat(ctx, conv, userMsg, handler)
+	})
+}
+
+// run 开始生成并在后台依次调用 Run 和 Finish，返回生成和结果；received 在收到第一段回复后关闭
+func (e *testEnv) run(session *models.Session, conv *ai.Conversation, generate GenerateFunc) (g *Generation, done <-chan result, received <-chan struct{}) {
+	ctx, g := e.manager.Start(session.ID, conv, "")
+
+	results := make(chan result, 1)
+	chunked := make(chan struct{})
+	var once sync.Once
+	go func() {
+		reply, err := e.manager.Run(ctx, g, generate, Events{
+			OnChunk: func(string) { once.Do(func() { close(chunked) }) },
+		})
+		saved, saveErr := e.manager.Finish(g, reply, err)
+		if saveErr != nil {
+			err = saveErr
+		}
+		results <- result{saved: saved, err: err}
+	}()
+	return g, results, chunked
+}
+
+func TestGenerationSurvivesSessionSwitch(t *testing.T) {
+	tests := []struct {
+		name    string
+		finish  func(e *testEnv, sessionID string)
+		wantErr error
+		content string
+		status  models.MessageStatus
+	}{
+		{
+			name:    "完成",
+			finish:  func(e *testEnv, sessionID string) { e.model.Release() },
+			content: aitest.FirstChunk + aitest.LastChunk,
+			status:  models.StatusComplete,
+		},
+		{
+			name:    "停止",
+			finish:  func(e *testEnv, sessionID string) { e.manager.Stop(sessionID, false) },
+			wantErr: context.Canceled,
+			content: aitest.FirstChunk,
+			status:  models.StatusInterrupted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(&config.QueueConfig{})
+			sessionA := &models.Session{ID: "session-a"}
+			sessionB := &models.Session{ID: "session-b"}
+
+			// 在会话 A 中开始生成
+			convA := e.manager.Conversation(sessionA, nil)
+			g, done, received := e.start(sessionA, convA, "你好")
+			<-received
+			userMsg := convA.History()[0]
+
+			// 切换到会话 B：B 使用新的对话，A 的生成继续进行
+			convB := e.manager.Conversation(sessionB, nil)
+			if convB == convA {
+				t.Fatal("会话 B 沿用了会话 A 的对话")
+			}
+			if e.manager.Get(sessionB.ID) != nil {
+				t.Error("会话 B 不应有进行中的生成")
+			}
+			if e.manager.Get(sessionA.ID) != g {
+				t.Fatal("切换会话后会话 A 的生成不再登记")
+			}
+
+			tt.finish(e, sessionA.ID)
+			r := <-done
+			if !errors.Is(r.err, tt.wantErr) {
+				t.Fatalf("生成错误 = %v，期望 %v", r.err, tt.wantErr)
+			}
+
+			saved := e.store.messages(sessionA.ID)
+			if len(saved) != 1 || saved[0] != r.saved {
+				t.Fatalf("会话 A 保存的消息 = %v", saved)
+			}
+			if reply := saved[0]; reply.Content != tt.content || reply.Status != tt.status || reply.ParentID != userMsg.ID {
+				t.Errorf("回复 = {%q %q parent=%s}，期望 {%q %q parent=%s}", reply.Content, reply.Status, reply.ParentID, tt.content, tt.status, userMsg.ID)
+			}
+			if n := len(e.store.messages(sessionB.ID)); n != 0 {
+				t.Errorf("会话 B 保存了 %d 条消息", n)
+			}
+			if len(convB.History()) != 0 {
+				t.Errorf("会话 B 的历史 = %v，期望为空", convB.History())
+			}
+			if e.manager.Get(sessionA.ID) != nil {
+				t.Error("生成结束后仍在登记中")
+			}
+		})
+	}
+}
+
+func TestConversationReusesRunningGeneration(t *testing.T) {
+	e := newTestEnv(&config.QueueConfig{})
+	session := &models.Session{ID: "session-a"}
+
+	conv := e.manager.Conversation(session, nil)
+	g, done, received := e.start(session, conv, "你好")
+	<-received
+
+	// 生成进行中重新加载会话：沿用生成中的对话，数据库中的历史不会另起一份
+	stored := []*models.Message{models.NewMessage(models.RoleUser, "你好")}
+	if reloaded := e.manager.Conversation(session, stored); reloaded != conv {
+		t.Fatal("重新加载会话时创建了新的对话")
+	}
+	if text := g.PlaceholderText(); text != aitest.FirstChunk {
+		t.Errorf("占位消息 = %q，期望恢复已生成的内容", text)
+	}
+
+	e.model.Release()
+	if r := <-done; r.err != nil {
+		t.Fatalf("生成失败: %v", r.err)
+	}
+
+	// 生成结束后重新加载，使用数据库中的历史创建新的对话
+	reloaded := e.manager.Conversation(session, stored)
+	if reloaded == conv {
+		t.Fatal("生成结束后仍沿用旧的对话")
+	}
+	if history := reloaded.History(); len(history) != 1 || history[0] != stored[0] {
+		t.Errorf("新对话的历史 = %v", history)
+	}
+}
+
+func TestStopDiscardsDeletedSession(t *testing.T) {
+	e := newTestEnv(&config.QueueConfig{})
+	session := &models.Session{ID: "session-a"}
+
+	_, done, received := e.start(session, e.manager.Conversation(session, nil), "你好")
+	<-received
+
+	// 删除会话：停止生成并丢弃结果
+	e.manager.Stop(session.ID, true)
+	r := <-done
+	if !errors.Is(r.err, context.Canceled) {
+		t.Fatalf("生成错误 = %v，期望 context.Canceled", r.err)
+	}
+	if r.saved != nil {
+		t.Errorf("已删除的会话返回了保存的消息: %v", r.saved)
+	}
+	if n := len(e.store.messages(session.ID)); n != 0 {
+		t.Errorf("已删除的会话保存了 %d 条消息", n)
+	}
+	if e.manager.Get(session.ID) != nil {
+		t.Error("生成结束后仍在登记中")
+	}
+}
+
+func TestStopWhileQueuedKeepsUserMessage(t *testing.T) {
+	e := newTestEnv(&config.QueueConfig{MaxConcurrent: 1})
+	session := &models.Session{ID: "session-a"}
+
+	// 其他会话占用唯一的名额
+	release, err := e.queue.Acquire(context.Background(), "session-other", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release(nil)
+
+	queued := make(chan struct{}, 1)
+	e.queue.SetOnChange(func() {
+		if e.queue.Status(session.ID) == queue.StatusQueued {
+			select {
+			case queued <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	// 按界面发送消息的方式：用户消息先保存并写入对话历史，再排队生成回复
+	userMsg := models.NewMessage(models.RoleUser, "你好")
+	if err := e.store.SaveMessage(session.ID, userMsg); err != nil {
+		t.Fatal(err)
+	}
+	conv := e.manager.Conversation(session, []*models.Message{userMsg})
+	g, done, _ := e.run(session, conv, e.service.StreamReply)
+	<-queued
+
+	// 排队期间停止：不调用模型，不保存回复，用户消息仍在对话历史中
+	e.manager.Stop(session.ID, false)
+	r := <-done
+	if !errors.Is(r.err, context.Canceled) {
+		t.Fatalf("生成错误 = %v，期望 context.Canceled", r.err)
+	}
+	if !g.Queued() {
+		t.Error("排队期间停止的生成应仍标记为排队中")
+	}
+	if r.saved != nil {
+		t.Errorf("排队期间停止后保存了回复: %v", r.saved)
+	}
+	if n := len(e.model.Inputs()); n != 0 {
+		t.Errorf("排队期间停止后调用了 %d 次模型", n)
+	}
+	if saved := e.store.messages(session.ID); len(saved) != 1 || saved[0] != userMsg {
+		t.Errorf("会话保存的消息 = %v，期望只有用户消息", saved)
+	}
+	if history := conv.History(); len(history) != 1 || history[0] != userMsg {
+		t.Fatalf("对话历史 = %v，期望只有用户消息", history)
+	}
+
+	// 名额空出后在同一对话上重新生成：模型收到之前的用户消息
+	release(nil)
+	e.model.Release()
+	_, done, _ = e.run(session, conv, e.service.StreamReply)
+	if r := <-done; r.err != nil || r.saved == nil || r.saved.ParentID != userMsg.ID {
+		t.Fatalf("重新生成的结果 = %+v", r)
+	}
+	inputs := e.model.Inputs()
+	if len(inputs) != 1 {
+		t.Fatalf("模型被调用了 %d 次，期望 1 次", len(inputs))
+	}
+	if input := inputs[0]; len(input) != 1 || input[0].Content != userMsg.Content {
+		t.Errorf("模型收到的消息 = %v，期望包含之前的用户消息", input)
+	}
+}
@@ -0,0 +1,86 @@
+package knowledge
+
+import (
+	"strings"
+	"unicode"
+)
+
+// splitText 将文本切分为不超过 size 个字符的片段，相邻片段重叠 overlap 个字符
+// 优先在段落、句子边界处切分，找不到合适边界时按字符数硬切
+func splitText(text string, size, overlap int) []string {
+	runes := []rune(strings.TrimSpace(normalizeSpace(text)))
+	if len(runes) == 0 {
+		return nil
+	}
+	if overlap >= size {
+		overlap = size / 4
+	}
+
+	var chunks []string
+	for start := 0; start < len(runes); {
+		end := start + size
+		if end >= len(runes) {
+			end = len(runes)
+		} else {
+			end = breakPoint(runes, start+size/2, end)
+		}
+
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return chunks
+}
+
+// breakPoint 在 [min, max) 范围内从后向前寻找最佳切分位置：段落 > 句末标点 > 空白
+func breakPoint(runes []rune, min, max int) int {
+	for i := max - 1; i > min; i-- {
+		if runes[i] == '\n' && runes[i-1] == '\n' {
+			return i + 1
+		}
+	}
+	for i := max - 1; i > min; i-- {
+		switch runes[i] {
+		case '。', '！', '？', '；', '.', '!', '?', ';', '\n':
+			return i + 1
+		}
+	}
+	for i := max - 1; i > min; i-- {
+		if unicode.IsSpace(runes[i]) {
+			return i + 1
+		}
+	}
+	return max
+}
+
+// normalizeSpace 统一换行符并压缩多余空行，PDF 提取的文本常有大量空白
+func normalizeSpace(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+
+	var b strings.Builder
+	blank := 0
+	for _, line := range lines {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if line == "" {
+			blank++
+			if blank > 1 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
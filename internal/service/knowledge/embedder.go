@@ -0,0 +1,66 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino-ext/libs/acl/openai"
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/wangle201210/gochat/internal/config"
+)
+
+const (
+	defaultOllamaBaseURL  = "http://localhost:11434/v1"
+	defaultOllamaModel    = "nomic-embed-text"
+	defaultOpenAIModel    = "text-embedding-3-small"
+	defaultLocalDimension = 512
+)
+
+// NewEmbedder 按配置创建向量模型，同时返回模型标识（provider:model），标识变化时需要重建索引
+func NewEmbedder(ctx context.Context, cfg *config.EmbeddingConfig) (embedding.Embedder, string, error) {
+	provider := strings.ToLower(strings.TrimSpace(cfg.Provider))
+	if provider == "" {
+		provider = "ollama"
+	}
+
+	switch provider {
+	case "local":
+		return newHashEmbedder(defaultLocalDimension), fmt.Sprintf("local:hash-%d", defaultLocalDimension), nil
+
+	case "ollama", "openai":
+		embCfg := &openai.EmbeddingConfig{
+			APIKey:  cfg.APIKey,
+			BaseURL: cfg.BaseURL,
+			Model:   cfg.Model,
+		}
+		if provider == "ollama" {
+			if embCfg.BaseURL == "" {
+				embCfg.BaseURL = defaultOllamaBaseURL
+			}
+			if embCfg.Model == "" {
+				embCfg.Model = defaultOllamaModel
+			}
+			if embCfg.APIKey == "" {
+				// 兼容接口要求携带 Key，但 Ollama 不做校验
+				embCfg.APIKey = "ollama"
+			}
+		} else {
+			if embCfg.APIKey == "" {
+				return nil, "", fmt.Errorf("openai 向量模型需要配置 api_key")
+			}
+			if embCfg.Model == "" {
+				embCfg.Model = defaultOpenAIModel
+			}
+		}
+
+		embedder, err := openai.NewEmbeddingClient(ctx, embCfg)
+		if err != nil {
+			return nil, "", fmt.Errorf("初始化向量模型失败: %w", err)
+		}
+		return embedder, provider + ":" + embCfg.Model, nil
+
+	default:
+		return nil, "", fmt.Errorf("不支持的向量模型 provider: %q，可选: ollama, openai, local", cfg.Provider)
+	}
+}
@@ -0,0 +1,96 @@
+package knowledge
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// hashEmbedder 离线可用的向量模型：将词和字符二元组哈希到固定维度，效果不及语义模型，
+// 但无需网络和模型服务，适合没有向量服务时做关键词级别的检索
+type hashEmbedder struct {
+	dimension int
+}
+
+func newHashEmbedder(dimension int) *hashEmbedder {
+	return &hashEmbedder{dimension: dimension}
+}
+
+// EmbedStrings 实现 embedding.Embedder
+func (e *hashEmbedder) EmbedStrings(ctx context.Context, texts []string, _ ...embedding.Option) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+// embed 计算单段文本的归一化向量
+func (e *hashEmbedder) embed(text string) []float64 {
+	vector := make([]float64, e.dimension)
+	for _, token := range tokenize(text) {
+		h := fnv.New32a()
+		h.Write([]byte(token))
+		sum := h.Sum32()
+		// 最高位决定符号，减少哈希冲突带来的偏差
+		if sum&(1<<31) != 0 {
+			vector[int(sum%uint32(e.dimension))] -= 1
+		} else {
+			vector[int(sum%uint32(e.dimension))] += 1
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vector {
+			vector[i] /= norm
+		}
+	}
+	return vector
+}
+
+// tokenize 拉丁字母和数字按词切分，中日韩等文字按相邻二字切分
+func tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var prevHan rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			flushWord()
+			tokens = append(tokens, string(r))
+			if prevHan != 0 {
+				tokens = append(tokens, string([]rune{prevHan, r}))
+			}
+			prevHan = r
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+			prevHan = 0
+		default:
+			flushWord()
+			prevHan = 0
+		}
+	}
+	flushWord()
+
+	return tokens
+}
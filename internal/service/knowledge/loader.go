@@ -0,0 +1,64 @@
+package knowledge
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// supportedExtensions 可以建立索引的文件类型
+var supportedExtensions = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".txt":      true,
+	".pdf":      true,
+}
+
+// isSupported 判断文件是否可以建立索引
+func isSupported(path string) bool {
+	return supportedExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// loadText 读取文件的纯文本内容
+func loadText(path string) (string, error) {
+	if strings.ToLower(filepath.Ext(path)) == ".pdf" {
+		return loadPDF(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取文件失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// loadPDF 提取 PDF 中的文字，扫描版 PDF 没有文字层时返回空字符串
+func loadPDF(path string) (text string, err error) {
+	// 解析库遇到损坏的文件可能 panic
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("解析 PDF 失败: %v", r)
+		}
+	}()
+
+	f, reader, err := pdf.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("打开 PDF 失败: %w", err)
+	}
+	defer f.Close()
+
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", fmt.Errorf("解析 PDF 失败: %w", err)
+	}
+
+	data, err := io.ReadAll(plain)
+	if err != nil {
+		return "", fmt.Errorf("读取 PDF 文字失败: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,370 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/wangle201210/gochat/internal/config"
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+const (
+	defaultChunkSize    = 800
+	defaultChunkOverlap = 100
+	defaultTopK         = 4
+	defaultMinScore     = 0.3
+
+	// embedBatchSize 单次向量化请求的片段数
+	embedBatchSize = 16
+)
+
+// Store 持久化知识库、文件索引和片段向量
+type Store interface {
+	GetKnowledgeBase(id string) (*models.KnowledgeBase, error)
+	SaveKnowledgeBase(kb *models.KnowledgeBase) error
+	ListKnowledgeDocuments(kbID string) ([]*models.KnowledgeDocument, error)
+	ReplaceKnowledgeDocument(kbID string, doc *models.KnowledgeDocument, chunks []*models.KnowledgeChunk) error
+	DeleteKnowledgeDocument(kbID, path string) error
+	GetKnowledgeChunks(kbID string) ([]*models.KnowledgeChunk, error)
+}
+
+// Service 本地知识库服务：为文件夹建立向量索引并按问题检索相关片段
+type Service struct {
+	store    Store
+	embedder embedding.Embedder
+	modelID  string // 向量模型标识，与知识库记录的不一致时需要重建索引
+	config   *config.KnowledgeConfig
+
+	mu    sync.Mutex
+	cache map[string][]*models.KnowledgeChunk // 按知识库缓存的片段，索引变化后失效
+}
+
+// NewService 创建知识库服务
+func NewService(store Store, embedder embedding.Embedder, modelID string, cfg *config.KnowledgeConfig) *Service {
+	return &Service{
+		store:    store,
+		embedder: embedder,
+		modelID:  modelID,
+		config:   cfg,
+		cache:    make(map[string][]*models.KnowledgeChunk),
+	}
+}
+
+// IndexProgress 索引进度
+type IndexProgress struct {
+	Done  int    // 已处理的文件数
+	Total int    // 文件总数
+	Path  string // 正在处理的文件
+}
+
+// IndexResult 一次索引的统计
+type IndexResult struct {
+	Indexed int // 新建或更新索引的文件数
+	Skipped int // 未变化而跳过的文件数
+	Removed int // 已从文件夹删除、移出索引的文件数
+	Failed  int // 读取或向量化失败的文件数
+}
+
+// Index 增量索引知识库文件夹：只处理新增或修改过的文件，并移除已删除文件的索引
+// 向量模型变化时重建全部索引；progress 可为空
+func (s *Service) Index(ctx context.Context, kb *models.KnowledgeBase, progress func(IndexProgress)) (*IndexResult, error) {
+	defer s.invalidate(kb.ID)
+
+	files, err := scanFolder(kb.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	indexed, err := s.store.ListKnowledgeDocuments(kb.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 向量模型变化后旧向量无法与新问题比较，需要全部重建
+	if kb.EmbeddingModel != s.modelID {
+		for _, doc := range indexed {
+			if err := s.store.DeleteKnowledgeDocument(kb.ID, doc.Path); err != nil {
+				return nil, err
+			}
+		}
+		indexed = nil
+
+		kb.EmbeddingModel = s.modelID
+		kb.UpdatedAt = time.Now()
+		if err := s.store.SaveKnowledgeBase(kb); err != nil {
+			return nil, err
+		}
+	}
+
+	existing := make(map[string]*models.KnowledgeDocument, len(indexed))
+	for _, doc := range indexed {
+		existing[doc.Path] = doc
+	}
+
+	result := &IndexResult{}
+	for i, file := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if progress != nil {
+			progress(IndexProgress{Done: i, Total: len(files), Path: file.Path})
+		}
+
+		if doc, ok := existing[file.Path]; ok && doc.Size == file.Size && doc.ModTime.Equal(file.ModTime) {
+			result.Skipped++
+			continue
+		}
+
+		if err := s.indexFile(ctx, kb, file); err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			log.Printf("索引文件 %s 失败: %v", file.Path, err)
+			result.Failed++
+			continue
+		}
+		result.Indexed++
+	}
+
+	// 移除已不在文件夹中的文件
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[file.Path] = true
+	}
+	for path := range existing {
+		if present[path] {
+			continue
+		}
+		if err := s.store.DeleteKnowledgeDocument(kb.ID, path); err != nil {
+			return result, err
+		}
+		result.Removed++
+	}
+
+	if progress != nil {
+		progress(IndexProgress{Done: len(files), Total: len(files)})
+	}
+
+	return result, nil
+}
+
+// indexFile 读取、切分并向量化单个文件，替换其原有索引
+func (s *Service) indexFile(ctx context.Context, kb *models.KnowledgeBase, file *models.KnowledgeDocument) error {
+	text, err := loadText(filepath.Join(kb.Path, filepath.FromSlash(file.Path)))
+	if err != nil {
+		return err
+	}
+
+	pieces := splitText(text, s.chunkSize(), s.chunkOverlap())
+	chunks := make([]*models.KnowledgeChunk, 0, len(pieces))
+	for start := 0; start < len(pieces); start += embedBatchSize {
+		batch := pieces[start:min(start+embedBatchSize, len(pieces))]
+		vectors, err := s.embedder.EmbedStrings(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("向量化失败: %w", err)
+		}
+		if len(vectors) != len(batch) {
+			return fmt.Errorf("向量化失败: 期望 %d 个向量，实际返回 %d 个", len(batch), len(vectors))
+		}
+
+		for i, content := range batch {
+			chunks = append(chunks, &models.KnowledgeChunk{
+				DocumentPath: file.Path,
+				Seq:          start + i,
+				Content:      content,
+				Embedding:    toFloat32(vectors[i]),
+			})
+		}
+	}
+
+	return s.store.ReplaceKnowledgeDocument(kb.ID, file, chunks)
+}
+
+// Retrieve 检索与问题最相关的片段，按相似度从高到低返回
+func (s *Service) Retrieve(ctx context.Context, kbID, query string) ([]models.Citation, error) {
+	query = strings.TrimSpace(query)
+	if kbID == "" || query == "" {
+		return nil, nil
+	}
+
+	kb, err := s.store.GetKnowledgeBase(kbID)
+	if err != nil {
+		return nil, err
+	}
+	if kb == nil {
+		return nil, fmt.Errorf("知识库不存在: %s", kbID)
+	}
+	if kb.EmbeddingModel != s.modelID {
+		return nil, fmt.Errorf("知识库 %s 的索引使用的向量模型与当前配置不一致，请重建索引", kb.Name)
+	}
+
+	chunks, err := s.chunks(kbID)
+	if err != nil || len(chunks) == 0 {
+		return nil, err
+	}
+
+	vectors, err := s.embedder.EmbedStrings(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("向量化问题失败: %w", err)
+	}
+	if len(vectors) == 0 {
+		return nil, nil
+	}
+	queryVector := toFloat32(vectors[0])
+
+	// 片段数量通常不大，直接暴力计算余弦相似度
+	type scored struct {
+		chunk *models.KnowledgeChunk
+		score float64
+	}
+	minScore := s.config.MinScore
+	if minScore <= 0 {
+		minScore = defaultMinScore
+	}
+	candidates := make([]scored, 0, len(chunks))
+	for _, chunk := range chunks {
+		if score := cosine(queryVector, chunk.Embedding); score >= minScore {
+			candidates = append(candidates, scored{chunk: chunk, score: score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	topK := s.config.TopK
+	if topK <= 0 {
+		topK = defaultTopK
+	}
+	candidates = candidates[:min(topK, len(candidates))]
+
+	citations := make([]models.Citation, 0, len(candidates))
+	for i, c := range candidates {
+		citations = append(citations, models.Citation{
+			Index:   i + 1,
+			Source:  c.chunk.DocumentPath,
+			Score:   c.score,
+			Content: c.chunk.Content,
+		})
+	}
+	return citations, nil
+}
+
+// chunks 获取知识库的全部片段，优先使用缓存
+func (s *Service) chunks(kbID string) ([]*models.KnowledgeChunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cached, ok := s.cache[kbID]; ok {
+		return cached, nil
+	}
+
+	chunks, err := s.store.GetKnowledgeChunks(kbID)
+	if err != nil {
+		return nil, err
+	}
+	s.cache[kbID] = chunks
+	return chunks, nil
+}
+
+// invalidate 清除知识库的片段缓存
+func (s *Service) invalidate(kbID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cache, kbID)
+}
+
+// ModelID 返回当前向量模型标识
+func (s *Service) ModelID() string {
+	return s.modelID
+}
+
+func (s *Service) chunkSize() int {
+	if s.config.ChunkSize > 0 {
+		return s.config.ChunkSize
+	}
+	return defaultChunkSize
+}
+
+func (s *Service) chunkOverlap() int {
+	if s.config.ChunkOverlap > 0 {
+		return s.config.ChunkOverlap
+	}
+	return defaultChunkOverlap
+}
+
+// scanFolder 列出文件夹下所有可索引的文件，跳过隐藏文件和目录，路径相对于文件夹并使用 / 分隔
+func scanFolder(root string) ([]*models.KnowledgeDocument, error) {
+	var files []*models.KnowledgeDocument
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !isSupported(path) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, &models.KnowledgeDocument{
+			Path:    filepath.ToSlash(rel),
+			ModTime: info.ModTime(),
+			Size:    info.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("知识库文件夹不存在: %s", root)
+		}
+		return nil, fmt.Errorf("扫描知识库文件夹失败: %w", err)
+	}
+	return files, nil
+}
+
+// toFloat32 向量按 float32 存储，节省一半空间
+func toFloat32(vector []float64) []float32 {
+	result := make([]float32, len(vector))
+	for i, v := range vector {
+		result[i] = float32(v)
+	}
+	return result
+}
+
+// cosine 计算余弦相似度，维度不一致时返回 0
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,131 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wangle201210/gochat/internal/config"
+	"github.com/wangle201210/gochat/internal/service/tools"
+)
+
+// startTimeout 单个 MCP 服务启动、握手并列出工具的超时时间
+const startTimeout = 30 * time.Second
+
+// Manager 管理通过 stdio 启动的 MCP 服务，并将它们提供的工具注册到工具注册表
+type Manager struct {
+	mu      sync.Mutex
+	clients map[string]*client.Client
+	closed  bool
+}
+
+// NewManager 创建 MCP 服务管理器
+func NewManager() *Manager {
+	return &Manager{
+		clients: make(map[string]*client.Client),
+	}
+}
+
+// Start 启动配置中的 MCP 服务并注册其工具，工具以服务名称作为分组
+// 单个服务启动失败只记录日志，不影响其他服务
+func (m *Manager) Start(ctx context.Context, servers []config.MCPServerConfig, registry *tools.Registry) {
+	for _, server := range servers {
+		if server.Disabled {
+			continue
+		}
+		if err := m.startServer(ctx, server, registry); err != nil {
+			log.Printf("启动 MCP 服务 %s 失败: %v", server.Name, err)
+		}
+	}
+}
+
+// startServer 启动单个 MCP 服务，完成握手后注册它提供的全部工具
+func (m *Manager) startServer(ctx context.Context, server config.MCPServerConfig, registry *tools.Registry) error {
+	if server.Name == "" || server.Command == "" {
+		return fmt.Errorf("MCP 服务配置缺少 name 或 command")
+	}
+
+	env := make([]string, 0, len(server.Env))
+	for k, v := range server.Env {
+		env = append(env, k+"="+v)
+	}
+
+	c, err := client.NewStdioMCPClient(server.Command, env, server.Args...)
+	if err != nil {
+		return fmt.Errorf("启动进程失败: %w", err)
+	}
+
+	// 持续读取服务的标准错误输出，避免管道写满阻塞子进程
+	if stderr, ok := client.GetStderr(c); ok {
+		go logStderr(server.Name, stderr)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: "gochat", Version: "1.0.0"}
+	if _, err := c.Initialize(ctx, initRequest); err != nil {
+		c.Close()
+		return fmt.Errorf("初始化失败: %w", err)
+	}
+
+	result, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		c.Close()
+		return fmt.Errorf("获取工具列表失败: %w", err)
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return c.Close()
+	}
+	m.clients[server.Name] = c
+	m.mu.Unlock()
+
+	for _, t := range result.Tools {
+		wrapped, err := newTool(c, server.Name, t)
+		if err != nil {
+			log.Printf("MCP 服务 %s 的工具 %s 无法使用: %v", server.Name, t.Name, err)
+			continue
+		}
+		if err := registry.RegisterGroup(ctx, server.Name, wrapped); err != nil {
+			log.Printf("注册 MCP 工具失败: %v", err)
+		}
+	}
+
+	log.Printf("MCP 服务 %s 已启动，提供 %d 个工具", server.Name, len(result.Tools))
+	return nil
+}
+
+// Close 关闭所有 MCP 服务进程
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	var firstErr error
+	for name, c := range m.clients {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("关闭 MCP 服务 %s 失败: %w", name, err)
+		}
+	}
+	m.clients = make(map[string]*client.Client)
+	return firstErr
+}
+
+// logStderr 将 MCP 服务的标准错误输出逐行写入日志
+func logStderr(name string, stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		log.Printf("[MCP %s] %s", name, scanner.Text())
+	}
+}
@@ -0,0 +1,124 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxToolNameLength 模型接口允许的工具名称最大长度
+const maxToolNameLength = 64
+
+// invalidToolNameChars 模型接口要求工具名称只包含字母、数字、下划线和连字符
+var invalidToolNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// mcpTool 将 MCP 服务提供的工具适配为 Eino 工具
+type mcpTool struct {
+	client *client.Client
+	name   string // MCP 服务中的原始工具名称
+	info   *schema.ToolInfo
+}
+
+// newTool 根据 MCP 工具描述创建 Eino 工具，名称加上服务名前缀以避免不同服务间重名
+func newTool(c *client.Client, server string, t mcp.Tool) (*mcpTool, error) {
+	// MarshalJSON 会优先使用 RawInputSchema，保留服务端提供的完整 JSON Schema
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, fmt.Errorf("序列化工具描述失败: %w", err)
+	}
+	var raw struct {
+		InputSchema json.RawMessage `json:"inputSchema"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析工具描述失败: %w", err)
+	}
+
+	params := &jsonschema.Schema{}
+	if err := json.Unmarshal(raw.InputSchema, params); err != nil {
+		return nil, fmt.Errorf("解析参数定义失败: %w", err)
+	}
+
+	return &mcpTool{
+		client: c,
+		name:   t.Name,
+		info: &schema.ToolInfo{
+			Name:        toolName(server, t.Name),
+			Desc:        t.Description,
+			ParamsOneOf: schema.NewParamsOneOfByJSONSchema(params),
+		},
+	}, nil
+}
+
+// Info 返回工具描述
+func (t *mcpTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return t.info, nil
+}
+
+// InvokableRun 调用 MCP 工具，返回结果中的文本内容
+func (t *mcpTool) InvokableRun(ctx context.Context, argumentsInJSON string, _ ...tool.Option) (string, error) {
+	var args map[string]any
+	if strings.TrimSpace(argumentsInJSON) != "" {
+		if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+			return "", fmt.Errorf("解析工具参数失败: %w", err)
+		}
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = t.name
+	request.Params.Arguments = args
+
+	result, err := t.client.CallTool(ctx, request)
+	if err != nil {
+		return "", err
+	}
+
+	text := resultText(result)
+	if result.IsError {
+		return "", errors.New(text)
+	}
+	return text, nil
+}
+
+// resultText 将工具结果转换为文本，非文本内容以占位说明代替
+func resultText(result *mcp.CallToolResult) string {
+	parts := make([]string, 0, len(result.Content))
+	for _, content := range result.Content {
+		if text, ok := mcp.AsTextContent(content); ok {
+			parts = append(parts, text.Text)
+		} else if image, ok := mcp.AsImageContent(content); ok {
+			parts = append(parts, fmt.Sprintf("[图片 %s]", image.MIMEType))
+		} else if resource, ok := mcp.AsEmbeddedResource(content); ok {
+			if text, ok := mcp.AsTextResourceContents(resource.Resource); ok {
+				parts = append(parts, text.Text)
+			} else {
+				parts = append(parts, "[二进制资源]")
+			}
+		}
+	}
+
+	// 只返回结构化内容的工具
+	if len(parts) == 0 && result.StructuredContent != nil {
+		if data, err := json.Marshal(result.StructuredContent); err == nil {
+			parts = append(parts, string(data))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// toolName 生成 "服务名__工具名" 形式的工具名称
+func toolName(server, name string) string {
+	full := invalidToolNameChars.ReplaceAllString(server+"__"+name, "_")
+	if len(full) > maxToolNameLength {
+		full = full[:maxToolNameLength]
+	}
+	return full
+}
@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/wangle201210/gochat/internal/config"
+)
+
+const (
+	defaultAnthropicBaseURL   = "https://api.anthropic.com/v1/"
+	defaultAnthropicMaxTokens = 4096
+)
+
+func init() {
+	Register("anthropic", Provider{New: newAnthropic, RequiresAPIKey: true})
+}
+
+// newAnthropic 通过 Anthropic 的 OpenAI SDK 兼容接口创建 ChatModel
+func newAnthropic(ctx context.Context, cfg *config.ModelConfig) (model.ToolCallingChatModel, error) {
+	// Anthropic 要求每次请求都指定 max_tokens
+	maxTokens := defaultAnthropicMaxTokens
+	modelCfg := &openai.ChatModelConfig{
+		BaseURL: baseURLOrDefault(cfg.BaseURL, defaultAnthropicBaseURL),
+		Model:   cfg.Model,
+		APIKey:  cfg.APIKey,
+	}
+
+	if opts := cfg.Anthropic; opts != nil {
+		if opts.MaxTokens > 0 {
+			maxTokens = opts.MaxTokens
+		}
+		if opts.ThinkingBudget > 0 {
+			modelCfg.ExtraFields = map[string]any{
+				"thinking": map[string]any{
+					"type":          "enabled",
+					"budget_tokens": opts.ThinkingBudget,
+				},
+			}
+		}
+		modelCfg.Timeout = seconds(opts.Timeout)
+	}
+	modelCfg.MaxTokens = &maxTokens
+
+	return openai.NewChatModel(ctx, modelCfg)
+}
@@ -0,0 +1,35 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/wangle201210/gochat/internal/config"
+)
+
+const defaultArkRegion = "cn-beijing"
+
+func init() {
+	Register("ark", Provider{New: newArk, RequiresAPIKey: true})
+}
+
+// newArk 通过火山方舟的 OpenAI 兼容接口创建 ChatModel，Model 填写推理接入点 ID 或模型名
+func newArk(ctx context.Context, cfg *config.ModelConfig) (model.ToolCallingChatModel, error) {
+	region := defaultArkRegion
+	var timeout int
+	if opts := cfg.Ark; opts != nil {
+		if opts.Region != "" {
+			region = opts.Region
+		}
+		timeout = opts.Timeout
+	}
+
+	return openai.NewChatModel(ctx, &openai.ChatModelConfig{
+		BaseURL: baseURLOrDefault(cfg.BaseURL, fmt.Sprintf("https://ark.%s.volces.com/api/v3", region)),
+		Model:   cfg.Model,
+		APIKey:  cfg.APIKey,
+		Timeout: seconds(timeout),
+	})
+}
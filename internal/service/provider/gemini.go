@@ -0,0 +1,31 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/wangle201210/gochat/internal/config"
+)
+
+const defaultGeminiBaseURL = "https://generativelanguage.googleapis.com/v1beta/openai/"
+
+func init() {
+	Register("gemini", Provider{New: newGemini, RequiresAPIKey: true})
+}
+
+// newGemini 通过 Gemini 的 OpenAI 兼容接口创建 ChatModel
+func newGemini(ctx context.Context, cfg *config.ModelConfig) (model.ToolCallingChatModel, error) {
+	modelCfg := &openai.ChatModelConfig{
+		BaseURL: baseURLOrDefault(cfg.BaseURL, defaultGeminiBaseURL),
+		Model:   cfg.Model,
+		APIKey:  cfg.APIKey,
+	}
+
+	if opts := cfg.Gemini; opts != nil {
+		modelCfg.ReasoningEffort = openai.ReasoningEffortLevel(opts.ReasoningEffort)
+		modelCfg.Timeout = seconds(opts.Timeout)
+	}
+
+	return openai.NewChatModel(ctx, modelCfg)
+}
@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/wangle201210/gochat/internal/config"
+)
+
+const defaultOllamaHost = "http://localhost:11434"
+
+func init() {
+	Register("ollama", Provider{New: newOllama})
+}
+
+// newOllama 通过 Ollama 的 OpenAI 兼容接口（/v1）创建 ChatModel，本地服务无需 API Key
+func newOllama(ctx context.Context, cfg *config.ModelConfig) (model.ToolCallingChatModel, error) {
+	host := defaultOllamaHost
+	var timeout int
+	if opts := cfg.Ollama; opts != nil {
+		if opts.Host != "" {
+			host = opts.Host
+		}
+		timeout = opts.Timeout
+	}
+
+	apiKey := cfg.APIKey
+	if apiKey == "" {
+		// 兼容接口要求携带 Key，但 Ollama 不做校验
+		apiKey = "ollama"
+	}
+
+	return openai.NewChatModel(ctx, &openai.ChatModelConfig{
+		BaseURL: baseURLOrDefault(cfg.BaseURL, strings.TrimRight(host, "/")+"/v1"),
+		Model:   cfg.Model,
+		APIKey:  apiKey,
+		Timeout: seconds(timeout),
+	})
+}
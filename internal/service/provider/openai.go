@@ -0,0 +1,46 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/wangle201210/gochat/internal/config"
+)
+
+const defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+func init() {
+	Register("openai", Provider{New: newOpenAI, RequiresAPIKey: true})
+}
+
+// newOpenAI 创建 OpenAI（及 OpenAI 兼容接口）的 ChatModel
+func newOpenAI(ctx context.Context, cfg *config.ModelConfig) (model.ToolCallingChatModel, error) {
+	modelCfg := &openai.ChatModelConfig{
+		BaseURL: baseURLOrDefault(cfg.BaseURL, defaultOpenAIBaseURL),
+		Model:   cfg.Model,
+		APIKey:  cfg.APIKey,
+	}
+
+	if opts := cfg.OpenAI; opts != nil {
+		modelCfg.ByAzure = opts.ByAzure
+		modelCfg.APIVersion = opts.APIVersion
+		modelCfg.Timeout = seconds(opts.Timeout)
+	}
+
+	return openai.NewChatModel(ctx, modelCfg)
+}
+
+// baseURLOrDefault 配置了 BaseURL 时优先使用配置值
+func baseURLOrDefault(baseURL, defaultURL string) string {
+	if baseURL != "" {
+		return baseURL
+	}
+	return defaultURL
+}
+
+// seconds 将秒数转换为 time.Duration，0 表示不限制
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
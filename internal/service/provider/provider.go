@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/wangle201210/gochat/internal/config"
+)
+
+// Factory 根据配置创建 ChatModel
+// 内置的 provider 都使用 eino 的 OpenAI ChatModel 访问服务商的 OpenAI 兼容接口，
+// 需要原生接口的专属能力时可用对应的 eino-ext 组件重新 Register 覆盖
+type Factory func(ctx context.Context, cfg *config.ModelConfig) (model.ToolCallingChatModel, error)
+
+// Provider 一个已注册的模型服务提供商
+type Provider struct {
+	New            Factory // ChatModel 构造函数
+	RequiresAPIKey bool    // 是否必须配置 API Key
+}
+
+var (
+	mu        sync.RWMutex
+	providers = make(map[string]Provider)
+)
+
+// Register 注册 provider，重复注册会覆盖之前的实现
+func Register(name string, p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[strings.ToLower(name)] = p
+}
+
+// Names 返回所有已注册的 provider 名称（按字母排序）
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Name 返回 provider 的规范名称：忽略大小写和首尾空白，为空时视为 "openai"
+func Name(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "openai"
+	}
+	return name
+}
+
+// Lookup 查找 provider，名称为空时视为 "openai"
+func Lookup(name string) (Provider, error) {
+	name = Name(name)
+
+	mu.RLock()
+	p, ok := providers[name]
+	mu.RUnlock()
+	if !ok {
+		return Provider{}, fmt.Errorf("不支持的模型 provider: %q，已注册的 provider: %s", name, strings.Join(Names(), ", "))
+	}
+	return p, nil
+}
+
+// RequiresAPIKey 判断 provider 是否必须配置 API Key，未知 provider 视为需要
+func RequiresAPIKey(name string) bool {
+	p, err := Lookup(name)
+	if err != nil {
+		return true
+	}
+	return p.RequiresAPIKey
+}
+
+// NewChatModel 按配置中的 provider 创建 ChatModel
+func NewChatModel(ctx context.Context, cfg *config.ModelConfig) (model.ToolCallingChatModel, error) {
+	p, err := Lookup(cfg.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	chatModel, err := p.New(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("创建 %s 模型失败: %w", cfg.Provider, err)
+	}
+	return chatModel, nil
+}
@@ -0,0 +1,263 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/wangle201210/gochat/internal/config"
+	"github.com/wangle201210/gochat/internal/service/provider"
+)
+
+// Status 会话中生成任务的状态
+type Status string
+
+const (
+	StatusQueued  Status = "queued"  // 等待空闲名额或 provider 限速
+	StatusRunning Status = "running" // 正在生成
+	StatusDone    Status = "done"    // 已完成（包括用户中止），会话被查看后清除
+	StatusFailed  Status = "failed"  // 生成出错，会话被查看后清除
+)
+
+// ErrCleared 排队中的任务因会话被清除而取消
+var ErrCleared = errors.New("会话已从生成队列中清除")
+
+// defaultMaxConcurrent 默认同时进行的生成数
+const defaultMaxConcurrent = 3
+
+// rateWindow provider 限速的统计窗口
+const rateWindow = time.Minute
+
+// waiter 一个等待开始的生成任务
+type waiter struct {
+	sessionID string
+	provider  string
+	ready     chan struct{} // 轮到该任务或任务被清除时关闭
+	granted   bool
+}
+
+// Queue 跨会话的生成队列：限制同时进行的生成数和各 provider 每分钟开始的生成数，
+// 任务按提交顺序开始，被限速的 provider 不会阻塞其他 provider 的任务；
+// 各会话的任务状态保存在队列中，与界面的显示状态无关。方法可在多个 goroutine 中并发调用
+type Queue struct {
+	mu            sync.Mutex
+	maxConcurrent int
+	rateLimits    map[string]int // 键为 provider 规范名称
+	window        time.Duration  // 限速的统计窗口
+	running       int
+	pending       []*waiter
+	starts        map[string][]time.Time // 各 provider 在统计窗口内开始生成的时间
+	statuses      map[string]Status
+	timer         *time.Timer // 被限速的任务到期后重新调度
+	onChange      func()
+}
+
+// New 创建生成队列
+func New(cfg *config.QueueConfig) *Queue {
+	maxConcurrent := cfg.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultMaxConcurrent
+	}
+	rateLimits := make(map[string]int, len(cfg.RateLimits))
+	for name, limit := range cfg.RateLimits {
+		rateLimits[provider.Name(name)] = limit
+	}
+	return &Queue{
+		maxConcurrent: maxConcurrent,
+		rateLimits:    rateLimits,
+		window:        rateWindow,
+		starts:        make(map[string][]time.Time),
+		statuses:      make(map[string]Status),
+	}
+}
+
+// SetOnChange 设置任务状态变化时的回调，回调在任意 goroutine 中调用
+func (q *Queue) SetOnChange(onChange func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onChange = onChange
+}
+
+// Acquire 为会话登记一个生成任务并等待轮到它，providerName 为处理该会话请求的 provider；
+// ctx 取消时放弃排队并返回 ctx 的错误，会话被 Clear 清除时返回 ErrCleared
+// 成功时返回的 done 必须在生成结束后调用，err 为空或为 context.Canceled 时记为完成，否则记为失败
+func (q *Queue) Acquire(ctx context.Context, sessionID, providerName string) (done func(err error), err error) {
+	w := &waiter{sessionID: sessionID, provider: provider.Name(providerName), ready: make(chan struct{})}
+
+	q.mu.Lock()
+	q.pending = append(q.pending, w)
+	q.statuses[sessionID] = StatusQueued
+	q.dispatch()
+	q.mu.Unlock()
+	q.notify()
+
+	select {
+	case <-w.ready:
+		if !w.granted {
+			return nil, ErrCleared
+		}
+	case <-ctx.Done():
+		q.mu.Lock()
+		if w.granted {
+			// 取消的同时刚好轮到，归还名额
+			q.running--
+		} else {
+			q.pending = slices.DeleteFunc(q.pending, func(p *waiter) bool { return p == w })
+		}
+		delete(q.statuses, sessionID)
+		q.dispatch()
+		q.mu.Unlock()
+		q.notify()
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { q.release(sessionID, err) })
+	}, nil
+}
+
+// release 生成结束后归还名额并记录结果
+func (q *Queue) release(sessionID string, err error) {
+	q.mu.Lock()
+	q.running--
+	if err == nil || errors.Is(err, context.Canceled) {
+		q.statuses[sessionID] = StatusDone
+	} else {
+		q.statuses[sessionID] = StatusFailed
+	}
+	q.dispatch()
+	q.mu.Unlock()
+	q.notify()
+}
+
+// Status 返回会话的任务状态，没有任务时返回空
+func (q *Queue) Status(sessionID string) Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.statuses[sessionID]
+}
+
+// Statuses 返回所有有任务的会话及其状态
+func (q *Queue) Statuses() map[string]Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	statuses := make(map[string]Status, len(q.statuses))
+	for id, status := range q.statuses {
+		statuses[id] = status
+	}
+	return statuses
+}
+
+// MarkSeen 会话已被查看，清除已结束任务的状态（完成或失败），排队和进行中的任务不受影响
+func (q *Queue) MarkSeen(sessionID string) {
+	q.mu.Lock()
+	status := q.statuses[sessionID]
+	cleared := status == StatusDone || status == StatusFailed
+	if cleared {
+		delete(q.statuses, sessionID)
+	}
+	q.mu.Unlock()
+
+	if cleared {
+		q.notify()
+	}
+}
+
+// Clear 清除会话在队列中的记录（会话已删除）：取消排队中的任务，其 Acquire 返回 ErrCleared，
+// 并清除已结束任务的状态；进行中的任务由调用方停止，结束时照常归还名额
+func (q *Queue) Clear(sessionID string) {
+	q.mu.Lock()
+	q.pending = slices.DeleteFunc(q.pending, func(w *waiter) bool {
+		if w.sessionID != sessionID {
+			return false
+		}
+		close(w.ready)
+		return true
+	})
+	if q.statuses[sessionID] != StatusRunning {
+		delete(q.statuses, sessionID)
+	}
+	q.mu.Unlock()
+	q.notify()
+}
+
+// dispatch 按提交顺序开始有空闲名额且未被限速的任务，调用方需持有锁
+// 返回是否有任务开始；因限速未能开始的任务在最早可用的时间重新调度
+func (q *Queue) dispatch() bool {
+	now := time.Now()
+	started := false
+	var wake time.Time
+
+	for i := 0; i < len(q.pending) && q.running < q.maxConcurrent; {
+		w := q.pending[i]
+		if next, ok := q.allow(w.provider, now); !ok {
+			if wake.IsZero() || next.Before(wake) {
+				wake = next
+			}
+			i++
+			continue
+		}
+
+		q.pending = slices.Delete(q.pending, i, i+1)
+		q.running++
+		if q.rateLimits[w.provider] > 0 {
+			q.starts[w.provider] = append(q.starts[w.provider], now)
+		}
+		w.granted = true
+		q.statuses[w.sessionID] = StatusRunning
+		close(w.ready)
+		started = true
+	}
+
+	if !wake.IsZero() {
+		if q.timer != nil {
+			q.timer.Stop()
+		}
+		q.timer = time.AfterFunc(wake.Sub(now), func() {
+			q.mu.Lock()
+			started := q.dispatch()
+			q.mu.Unlock()
+			if started {
+				q.notify()
+			}
+		})
+	}
+	return started
+}
+
+// allow 判断 provider 当前能否开始新的生成，不能时返回最早可以开始的时间，调用方需持有锁
+func (q *Queue) allow(provider string, now time.Time) (time.Time, bool) {
+	limit := q.rateLimits[provider]
+	if limit <= 0 {
+		return time.Time{}, true
+	}
+
+	// 丢弃统计窗口之外的记录
+	starts := q.starts[provider]
+	expired := 0
+	for expired < len(starts) && !starts[expired].Add(q.window).After(now) {
+		expired++
+	}
+	starts = starts[expired:]
+	q.starts[provider] = starts
+
+	if len(starts) < limit {
+		return time.Time{}, true
+	}
+	return starts[len(starts)-limit].Add(q.window), false
+}
+
+// notify 通知任务状态变化，不持有锁调用回调
+func (q *Queue) notify() {
+	q.mu.Lock()
+	onChange := q.onChange
+	q.mu.Unlock()
+
+	if onChange != nil {
+		onChange()
+	}
+}
@@ -0,0 +1,241 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/wangle201210/gochat/internal/config"
+)
+
+// waitTimeout 等待预期事件的最长时间
+const waitTimeout = 2 * time.Second
+
+// acquired 一次 Acquire 的结果
+type acquired struct {
+	sessionID string
+	done      func(error)
+	err       error
+}
+
+// acquireAsync 在后台为会话排队，等到任务登记进队列后返回
+func acquireAsync(t *testing.T, q *Queue, ctx context.Context, sessionID, provider string) <-chan acquired {
+	t.Helper()
+
+	result := make(chan acquired, 1)
+	go func() {
+		done, err := q.Acquire(ctx, sessionID, provider)
+		result <- acquired{sessionID: sessionID, done: done, err: err}
+	}()
+	waitFor(t, func() bool { return q.Status(sessionID) != "" })
+	return result
+}
+
+// waitFor 等待条件成立
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待超时")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// receive 等待任务轮到或返回错误
+func receive(t *testing.T, ch <-chan acquired) acquired {
+	t.Helper()
+
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(waitTimeout):
+		t.Fatal("等待任务开始超时")
+		return acquired{}
+	}
+}
+
+// assertWaiting 确认任务仍在排队
+func assertWaiting(t *testing.T, ch <-chan acquired) {
+	t.Helper()
+
+	select {
+	case r := <-ch:
+		t.Fatalf("会话 %s 不应开始（err=%v）", r.sessionID, r.err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestQueueConcurrencyCap(t *testing.T) {
+	q := New(&config.QueueConfig{MaxConcurrent: 2})
+	ctx := context.Background()
+
+	first, err := q.Acquire(ctx, "a", "openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Acquire(ctx, "b", "openai"); err != nil {
+		t.Fatal(err)
+	}
+
+	third := acquireAsync(t, q, ctx, "c", "openai")
+	assertWaiting(t, third)
+	if status := q.Status("c"); status != StatusQueued {
+		t.Errorf("超出并发数的任务状态 = %q，期望 %q", status, StatusQueued)
+	}
+
+	first(nil)
+	if r := receive(t, third); r.err != nil {
+		t.Fatal(r.err)
+	}
+	statuses := q.Statuses()
+	if statuses["a"] != StatusDone || statuses["b"] != StatusRunning || statuses["c"] != StatusRunning {
+		t.Errorf("任务状态 = %v", statuses)
+	}
+}
+
+func TestQueueDispatchesInOrder(t *testing.T) {
+	q := New(&config.QueueConfig{MaxConcurrent: 1})
+	ctx := context.Background()
+
+	hold, err := q.Acquire(ctx, "hold", "openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var waiters []<-chan acquired
+	for i := range 4 {
+		waiters = append(waiters, acquireAsync(t, q, ctx, fmt.Sprintf("s%d", i), "openai"))
+	}
+
+	hold(nil)
+	for i, ch := range waiters {
+		r := receive(t, ch)
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if want := fmt.Sprintf("s%d", i); r.sessionID != want {
+			t.Fatalf("第 %d 个开始的是 %s，期望 %s", i, r.sessionID, want)
+		}
+		// 前一个任务结束前，后面的任务都不能开始
+		for _, rest := range waiters[i+1:] {
+			assertWaiting(t, rest)
+		}
+		r.done(nil)
+	}
+}
+
+func TestQueueRateLimitWakesWaiter(t *testing.T) {
+	// 限速按 provider 规范名称统计：配置和调用方的大小写、空名称都视为同一个 provider
+	q := New(&config.QueueConfig{MaxConcurrent: 5, RateLimits: map[string]int{"OpenAI": 1}})
+	q.window = 100 * time.Millisecond
+	ctx := context.Background()
+
+	start := time.Now()
+	done, err := q.Acquire(ctx, "a", "openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done(nil)
+
+	limited := acquireAsync(t, q, ctx, "b", "")
+	if status := q.Status("b"); status != StatusQueued {
+		t.Errorf("被限速的任务状态 = %q，期望 %q", status, StatusQueued)
+	}
+
+	// 被限速的 provider 不阻塞其他 provider 的任务
+	other := receive(t, acquireAsync(t, q, ctx, "c", "ollama"))
+	if other.err != nil {
+		t.Fatal(other.err)
+	}
+	other.done(nil)
+
+	// 没有其他任务结束，由计时器在统计窗口过后唤醒
+	r := receive(t, limited)
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if elapsed := time.Since(start); elapsed < q.window {
+		t.Errorf("被限速的任务在 %v 后开始，早于统计窗口 %v", elapsed, q.window)
+	}
+	r.done(nil)
+}
+
+func TestQueueClearCancelsWaiters(t *testing.T) {
+	q := New(&config.QueueConfig{MaxConcurrent: 1})
+	ctx := context.Background()
+
+	hold, err := q.Acquire(ctx, "a", "openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiting := acquireAsync(t, q, ctx, "b", "openai")
+	next := acquireAsync(t, q, ctx, "c", "openai")
+
+	// 查看会话不影响排队中的任务
+	q.MarkSeen("b")
+	assertWaiting(t, waiting)
+
+	q.Clear("b")
+	if r := receive(t, waiting); !errors.Is(r.err, ErrCleared) {
+		t.Fatalf("清除后 Acquire 返回 %v，期望 ErrCleared", r.err)
+	}
+	if status := q.Status("b"); status != "" {
+		t.Errorf("清除后状态 = %q", status)
+	}
+
+	// 进行中的任务不受 Clear 影响，结束后照常归还名额
+	q.Clear("a")
+	if status := q.Status("a"); status != StatusRunning {
+		t.Errorf("进行中的任务被清除后状态 = %q", status)
+	}
+	assertWaiting(t, next)
+	hold(errors.New("生成失败"))
+	r := receive(t, next)
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+
+	if status := q.Status("a"); status != StatusFailed {
+		t.Errorf("失败的任务状态 = %q，期望 %q", status, StatusFailed)
+	}
+	q.MarkSeen("a")
+	if status := q.Status("a"); status != "" {
+		t.Errorf("查看后状态 = %q", status)
+	}
+	r.done(nil)
+}
+
+func TestQueueContextCancelWhileQueued(t *testing.T) {
+	q := New(&config.QueueConfig{MaxConcurrent: 1})
+
+	hold, err := q.Acquire(context.Background(), "a", "openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waiting := acquireAsync(t, q, ctx, "b", "openai")
+	cancel()
+	if r := receive(t, waiting); !errors.Is(r.err, context.Canceled) {
+		t.Fatalf("取消后 Acquire 返回 %v，期望 context.Canceled", r.err)
+	}
+	if status := q.Status("b"); status != "" {
+		t.Errorf("取消后状态 = %q", status)
+	}
+
+	// 取消的任务不占用名额，也不会在名额空出后开始
+	hold(nil)
+	done, err := q.Acquire(context.Background(), "c", "openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done(nil)
+	if statuses := q.Statuses(); len(statuses) != 2 || statuses["b"] != "" {
+		t.Errorf("任务状态 = %v", statuses)
+	}
+}
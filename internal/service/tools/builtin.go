@@ -0,0 +1,184 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+)
+
+// RegisterBuiltins 注册内置工具：当前时间和计算器
+func RegisterBuiltins(ctx context.Context, r *Registry) error {
+	builtins := []func() (tool.InvokableTool, error){
+		newCurrentTimeTool,
+		newCalculatorTool,
+	}
+
+	for _, build := range builtins {
+		t, err := build()
+		if err != nil {
+			return fmt.Errorf("创建内置工具失败: %w", err)
+		}
+		if err := r.Register(ctx, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// currentTimeInput current_time 工具的参数
+type currentTimeInput struct {
+	Timezone string `json:"timezone,omitempty" jsonschema_description:"IANA 时区名称，例如 Asia/Shanghai，留空使用本地时区"`
+}
+
+// newCurrentTimeTool 获取当前日期和时间，弥补模型不知道当前时间的问题
+func newCurrentTimeTool() (tool.InvokableTool, error) {
+	return utils.InferTool("current_time", "获取当前的日期、时间和星期",
+		func(ctx context.Context, input *currentTimeInput) (string, error) {
+			now := time.Now()
+			if input.Timezone != "" {
+				loc, err := time.LoadLocation(input.Timezone)
+				if err != nil {
+					return "", fmt.Errorf("无效的时区 %q: %w", input.Timezone, err)
+				}
+				now = now.In(loc)
+			}
+			return fmt.Sprintf("%s %s", now.Format("2006-01-02 15:04:05 MST"), now.Weekday()), nil
+		})
+}
+
+// calculatorInput calculator 工具的参数
+type calculatorInput struct {
+	Expression string `json:"expression" jsonschema:"required" jsonschema_description:"算术表达式，支持 + - * / %、括号以及 sqrt、pow、abs 函数，例如 (1.5+2)*pow(2,10)"`
+}
+
+// newCalculatorTool 精确计算算术表达式，避免模型心算出错
+func newCalculatorTool() (tool.InvokableTool, error) {
+	return utils.InferTool("calculator", "计算算术表达式并返回结果",
+		func(ctx context.Context, input *calculatorInput) (string, error) {
+			expr, err := parser.ParseExpr(input.Expression)
+			if err != nil {
+				return "", fmt.Errorf("表达式格式错误: %w", err)
+			}
+
+			value, err := evalExpr(expr)
+			if err != nil {
+				return "", err
+			}
+			return formatValue(value), nil
+		})
+}
+
+// evalExpr 对表达式求值，整数和有理数运算保持精确，函数调用按浮点数计算
+func evalExpr(expr ast.Expr) (constant.Value, error) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.INT && e.Kind != token.FLOAT {
+			return nil, fmt.Errorf("不支持的字面量: %s", e.Value)
+		}
+		return constant.MakeFromLiteral(e.Value, e.Kind, 0), nil
+
+	case *ast.ParenExpr:
+		return evalExpr(e.X)
+
+	case *ast.UnaryExpr:
+		x, err := evalExpr(e.X)
+		if err != nil {
+			return nil, err
+		}
+		if e.Op != token.ADD && e.Op != token.SUB {
+			return nil, fmt.Errorf("不支持的运算符: %s", e.Op)
+		}
+		return constant.UnaryOp(e.Op, x, 0), nil
+
+	case *ast.BinaryExpr:
+		x, err := evalExpr(e.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := evalExpr(e.Y)
+		if err != nil {
+			return nil, err
+		}
+		switch e.Op {
+		case token.ADD, token.SUB, token.MUL:
+			return constant.BinaryOp(x, e.Op, y), nil
+		case token.QUO:
+			if constant.Sign(y) == 0 {
+				return nil, fmt.Errorf("除数不能为 0")
+			}
+			return constant.BinaryOp(x, token.QUO, y), nil
+		case token.REM:
+			if x.Kind() != constant.Int || y.Kind() != constant.Int {
+				return nil, fmt.Errorf("取余运算只支持整数")
+			}
+			if constant.Sign(y) == 0 {
+				return nil, fmt.Errorf("除数不能为 0")
+			}
+			return constant.BinaryOp(x, token.REM, y), nil
+		default:
+			return nil, fmt.Errorf("不支持的运算符: %s", e.Op)
+		}
+
+	case *ast.CallExpr:
+		return evalCall(e)
+
+	default:
+		return nil, fmt.Errorf("不支持的表达式")
+	}
+}
+
+// evalCall 计算 sqrt、pow、abs 函数
+func evalCall(call *ast.CallExpr) (constant.Value, error) {
+	ident, ok := call.Fun.(*ast.Ident)
+	if !ok {
+		return nil, fmt.Errorf("不支持的函数调用")
+	}
+
+	args := make([]float64, 0, len(call.Args))
+	for _, arg := range call.Args {
+		v, err := evalExpr(arg)
+		if err != nil {
+			return nil, err
+		}
+		f, _ := constant.Float64Val(constant.ToFloat(v))
+		args = append(args, f)
+	}
+
+	var result float64
+	switch {
+	case ident.Name == "sqrt" && len(args) == 1:
+		if args[0] < 0 {
+			return nil, fmt.Errorf("sqrt 的参数不能为负数")
+		}
+		result = math.Sqrt(args[0])
+	case ident.Name == "pow" && len(args) == 2:
+		result = math.Pow(args[0], args[1])
+	case ident.Name == "abs" && len(args) == 1:
+		result = math.Abs(args[0])
+	default:
+		return nil, fmt.Errorf("不支持的函数或参数个数错误: %s", ident.Name)
+	}
+
+	if math.IsInf(result, 0) || math.IsNaN(result) {
+		return nil, fmt.Errorf("计算结果超出范围")
+	}
+	return constant.MakeFloat64(result), nil
+}
+
+// formatValue 格式化计算结果，整数结果不带小数部分
+func formatValue(v constant.Value) string {
+	if i := constant.ToInt(v); i.Kind() == constant.Int {
+		return i.ExactString()
+	}
+	f, _ := constant.Float64Val(constant.ToFloat(v))
+	return strconv.FormatFloat(f, 'g', 15, 64)
+}
@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Registry 工具注册表，按名称管理可供模型调用的工具
+// 工具可以归属于某个分组（例如提供它的 MCP 服务），以便按会话整组启用或禁用
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]*entry
+	names   []string // 注册顺序，保证每次提供给模型的工具列表稳定
+}
+
+// entry 注册表中的一个工具
+type entry struct {
+	tool  tool.InvokableTool
+	info  *schema.ToolInfo
+	group string // 所属分组，内置工具为空
+}
+
+// NewRegistry 创建空的工具注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		entries: make(map[string]*entry),
+	}
+}
+
+// Register 注册不属于任何分组的工具，名称重复时返回错误
+func (r *Registry) Register(ctx context.Context, t tool.InvokableTool) error {
+	return r.RegisterGroup(ctx, "", t)
+}
+
+// RegisterGroup 注册属于指定分组的工具，名称重复时返回错误
+func (r *Registry) RegisterGroup(ctx context.Context, group string, t tool.InvokableTool) error {
+	info, err := t.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("获取工具信息失败: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.entries[info.Name]; exists {
+		return fmt.Errorf("工具 %q 已注册", info.Name)
+	}
+
+	r.entries[info.Name] = &entry{tool: t, info: info, group: group}
+	r.names = append(r.names, info.Name)
+	return nil
+}
+
+// Names 返回已注册的工具名称（按注册顺序）
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string(nil), r.names...)
+}
+
+// Groups 返回已注册工具的分组（按注册顺序，不含空分组）
+func (r *Registry) Groups() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	groups := make([]string, 0)
+	for _, name := range r.names {
+		if group := r.entries[name].group; group != "" && !slices.Contains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// Infos 返回工具的描述，用于告知模型可调用的工具；disabledGroups 中分组的工具被排除
+func (r *Registry) Infos(disabledGroups ...string) []*schema.ToolInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]*schema.ToolInfo, 0, len(r.names))
+	for _, name := range r.names {
+		e := r.entries[name]
+		if e.group != "" && slices.Contains(disabledGroups, e.group) {
+			continue
+		}
+		infos = append(infos, e.info)
+	}
+	return infos
+}
+
+// Call 按名称调用工具，arguments 为模型生成的 JSON 参数
+func (r *Registry) Call(ctx context.Context, name, arguments string) (string, error) {
+	r.mu.RLock()
+	e, ok := r.entries[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("未知工具: %s", name)
+	}
+
+	result, err := e.tool.InvokableRun(ctx, arguments)
+	if err != nil {
+		return "", fmt.Errorf("调用工具 %s 失败: %w", name, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// saveAttachments 在保存消息的事务中写入消息携带的附件
+func saveAttachments(tx *sql.Tx, sessionID string, message *models.Message) error {
+	query := `
+	INSERT INTO attachments (id, message_id, session_id, name, mime_type, size, data, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	for _, a := range message.Attachments {
+		a.MessageID = message.ID
+		if _, err := tx.Exec(query, a.ID, a.MessageID, sessionID, a.Name, a.MimeType, a.Size, a.Data, a.CreatedAt); err != nil {
+			return fmt.Errorf("保存附件失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadAttachments 为消息列表填充附件
+func (d *Database) loadAttachments(sessionID string, messages []*models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	query := `
+	SELECT id, message_id, name, mime_type, size, data, created_at
+	FROM attachments
+	WHERE session_id = ?
+	ORDER BY created_at, rowid
+	`
+
+	rows, err := d.db.Query(query, sessionID)
+	if err != nil {
+		return fmt.Errorf("查询附件失败: %w", err)
+	}
+	defer rows.Close()
+
+	byMessage := make(map[string]*models.Message, len(messages))
+	for _, msg := range messages {
+		byMessage[msg.ID] = msg
+	}
+
+	for rows.Next() {
+		a := &models.Attachment{}
+		if err := rows.Scan(&a.ID, &a.MessageID, &a.Name, &a.MimeType, &a.Size, &a.Data, &a.CreatedAt); err != nil {
+			return fmt.Errorf("读取附件数据失败: %w", err)
+		}
+		if msg, ok := byMessage[a.MessageID]; ok {
+			msg.Attachments = append(msg.Attachments, a)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("遍历附件失败: %w", err)
+	}
+
+	return nil
+}
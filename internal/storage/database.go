@@ -2,6 +2,7 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,7 +14,8 @@ import (
 
 // Database SQLite 数据库
 type Database struct {
-	db *sql.DB
+	db         *sql.DB
+	ftsEnabled bool // 是否启用 FTS5 全文索引
 }
 
 // NewDatabase 创建数据库连接
@@ -24,6 +26,10 @@ func NewDatabase(dbPath string) (*Database, error) {
 		return nil, fmt.Errorf("创建数据库目录失败: %w", err)
 	}
 
+	// 记录数据库文件是否已存在，升级已有数据库前需要备份
+	info, statErr := os.Stat(dbPath)
+	existed := statErr == nil && info.Size() > 0
+
 	// 打开数据库连接
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
@@ -38,8 +44,14 @@ func NewDatabase(dbPath string) (*Database, error) {
 
 	database := &Database{db: db}
 
-	// 初始化表结构
-	if err := database.initTables(); err != nil {
+	// 升级表结构
+	if err := database.migrate(dbPath, existed); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// 全文搜索索引
+	if err := database.initSearchIndex(); err != nil {
 		db.Close()
 		return nil, err
 	}
@@ -47,65 +59,62 @@ func NewDatabase(dbPath string) (*Database, error) {
 	return database, nil
 }
 
-// initTables 初始化数据库表
-func (d *Database) initTables() error {
-	// 创建会话表
-	createSessionsTable := `
-	CREATE TABLE IF NOT EXISTS sessions (
-		id TEXT PRIMARY KEY,
-		title TEXT NOT NULL,
-		created_at DATETIME NOT NULL,
-		updated_at DATETIME NOT NULL
-	);
-	`
-
-	// 创建消息表
-	createMessagesTable := `
-	CREATE TABLE IF NOT EXISTS messages (
-		id TEXT PRIMARY KEY,
-		session_id TEXT NOT NULL,
-		role TEXT NOT NULL,
-		content TEXT NOT NULL,
-		timestamp DATETIME NOT NULL,
-		FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
-	);
-	`
-
-	// 创建索引
-	createIndexes := `
-	CREATE INDEX IF NOT EXISTS idx_messages_session_id ON messages(session_id);
-	CREATE INDEX IF NOT EXISTS idx_sessions_updated_at ON sessions(updated_at DESC);
-	`
-
-	// 执行建表语句
-	if _, err := d.db.Exec(createSessionsTable); err != nil {
-		return fmt.Errorf("创建会话表失败: %w", err)
-	}
+// Close 关闭数据库连接
+func (d *Database) Close() error {
+	return d.db.Close()
+}
 
-	if _, err := d.db.Exec(createMessagesTable); err != nil {
-		return fmt.Errorf("创建消息表失败: %w", err)
-	}
+// sessionColumns 会话查询的列，与 scanSession 的读取顺序一致
+const sessionColumns = `id, title, created_at, updated_at, system_prompt, model, temperature, top_p, max_tokens,
+	disabled_mcp_servers, knowledge_base_id`
 
-	if _, err := d.db.Exec(createIndexes); err != nil {
-		return fmt.Errorf("创建索引失败: %w", err)
-	}
+// rowScanner 兼容 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
-	return nil
+// execer 兼容 *sql.DB 和 *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
 }
 
-// Close 关闭数据库连接
-func (d *Database) Close() error {
-	return d.db.Close()
+// querier 兼容 *sql.DB 和 *sql.Tx
+type querier interface {
+	QueryRow(query string, args ...any) *sql.Row
 }
 
 // SaveSession 保存会话
 func (d *Database) SaveSession(session *models.Session) error {
+	return saveSession(d.db, session)
+}
+
+// saveSession 写入或更新会话
+func saveSession(db execer, session *models.Session) error {
 	query := `
-	INSERT OR REPLACE INTO sessions (id, title, created_at, updated_at)
-	VALUES (?, ?, ?, ?)
+	INSERT INTO sessions (` + sessionColumns + `)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(id) DO UPDATE SET
+		title = excluded.title,
+		updated_at = excluded.updated_at,
+		system_prompt = excluded.system_prompt,
+		model = excluded.model,
+		temperature = excluded.temperature,
+		top_p = excluded.top_p,
+		max_tokens = excluded.max_tokens,
+		disabled_mcp_servers = excluded.disabled_mcp_servers,
+		knowledge_base_id = excluded.knowledge_base_id
 	`
 
-	_, err := d.db.Exec(query, session.ID, session.Title, session.CreatedAt, session.UpdatedAt)
+	disabledServers, err := marshalStrings(session.DisabledMCPServers)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(query,
+		session.ID, session.Title, session.CreatedAt, session.UpdatedAt,
+		session.SystemPrompt, session.Model, session.Temperature, session.TopP, session.MaxTokens,
+		disabledServers, session.KnowledgeBaseID,
+	)
 	if err != nil {
 		return fmt.Errorf("保存会话失败: %w", err)
 	}
@@ -116,18 +125,12 @@ func (d *Database) SaveSession(session *models.Session) error {
 // GetSession 获取会话
 func (d *Database) GetSession(sessionID string) (*models.Session, error) {
 	query := `
-	SELECT id, title, created_at, updated_at
+	SELECT ` + sessionColumns + `
 	FROM sessions
 	WHERE id = ?
 	`
 
-	session := &models.Session{}
-	err := d.db.QueryRow(query, sessionID).Scan(
-		&session.ID,
-		&session.Title,
-		&session.CreatedAt,
-		&session.UpdatedAt,
-	)
+	session, err := scanSession(d.db.QueryRow(query, sessionID))
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -143,12 +146,36 @@ func (d *Database) GetSession(sessionID string) (*models.Session, error) {
 // ListSessions 获取所有会话列表（按更新时间倒序）
 func (d *Database) ListSessions() ([]*models.Session, error) {
 	query := `
-	SELECT id, title, created_at, updated_at
+	SELECT ` + sessionColumns + `
+	FROM sessions
+	ORDER BY updated_at DESC
+	`
+	return d.querySessions(query)
+}
+
+// ListSessionsPage 分页获取会话列表（按更新时间倒序），同时返回会话总数
+func (d *Database) ListSessionsPage(limit, offset int) ([]*models.Session, int, error) {
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("统计会话数量失败: %w", err)
+	}
+
+	query := `
+	SELECT ` + sessionColumns + `
 	FROM sessions
 	ORDER BY updated_at DESC
+	LIMIT ? OFFSET ?
 	`
+	sessions, err := d.querySessions(query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return sessions, total, nil
+}
 
-	rows, err := d.db.Query(query)
+// querySessions 执行会话查询并读取全部结果
+func (d *Database) querySessions(query string, args ...any) ([]*models.Session, error) {
+	rows, err := d.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("查询会话列表失败: %w", err)
 	}
@@ -156,8 +183,8 @@ func (d *Database) ListSessions() ([]*models.Session, error) {
 
 	sessions := make([]*models.Session, 0)
 	for rows.Next() {
-		session := &models.Session{}
-		if err := rows.Scan(&session.ID, &session.Title, &session.CreatedAt, &session.UpdatedAt); err != nil {
+		session, err := scanSession(rows)
+		if err != nil {
 			return nil, fmt.Errorf("读取会话数据失败: %w", err)
 		}
 		sessions = append(sessions, session)
@@ -170,15 +197,107 @@ func (d *Database) ListSessions() ([]*models.Session, error) {
 	return sessions, nil
 }
 
-// DeleteSession 删除会话（会级联删除相关消息）
-func (d *Database) DeleteSession(sessionID string) error {
-	query := `DELETE FROM sessions WHERE id = ?`
+// UpdateSessionSettings 更新会话的系统提示词、模型参数和 MCP 服务开关
+func (d *Database) UpdateSessionSettings(session *models.Session) error {
+	query := `
+	UPDATE sessions
+	SET system_prompt = ?, model = ?, temperature = ?, top_p = ?, max_tokens = ?, disabled_mcp_servers = ?
+	WHERE id = ?
+	`
+
+	disabledServers, err := marshalStrings(session.DisabledMCPServers)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Exec(query, session.SystemPrompt, session.Model, session.Temperature, session.TopP, session.MaxTokens, disabledServers, session.ID)
+	if err != nil {
+		return fmt.Errorf("更新会话设置失败: %w", err)
+	}
 
-	_, err := d.db.Exec(query, sessionID)
+	return nil
+}
+
+// scanSession 读取按 sessionColumns 查询的一行会话
+func scanSession(row rowScanner) (*models.Session, error) {
+	session := &models.Session{}
+	var (
+		temperature sql.NullFloat64
+		topP        sql.NullFloat64
+		maxTokens   sql.NullInt64
+		disabled    string
+	)
+	err := row.Scan(
+		&session.ID,
+		&session.Title,
+		&session.CreatedAt,
+		&session.UpdatedAt,
+		&session.SystemPrompt,
+		&session.Model,
+		&temperature,
+		&topP,
+		&maxTokens,
+		&disabled,
+		&session.KnowledgeBaseID,
+	)
 	if err != nil {
+		return nil, err
+	}
+
+	if disabled != "" {
+		if err := json.Unmarshal([]byte(disabled), &session.DisabledMCPServers); err != nil {
+			return nil, fmt.Errorf("解析 MCP 服务开关失败: %w", err)
+		}
+	}
+
+	if temperature.Valid {
+		v := float32(temperature.Float64)
+		session.Temperature = &v
+	}
+	if topP.Valid {
+		v := float32(topP.Float64)
+		session.TopP = &v
+	}
+	if maxTokens.Valid {
+		v := int(maxTokens.Int64)
+		session.MaxTokens = &v
+	}
+
+	return session, nil
+}
+
+// DeleteSession 删除会话及其所有消息
+func (d *Database) DeleteSession(sessionID string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM messages WHERE session_id = ?`, sessionID); err != nil {
+		return fmt.Errorf("删除会话消息失败: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM attachments WHERE session_id = ?`, sessionID); err != nil {
+		return fmt.Errorf("删除会话附件失败: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM summaries WHERE session_id = ?`, sessionID); err != nil {
+		return fmt.Errorf("删除会话摘要失败: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM imported_messages WHERE session_id = ?`, sessionID); err != nil {
+		return fmt.Errorf("删除会话导入记录失败: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE id = ?`, sessionID); err != nil {
 		return fmt.Errorf("删除会话失败: %w", err)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+
 	return nil
 }
 
@@ -198,51 +317,317 @@ func (d *Database) UpdateSessionTitle(sessionID, title string) error {
 	return nil
 }
 
-// SaveMessage 保存消息
+// messageColumns 消息查询的列，与 scanMessages 的读取顺序一致
+const messageColumns = `m.id, m.parent_id, m.role, m.content, m.timestamp, m.status,
+	m.model, m.prompt_tokens, m.completion_tokens, m.latency_ms, m.finish_reason,
+	m.tool_calls, m.tool_call_id, m.tool_name, m.citations`
+
+// SaveMessage 保存消息，并将其设为会话当前分支的末端
 func (d *Database) SaveMessage(sessionID string, message *models.Message) error {
-	query := `
-	INSERT INTO messages (id, session_id, role, content, timestamp)
-	VALUES (?, ?, ?, ?, ?)
-	`
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertMessage(tx, sessionID, message); err != nil {
+		return err
+	}
 
-	_, err := d.db.Exec(query, message.ID, sessionID, message.Role, message.Content, message.Timestamp)
+	// 更新会话的更新时间和当前分支
+	updateQuery := `UPDATE sessions SET updated_at = ?, active_leaf_id = ? WHERE id = ?`
+	_, err = tx.Exec(updateQuery, time.Now(), message.ID, sessionID)
 	if err != nil {
-		return fmt.Errorf("保存消息失败: %w", err)
+		return fmt.Errorf("更新会话时间失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+
+	return nil
+}
+
+// SaveMessages 在一个事务中按顺序保存多条消息，并将最后一条设为会话当前分支的末端
+func (d *Database) SaveMessages(sessionID string, messages []*models.Message) error {
+	if len(messages) == 0 {
+		return nil
 	}
 
-	// 更新会话的更新时间
-	updateQuery := `UPDATE sessions SET updated_at = ? WHERE id = ?`
-	_, err = d.db.Exec(updateQuery, time.Now(), sessionID)
+	tx, err := d.db.Begin()
 	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, message := range messages {
+		if err := insertMessage(tx, sessionID, message); err != nil {
+			return err
+		}
+	}
+
+	updateQuery := `UPDATE sessions SET updated_at = ?, active_leaf_id = ? WHERE id = ?`
+	if _, err := tx.Exec(updateQuery, time.Now(), messages[len(messages)-1].ID, sessionID); err != nil {
 		return fmt.Errorf("更新会话时间失败: %w", err)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+
 	return nil
 }
 
-// GetMessages 获取会话的所有消息
+// insertMessage 在事务中写入消息及其附件
+func insertMessage(tx *sql.Tx, sessionID string, message *models.Message) error {
+	query := `
+	INSERT INTO messages (id, session_id, parent_id, role, content, timestamp, status,
+		model, prompt_tokens, completion_tokens, latency_ms, finish_reason,
+		tool_calls, tool_call_id, tool_name, citations, seq)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, (SELECT COALESCE(MAX(seq), 0) + 1 FROM messages))
+	`
+
+	usage := message.Usage
+	if usage == nil {
+		usage = &models.Usage{}
+	}
+
+	toolCalls, err := marshalToolCalls(message.ToolCalls)
+	if err != nil {
+		return err
+	}
+
+	citations, err := marshalCitations(message.Citations)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(query, message.ID, sessionID, nullString(message.ParentID), message.Role, message.Content, message.Timestamp, message.Status,
+		usage.Model, usage.PromptTokens, usage.CompletionTokens, usage.Latency.Milliseconds(), usage.FinishReason,
+		toolCalls, message.ToolCallID, message.ToolName, citations)
+	if err != nil {
+		return fmt.Errorf("保存消息失败: %w", err)
+	}
+
+	return saveAttachments(tx, sessionID, message)
+}
+
+// GetMessages 获取会话的所有消息（包含所有分支，按时间排序）
 func (d *Database) GetMessages(sessionID string) ([]*models.Message, error) {
 	query := `
-	SELECT id, role, content, timestamp
-	FROM messages
-	WHERE session_id = ?
-	ORDER BY timestamp ASC
+	SELECT ` + messageColumns + `
+	FROM messages m
+	WHERE m.session_id = ?
+	ORDER BY m.timestamp ASC, m.seq ASC
 	`
 
 	rows, err := d.db.Query(query, sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("查询消息列表失败: %w", err)
 	}
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.loadAttachments(sessionID, messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// GetActivePath 获取会话当前分支上从根到末端的消息
+func (d *Database) GetActivePath(sessionID string) ([]*models.Message, error) {
+	leaf := `
+		SELECT COALESCE(active_leaf_id, (
+			SELECT id FROM messages WHERE session_id = sessions.id ORDER BY timestamp DESC, seq DESC LIMIT 1
+		)), 0
+		FROM sessions WHERE id = ?`
+	return d.queryPath(sessionID, leaf, sessionID)
+}
+
+// GetPath 获取会话中从根到指定消息的消息，消息不属于该会话时返回空列表
+func (d *Database) GetPath(sessionID, messageID string) ([]*models.Message, error) {
+	leaf := `
+		SELECT id, 0 FROM messages WHERE id = ? AND session_id = ?`
+	return d.queryPath(sessionID, leaf, messageID, sessionID)
+}
+
+// queryPath 从 leaf 查询出的末端消息沿父消息回溯到根，按从根到末端的顺序返回
+func (d *Database) queryPath(sessionID, leaf string, args ...any) ([]*models.Message, error) {
+	query := `
+	WITH RECURSIVE path(id, depth) AS (` + leaf + `
+		UNION ALL
+		SELECT m.parent_id, path.depth + 1
+		FROM messages m JOIN path ON m.id = path.id
+		WHERE m.parent_id IS NOT NULL
+	)
+	SELECT ` + messageColumns + `
+	FROM path JOIN messages m ON m.id = path.id
+	ORDER BY path.depth DESC
+	`
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询当前分支失败: %w", err)
+	}
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.loadAttachments(sessionID, messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// GetBranches 一次查询会话中所有有兄弟分支的消息在各自分支中的位置（按创建时间排序），键为消息 ID
+// 没有兄弟分支的消息不在结果中
+func (d *Database) GetBranches(sessionID string) (map[string]*models.BranchInfo, error) {
+	query := `
+	SELECT id, pos, n, COALESCE(prev_id, ''), COALESCE(next_id, '') FROM (
+		SELECT id,
+			ROW_NUMBER() OVER w - 1 AS pos,
+			COUNT(*) OVER (PARTITION BY parent_id) AS n,
+			LAG(id) OVER w AS prev_id,
+			LEAD(id) OVER w AS next_id
+		FROM messages
+		WHERE session_id = ?
+		WINDOW w AS (PARTITION BY parent_id ORDER BY timestamp, seq)
+	)
+	WHERE n > 1
+	`
+
+	rows, err := d.db.Query(query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("查询分支消息失败: %w", err)
+	}
+	defer rows.Close()
+
+	branches := make(map[string]*models.BranchInfo)
+	for rows.Next() {
+		var id string
+		info := &models.BranchInfo{}
+		if err := rows.Scan(&id, &info.Index, &info.Count, &info.PrevID, &info.NextID); err != nil {
+			return nil, fmt.Errorf("读取分支消息失败: %w", err)
+		}
+		branches[id] = info
+	}
+	return branches, rows.Err()
+}
+
+// SelectBranch 切换到包含指定消息的分支，沿最新的子消息走到末端
+func (d *Database) SelectBranch(sessionID, messageID string) error {
+	query := `
+	WITH RECURSIVE descend(id) AS (
+		SELECT ?
+		UNION ALL
+		SELECT (
+			SELECT c.id FROM messages c
+			WHERE c.parent_id = descend.id
+			ORDER BY c.timestamp DESC, c.seq DESC
+			LIMIT 1
+		)
+		FROM descend
+		WHERE descend.id IS NOT NULL
+	)
+	UPDATE sessions SET active_leaf_id = (
+		SELECT id FROM descend WHERE id IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM messages c WHERE c.parent_id = descend.id)
+	)
+	WHERE id = ? AND EXISTS (SELECT 1 FROM messages WHERE id = ? AND session_id = ?)
+	`
+
+	if _, err := d.db.Exec(query, messageID, sessionID, messageID, sessionID); err != nil {
+		return fmt.Errorf("切换分支失败: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteBranch 删除指定消息及其所有后代消息，会话当前分支回退到其父消息
+func (d *Database) DeleteBranch(sessionID, messageID string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	var parentID sql.NullString
+	err = tx.QueryRow(`SELECT parent_id FROM messages WHERE id = ? AND session_id = ?`, messageID, sessionID).Scan(&parentID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("查询消息失败: %w", err)
+	}
+
+	subtree := `
+	WITH RECURSIVE subtree(id) AS (
+		SELECT ?
+		UNION ALL
+		SELECT m.id FROM messages m JOIN subtree ON m.parent_id = subtree.id
+	)
+	`
+	if _, err := tx.Exec(subtree+`DELETE FROM attachments WHERE message_id IN (SELECT id FROM subtree)`, messageID); err != nil {
+		return fmt.Errorf("删除附件失败: %w", err)
+	}
+	if _, err := tx.Exec(subtree+`DELETE FROM messages WHERE id IN (SELECT id FROM subtree)`, messageID); err != nil {
+		return fmt.Errorf("删除消息失败: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE sessions SET updated_at = ?, active_leaf_id = ? WHERE id = ?`, time.Now(), parentID, sessionID); err != nil {
+		return fmt.Errorf("更新会话失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+
+	return nil
+}
+
+// scanMessages 读取按 messageColumns 查询的消息列表
+func scanMessages(rows *sql.Rows) ([]*models.Message, error) {
 	defer rows.Close()
 
 	messages := make([]*models.Message, 0)
 	for rows.Next() {
 		message := &models.Message{}
-		var roleStr string
-		if err := rows.Scan(&message.ID, &roleStr, &message.Content, &message.Timestamp); err != nil {
+		usage := &models.Usage{}
+		var (
+			parentID  sql.NullString
+			roleStr   string
+			statusStr string
+			latencyMs int64
+			toolCalls string
+			citations string
+		)
+		if err := rows.Scan(&message.ID, &parentID, &roleStr, &message.Content, &message.Timestamp, &statusStr,
+			&usage.Model, &usage.PromptTokens, &usage.CompletionTokens, &latencyMs, &usage.FinishReason,
+			&toolCalls, &message.ToolCallID, &message.ToolName, &citations); err != nil {
 			return nil, fmt.Errorf("读取消息数据失败: %w", err)
 		}
+		if toolCalls != "" {
+			if err := json.Unmarshal([]byte(toolCalls), &message.ToolCalls); err != nil {
+				return nil, fmt.Errorf("解析工具调用失败: %w", err)
+			}
+		}
+		if citations != "" {
+			if err := json.Unmarshal([]byte(citations), &message.Citations); err != nil {
+				return nil, fmt.Errorf("解析引用失败: %w", err)
+			}
+		}
+		message.ParentID = parentID.String
 		message.Role = models.Role(roleStr)
+		message.Status = models.MessageStatus(statusStr)
+
+		// 没有记录用量的消息（用户消息、旧数据）保持 Usage 为空
+		if usage.Model != "" || usage.TotalTokens() > 0 {
+			usage.Latency = time.Duration(latencyMs) * time.Millisecond
+			message.Usage = usage
+		}
 		messages = append(messages, message)
 	}
 
@@ -252,3 +637,44 @@ func (d *Database) GetMessages(sessionID string) ([]*models.Message, error) {
 
 	return messages, nil
 }
+
+// marshalToolCalls 将工具调用序列化为 JSON，没有工具调用时写入空字符串
+func marshalToolCalls(toolCalls []models.ToolCall) (string, error) {
+	if len(toolCalls) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(toolCalls)
+	if err != nil {
+		return "", fmt.Errorf("序列化工具调用失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// marshalCitations 将引用序列化为 JSON，没有引用时写入空字符串
+func marshalCitations(citations []models.Citation) (string, error) {
+	if len(citations) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(citations)
+	if err != nil {
+		return "", fmt.Errorf("序列化引用失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// marshalStrings 将字符串列表序列化为 JSON，列表为空时写入空字符串
+func marshalStrings(values []string) (string, error) {
+	if len(values) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("序列化列表失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// nullString 空字符串按 NULL 写入
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
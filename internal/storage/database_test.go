@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// newBranchDatabase 创建包含以下消息树的数据库，括号中为创建顺序；当前分支末端为最后保存的 u1b
+//
+//	会话 s1:
+//	u1 (1) ── a1 (2) ── u2 (3) ── a2 (4，带附件)
+//	       └─ a1b (5)          重新生成的回复
+//	u1b (6)                    编辑后的第一条消息
+//	会话 s2:
+//	x1 (7)
+func newBranchDatabase(t *testing.T) *Database {
+	t.Helper()
+
+	d := openTestDatabase(t, filepath.Join(t.TempDir(), "gochat.db"))
+	for _, id := range []string{"s1", "s2"} {
+		session := models.NewSession()
+		session.ID = id
+		if err := d.SaveSession(session); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	tree := []struct {
+		sessionID, id, parentID string
+		role                    models.Role
+	}{
+		{"s1", "u1", "", models.RoleUser},
+		{"s1", "a1", "u1", models.RoleAssistant},
+		{"s1", "u2", "a1", models.RoleUser},
+		{"s1", "a2", "u2", models.RoleAssistant},
+		{"s1", "a1b", "u1", models.RoleAssistant},
+		{"s1", "u1b", "", models.RoleUser},
+		{"s2", "x1", "", models.RoleUser},
+	}
+	for i, m := range tree {
+		msg := &models.Message{ID: m.id, ParentID: m.parentID, Role: m.role, Content: m.id, Timestamp: base.Add(time.Duration(i) * time.Minute)}
+		if m.id == "a2" {
+			msg.Attachments = []*models.Attachment{models.NewAttachment("a.txt", "text/plain", []byte("附件"))}
+		}
+		if err := d.SaveMessage(m.sessionID, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return d
+}
+
+// activePathIDs 返回会话当前分支上的消息 ID
+func activePathIDs(t *testing.T, d *Database, sessionID string) []string {
+	t.Helper()
+
+	path, err := d.GetActivePath(sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(path))
+	for _, msg := range path {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+func TestGetActivePath(t *testing.T) {
+	d := newBranchDatabase(t)
+
+	tests := []struct {
+		name      string
+		sessionID string
+		want      []string
+	}{
+		{"最后保存的分支", "s1", []string{"u1b"}},
+		{"其他会话", "s2", []string{"x1"}},
+		{"不存在的会话", "s3", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := activePathIDs(t, d, tt.sessionID); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("当前分支 = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+
+	// 当前分支上的消息带有附件
+	if err := d.SelectBranch("s1", "a2"); err != nil {
+		t.Fatal(err)
+	}
+	path, err := d.GetActivePath("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := path[len(path)-1]; last.ID != "a2" || len(last.Attachments) != 1 || string(last.Attachments[0].Data) != "附件" {
+		t.Errorf("当前分支末端 = %+v，期望带附件的 a2", last)
+	}
+}
+
+func TestGetBranches(t *testing.T) {
+	d := newBranchDatabase(t)
+
+	branches, err := d.GetBranches("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]*models.BranchInfo{
+		"u1":  {Index: 0, Count: 2, NextID: "u1b"},
+		"u1b": {Index: 1, Count: 2, PrevID: "u1"},
+		"a1":  {Index: 0, Count: 2, NextID: "a1b"},
+		"a1b": {Index: 1, Count: 2, PrevID: "a1"},
+	}
+	if !reflect.DeepEqual(branches, want) {
+		t.Errorf("分支 = %v，期望 %v", branches, want)
+	}
+
+	// 其他会话的根消息不算作兄弟分支
+	branches, err = d.GetBranches("s2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 0 {
+		t.Errorf("会话 s2 的分支 = %v，期望为空", branches)
+	}
+}
+
+func TestSelectBranch(t *testing.T) {
+	tests := []struct {
+		name      string
+		messageID string
+		want      []string
+	}{
+		{"沿子消息走到末端", "a1", []string{"u1", "a1", "u2", "a2"}},
+		{"选择中间消息", "u2", []string{"u1", "a1", "u2", "a2"}},
+		{"选择末端消息", "a1b", []string{"u1", "a1b"}},
+		{"多个子消息时选最新的", "u1", []string{"u1", "a1b"}},
+		{"其他会话的消息", "x1", []string{"u1b"}},
+		{"不存在的消息", "missing", []string{"u1b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newBranchDatabase(t)
+			if err := d.SelectBranch("s1", tt.messageID); err != nil {
+				t.Fatal(err)
+			}
+			if got := activePathIDs(t, d, "s1"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("当前分支 = %v，期望 %v", got, tt.want)
+			}
+			if got := activePathIDs(t, d, "s2"); !reflect.DeepEqual(got, []string{"x1"}) {
+				t.Errorf("会话 s2 的当前分支 = %v", got)
+			}
+		})
+	}
+}
+
+func TestDeleteBranch(t *testing.T) {
+	tests := []struct {
+		name      string
+		messageID string
+		wantPath  []string
+		wantLeft  []string // 会话 s1 剩余的消息，按创建顺序
+	}{
+		{"删除子树并回退到父消息", "a1", []string{"u1"}, []string{"u1", "a1b", "u1b"}},
+		{"删除末端消息", "a2", []string{"u1", "a1", "u2"}, []string{"u1", "a1", "u2", "a1b", "u1b"}},
+		{"删除根消息", "u1", []string{"u1b"}, []string{"u1b"}},
+		{"其他会话的消息", "x1", []string{"u1", "a1", "u2", "a2"}, []string{"u1", "a1", "u2", "a2", "a1b", "u1b"}},
+		{"不存在的消息", "missing", []string{"u1", "a1", "u2", "a2"}, []string{"u1", "a1", "u2", "a2", "a1b", "u1b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newBranchDatabase(t)
+			if err := d.SelectBranch("s1", "a2"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := d.DeleteBranch("s1", tt.messageID); err != nil {
+				t.Fatal(err)
+			}
+			if got := activePathIDs(t, d, "s1"); !reflect.DeepEqual(got, tt.wantPath) {
+				t.Errorf("当前分支 = %v，期望 %v", got, tt.wantPath)
+			}
+
+			messages, err := d.GetMessages("s1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			left := make([]string, 0, len(messages))
+			for _, msg := range messages {
+				left = append(left, msg.ID)
+			}
+			if !reflect.DeepEqual(left, tt.wantLeft) {
+				t.Errorf("剩余消息 = %v，期望 %v", left, tt.wantLeft)
+			}
+			if got := activePathIDs(t, d, "s2"); !reflect.DeepEqual(got, []string{"x1"}) {
+				t.Errorf("会话 s2 的当前分支 = %v", got)
+			}
+
+			// 被删除消息的附件一并删除
+			var attachments int
+			if err := d.db.QueryRow(`SELECT COUNT(*) FROM attachments WHERE message_id = 'a2'`).Scan(&attachments); err != nil {
+				t.Fatal(err)
+			}
+			if kept := slices.Contains(left, "a2"); kept != (attachments == 1) {
+				t.Errorf("a2 的附件数 = %d，消息保留: %v", attachments, kept)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// ImportSession 在一个事务中写入导入的会话：之前导入过（且会话未被删除）或会话 ID 已存在时
+// 只追加尚未导入的消息，否则新建会话；消息保留原始时间，会话的当前分支切换到导入数据中的当前分支
+func (d *Database) ImportSession(imp *models.ImportedSession) (*models.ImportResult, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	session, err := findImportedSession(tx, imp.SourceKey, imp.Session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ImportResult{}
+	if session == nil {
+		session = imp.Session
+		if err := saveSession(tx, session); err != nil {
+			return nil, err
+		}
+		result.Created = true
+	}
+	result.SessionID = session.ID
+	if err := recordImport(tx, imp.SourceKey, session.ID, ""); err != nil {
+		return nil, err
+	}
+
+	ids, err := importedMessageIDs(tx, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := sessionMessageIDs(tx, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, im := range imp.Messages {
+		if _, ok := ids[im.SourceKey]; ok {
+			continue
+		}
+		msg := im.Message
+		if existing[msg.ID] {
+			// 保留原 ID 导入（如 GoChat 自己的导出文件）时，消息可能已在会话中
+			ids[im.SourceKey] = msg.ID
+			continue
+		}
+		msg.ParentID = ids[im.ParentKey]
+		if err := insertMessage(tx, session.ID, msg); err != nil {
+			return nil, err
+		}
+		if err := recordImport(tx, im.SourceKey, session.ID, msg.ID); err != nil {
+			return nil, err
+		}
+		ids[im.SourceKey] = msg.ID
+		result.Added++
+	}
+
+	if result.Added > 0 {
+		activeKey := imp.ActiveKey
+		if activeKey == "" {
+			activeKey = imp.Messages[len(imp.Messages)-1].SourceKey
+		}
+		updatedAt := session.UpdatedAt
+		if imp.Session.UpdatedAt.After(updatedAt) {
+			updatedAt = imp.Session.UpdatedAt
+		}
+		updateQuery := `UPDATE sessions SET updated_at = ?, active_leaf_id = ? WHERE id = ?`
+		if _, err := tx.Exec(updateQuery, updatedAt, nullString(ids[activeKey]), session.ID); err != nil {
+			return nil, fmt.Errorf("更新会话失败: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", err)
+	}
+
+	return result, nil
+}
+
+// findImportedSession 查找之前由 sourceKey 导入的会话或 ID 为 sessionID 的会话，都不存在时返回 nil
+func findImportedSession(tx *sql.Tx, sourceKey, sessionID string) (*models.Session, error) {
+	query := `
+	SELECT ` + sessionColumns + `
+	FROM sessions
+	WHERE id = COALESCE((SELECT session_id FROM imported_messages WHERE source_key = ? AND message_id = ''), ?)
+	`
+
+	session, err := scanSession(tx.QueryRow(query, sourceKey, sessionID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询导入记录失败: %w", err)
+	}
+	return session, nil
+}
+
+// importedMessageIDs 返回会话中已导入且仍存在的消息，键为来源中的标识
+func importedMessageIDs(tx *sql.Tx, sessionID string) (map[string]string, error) {
+	query := `
+	SELECT i.source_key, i.message_id
+	FROM imported_messages i JOIN messages m ON m.id = i.message_id
+	WHERE i.session_id = ?
+	`
+
+	rows, err := tx.Query(query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("查询导入记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]string)
+	for rows.Next() {
+		var sourceKey, messageID string
+		if err := rows.Scan(&sourceKey, &messageID); err != nil {
+			return nil, fmt.Errorf("读取导入记录失败: %w", err)
+		}
+		ids[sourceKey] = messageID
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历导入记录失败: %w", err)
+	}
+
+	return ids, nil
+}
+
+// sessionMessageIDs 返回会话中所有消息的 ID
+func sessionMessageIDs(tx *sql.Tx, sessionID string) (map[string]bool, error) {
+	rows, err := tx.Query(`SELECT id FROM messages WHERE session_id = ?`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("查询消息列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("读取消息数据失败: %w", err)
+		}
+		ids[id] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历消息失败: %w", err)
+	}
+
+	return ids, nil
+}
+
+// recordImport 记录导入的会话（messageID 为空）或消息，覆盖指向已删除数据的旧记录
+func recordImport(tx *sql.Tx, sourceKey, sessionID, messageID string) error {
+	query := `INSERT OR REPLACE INTO imported_messages (source_key, session_id, message_id) VALUES (?, ?, ?)`
+	if _, err := tx.Exec(query, sourceKey, sessionID, messageID); err != nil {
+		return fmt.Errorf("保存导入记录失败: %w", err)
+	}
+	return nil
+}
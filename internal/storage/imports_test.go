@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// importMessage 待导入消息的来源标识、父消息标识和内容
+type importMessage struct {
+	key, parent, content string
+}
+
+// newImport 按导入器的方式构造一次导入：每次解析都生成新的会话和消息 ID，消息标识以会话标识为前缀；
+// activeKey 为空时使用最后一条消息
+func newImport(sourceKey, activeKey string, messages ...importMessage) *models.ImportedSession {
+	keyOf := func(key string) string {
+		if key == "" {
+			return ""
+		}
+		return sourceKey + "/" + key
+	}
+
+	imp := &models.ImportedSession{SourceKey: sourceKey, Session: models.NewSession(), ActiveKey: keyOf(activeKey)}
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i, m := range messages {
+		msg := models.NewMessage(models.RoleUser, m.content)
+		msg.Timestamp = base.Add(time.Duration(i) * time.Minute)
+		imp.Messages = append(imp.Messages, &models.ImportedMessage{SourceKey: keyOf(m.key), ParentKey: keyOf(m.parent), Message: msg})
+	}
+	return imp
+}
+
+// mustImport 导入会话，失败时终止测试
+func mustImport(t *testing.T, d *Database, imp *models.ImportedSession) *models.ImportResult {
+	t.Helper()
+
+	result, err := d.ImportSession(imp)
+	if err != nil {
+		t.Fatalf("导入失败: %v", err)
+	}
+	return result
+}
+
+// activePathContents 返回会话当前分支上的消息内容
+func activePathContents(t *testing.T, d *Database, sessionID string) []string {
+	t.Helper()
+
+	path, err := d.GetActivePath(sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := make([]string, 0, len(path))
+	for _, msg := range path {
+		contents = append(contents, msg.Content)
+	}
+	return contents
+}
+
+func TestImportSessionDedupe(t *testing.T) {
+	first := []importMessage{{"q1", "", "问题一"}, {"a1", "q1", "回答一"}}
+
+	tests := []struct {
+		name string
+		// setup 在导入 imp 之前执行，返回之前导入到的会话
+		setup       func(t *testing.T, d *Database) string
+		imp         func() *models.ImportedSession
+		wantCreated bool
+		wantAdded   int
+		wantPath    []string
+	}{
+		{
+			name:        "首次导入",
+			setup:       func(t *testing.T, d *Database) string { return "" },
+			imp:         func() *models.ImportedSession { return newImport("chatgpt:c1", "", first...) },
+			wantCreated: true,
+			wantAdded:   2,
+			wantPath:    []string{"问题一", "回答一"},
+		},
+		{
+			name: "重复导入同一文件",
+			setup: func(t *testing.T, d *Database) string {
+				return mustImport(t, d, newImport("chatgpt:c1", "", first...)).SessionID
+			},
+			imp:       func() *models.ImportedSession { return newImport("chatgpt:c1", "", first...) },
+			wantAdded: 0,
+			wantPath:  []string{"问题一", "回答一"},
+		},
+		{
+			name: "追加新消息",
+			setup: func(t *testing.T, d *Database) string {
+				return mustImport(t, d, newImport("chatgpt:c1", "", first...)).SessionID
+			},
+			imp: func() *models.ImportedSession {
+				return newImport("chatgpt:c1", "", append(first, importMessage{"q2", "a1", "问题二"})...)
+			},
+			wantAdded: 1,
+			wantPath:  []string{"问题一", "回答一", "问题二"},
+		},
+		{
+			name: "新分支成为当前分支",
+			setup: func(t *testing.T, d *Database) string {
+				return mustImport(t, d, newImport("chatgpt:c1", "", first...)).SessionID
+			},
+			imp: func() *models.ImportedSession {
+				return newImport("chatgpt:c1", "a1b", append(first, importMessage{"a1b", "q1", "重新回答"})...)
+			},
+			wantAdded: 1,
+			wantPath:  []string{"问题一", "重新回答"},
+		},
+		{
+			name: "删除消息后重新导入",
+			setup: func(t *testing.T, d *Database) string {
+				result := mustImport(t, d, newImport("chatgpt:c1", "", first...))
+				path, err := d.GetActivePath(result.SessionID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := d.DeleteBranch(result.SessionID, path[1].ID); err != nil {
+					t.Fatal(err)
+				}
+				return result.SessionID
+			},
+			imp:       func() *models.ImportedSession { return newImport("chatgpt:c1", "", first...) },
+			wantAdded: 1,
+			wantPath:  []string{"问题一", "回答一"},
+		},
+		{
+			name: "删除会话后重新导入",
+			setup: func(t *testing.T, d *Database) string {
+				result := mustImport(t, d, newImport("chatgpt:c1", "", first...))
+				if err := d.DeleteSession(result.SessionID); err != nil {
+					t.Fatal(err)
+				}
+				return ""
+			},
+			imp:         func() *models.ImportedSession { return newImport("chatgpt:c1", "", first...) },
+			wantCreated: true,
+			wantAdded:   2,
+			wantPath:    []string{"问题一", "回答一"},
+		},
+		{
+			name: "不同来源",
+			setup: func(t *testing.T, d *Database) string {
+				mustImport(t, d, newImport("chatgpt:c1", "", first...))
+				return ""
+			},
+			imp:         func() *models.ImportedSession { return newImport("chatgpt:c2", "", first...) },
+			wantCreated: true,
+			wantAdded:   2,
+			wantPath:    []string{"问题一", "回答一"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := openTestDatabase(t, filepath.Join(t.TempDir(), "gochat.db"))
+			previous := tt.setup(t, d)
+
+			result := mustImport(t, d, tt.imp())
+			if result.Created != tt.wantCreated || result.Added != tt.wantAdded {
+				t.Errorf("导入结果 = {Created: %v, Added: %d}，期望 {%v, %d}", result.Created, result.Added, tt.wantCreated, tt.wantAdded)
+			}
+			if previous != "" && result.SessionID != previous {
+				t.Errorf("导入到会话 %s，期望之前导入的会话 %s", result.SessionID, previous)
+			}
+			if got := activePathContents(t, d, result.SessionID); !reflect.DeepEqual(got, tt.wantPath) {
+				t.Errorf("当前分支 = %v，期望 %v", got, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestImportSessionKeepsOriginalIDs(t *testing.T) {
+	d := openTestDatabase(t, filepath.Join(t.TempDir(), "gochat.db"))
+
+	// GoChat 导出文件保留原会话和消息 ID，导回同一个数据库时只追加会话中没有的消息
+	session := models.NewSession()
+	if err := d.SaveSession(session); err != nil {
+		t.Fatal(err)
+	}
+	question := models.NewMessage(models.RoleUser, "问题一")
+	if err := d.SaveMessage(session.ID, question); err != nil {
+		t.Fatal(err)
+	}
+
+	exported := func(messages ...*models.Message) *models.ImportedSession {
+		copied := *session
+		imp := &models.ImportedSession{SourceKey: "gochat:" + session.ID, Session: &copied}
+		for _, msg := range messages {
+			m := *msg
+			imp.Messages = append(imp.Messages, &models.ImportedMessage{SourceKey: m.ID, ParentKey: m.ParentID, Message: &m})
+		}
+		return imp
+	}
+
+	answer := models.NewMessage(models.RoleAssistant, "回答一")
+	answer.ParentID = question.ID
+	tests := []struct {
+		name      string
+		imp       *models.ImportedSession
+		wantAdded int
+		wantPath  []string
+	}{
+		{"消息已在会话中", exported(question), 0, []string{"问题一"}},
+		{"追加导出后的新消息", exported(question, answer), 1, []string{"问题一", "回答一"}},
+		{"再次导入", exported(question, answer), 0, []string{"问题一", "回答一"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := mustImport(t, d, tt.imp)
+			if result.Created || result.SessionID != session.ID || result.Added != tt.wantAdded {
+				t.Errorf("导入结果 = %+v，期望追加 %d 条到会话 %s", result, tt.wantAdded, session.ID)
+			}
+			if got := activePathContents(t, d, session.ID); !reflect.DeepEqual(got, tt.wantPath) {
+				t.Errorf("当前分支 = %v，期望 %v", got, tt.wantPath)
+			}
+		})
+	}
+
+	path, err := d.GetActivePath(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path[1].ID != answer.ID || path[1].ParentID != question.ID {
+		t.Errorf("导入的消息 = {%s parent=%s}，期望保留原 ID", path[1].ID, path[1].ParentID)
+	}
+}
@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// SaveKnowledgeBase 保存知识库
+func (d *Database) SaveKnowledgeBase(kb *models.KnowledgeBase) error {
+	query := `
+	INSERT INTO knowledge_bases (id, name, path, embedding_model, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT(id) DO UPDATE SET
+		name = excluded.name,
+		path = excluded.path,
+		embedding_model = excluded.embedding_model,
+		updated_at = excluded.updated_at
+	`
+
+	_, err := d.db.Exec(query, kb.ID, kb.Name, kb.Path, kb.EmbeddingModel, kb.CreatedAt, kb.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("保存知识库失败: %w", err)
+	}
+
+	return nil
+}
+
+// GetKnowledgeBase 获取知识库，不存在时返回 nil
+func (d *Database) GetKnowledgeBase(id string) (*models.KnowledgeBase, error) {
+	query := `
+	SELECT id, name, path, embedding_model, created_at, updated_at
+	FROM knowledge_bases
+	WHERE id = ?
+	`
+
+	kb := &models.KnowledgeBase{}
+	err := d.db.QueryRow(query, id).Scan(&kb.ID, &kb.Name, &kb.Path, &kb.EmbeddingModel, &kb.CreatedAt, &kb.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("获取知识库失败: %w", err)
+	}
+
+	return kb, nil
+}
+
+// ListKnowledgeBases 获取所有知识库（按名称排序）
+func (d *Database) ListKnowledgeBases() ([]*models.KnowledgeBase, error) {
+	query := `
+	SELECT id, name, path, embedding_model, created_at, updated_at
+	FROM knowledge_bases
+	ORDER BY name
+	`
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("查询知识库列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	kbs := make([]*models.KnowledgeBase, 0)
+	for rows.Next() {
+		kb := &models.KnowledgeBase{}
+		if err := rows.Scan(&kb.ID, &kb.Name, &kb.Path, &kb.EmbeddingModel, &kb.CreatedAt, &kb.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("读取知识库数据失败: %w", err)
+		}
+		kbs = append(kbs, kb)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历知识库列表失败: %w", err)
+	}
+
+	return kbs, nil
+}
+
+// DeleteKnowledgeBase 删除知识库及其索引，并解除会话的关联
+func (d *Database) DeleteKnowledgeBase(id string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`DELETE FROM kb_chunks WHERE kb_id = ?`,
+		`DELETE FROM kb_documents WHERE kb_id = ?`,
+		`DELETE FROM knowledge_bases WHERE id = ?`,
+		`UPDATE sessions SET knowledge_base_id = '' WHERE knowledge_base_id = ?`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return fmt.Errorf("删除知识库失败: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+
+	return nil
+}
+
+// ListKnowledgeDocuments 获取知识库中已索引的文件
+func (d *Database) ListKnowledgeDocuments(kbID string) ([]*models.KnowledgeDocument, error) {
+	query := `
+	SELECT path, mod_time, size, chunks
+	FROM kb_documents
+	WHERE kb_id = ?
+	ORDER BY path
+	`
+
+	rows, err := d.db.Query(query, kbID)
+	if err != nil {
+		return nil, fmt.Errorf("查询知识库文件失败: %w", err)
+	}
+	defer rows.Close()
+
+	docs := make([]*models.KnowledgeDocument, 0)
+	for rows.Next() {
+		doc := &models.KnowledgeDocument{}
+		if err := rows.Scan(&doc.Path, &doc.ModTime, &doc.Size, &doc.Chunks); err != nil {
+			return nil, fmt.Errorf("读取知识库文件失败: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历知识库文件失败: %w", err)
+	}
+
+	return docs, nil
+}
+
+// ReplaceKnowledgeDocument 用新的片段替换文件的索引
+func (d *Database) ReplaceKnowledgeDocument(kbID string, doc *models.KnowledgeDocument, chunks []*models.KnowledgeChunk) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteKnowledgeDocument(tx, kbID, doc.Path); err != nil {
+		return err
+	}
+
+	docQuery := `
+	INSERT INTO kb_documents (kb_id, path, mod_time, size, chunks)
+	VALUES (?, ?, ?, ?, ?)
+	`
+	if _, err := tx.Exec(docQuery, kbID, doc.Path, doc.ModTime, doc.Size, len(chunks)); err != nil {
+		return fmt.Errorf("保存知识库文件失败: %w", err)
+	}
+
+	chunkQuery := `
+	INSERT INTO kb_chunks (kb_id, document_path, seq, content, embedding)
+	VALUES (?, ?, ?, ?, ?)
+	`
+	for _, chunk := range chunks {
+		if _, err := tx.Exec(chunkQuery, kbID, doc.Path, chunk.Seq, chunk.Content, encodeEmbedding(chunk.Embedding)); err != nil {
+			return fmt.Errorf("保存知识库片段失败: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE knowledge_bases SET updated_at = ? WHERE id = ?`, time.Now(), kbID); err != nil {
+		return fmt.Errorf("更新知识库时间失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteKnowledgeDocument 删除文件的索引
+func (d *Database) DeleteKnowledgeDocument(kbID, path string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteKnowledgeDocument(tx, kbID, path); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+
+	return nil
+}
+
+// deleteKnowledgeDocument 在事务中删除文件及其片段
+func deleteKnowledgeDocument(tx *sql.Tx, kbID, path string) error {
+	if _, err := tx.Exec(`DELETE FROM kb_chunks WHERE kb_id = ? AND document_path = ?`, kbID, path); err != nil {
+		return fmt.Errorf("删除知识库片段失败: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM kb_documents WHERE kb_id = ? AND path = ?`, kbID, path); err != nil {
+		return fmt.Errorf("删除知识库文件失败: %w", err)
+	}
+	return nil
+}
+
+// GetKnowledgeChunks 获取知识库的全部片段及向量
+func (d *Database) GetKnowledgeChunks(kbID string) ([]*models.KnowledgeChunk, error) {
+	query := `
+	SELECT document_path, seq, content, embedding
+	FROM kb_chunks
+	WHERE kb_id = ?
+	ORDER BY document_path, seq
+	`
+
+	rows, err := d.db.Query(query, kbID)
+	if err != nil {
+		return nil, fmt.Errorf("查询知识库片段失败: %w", err)
+	}
+	defer rows.Close()
+
+	chunks := make([]*models.KnowledgeChunk, 0)
+	for rows.Next() {
+		chunk := &models.KnowledgeChunk{}
+		var embedding []byte
+		if err := rows.Scan(&chunk.DocumentPath, &chunk.Seq, &chunk.Content, &embedding); err != nil {
+			return nil, fmt.Errorf("读取知识库片段失败: %w", err)
+		}
+		chunk.Embedding = decodeEmbedding(embedding)
+		chunks = append(chunks, chunk)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历知识库片段失败: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// UpdateSessionKnowledgeBase 设置会话关联的知识库，kbID 为空表示解除关联
+func (d *Database) UpdateSessionKnowledgeBase(sessionID, kbID string) error {
+	_, err := d.db.Exec(`UPDATE sessions SET knowledge_base_id = ? WHERE id = ?`, kbID, sessionID)
+	if err != nil {
+		return fmt.Errorf("更新会话知识库失败: %w", err)
+	}
+
+	return nil
+}
+
+// encodeEmbedding 将向量编码为小端 float32 字节序列
+func encodeEmbedding(embedding []float32) []byte {
+	data := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+	return data
+}
+
+// decodeEmbedding 解码 encodeEmbedding 编码的向量
+func decodeEmbedding(data []byte) []float32 {
+	embedding := make([]float32, len(data)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return embedding
+}
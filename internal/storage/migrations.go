@@ -0,0 +1,440 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migration 一次数据库结构升级，在单个事务中执行
+type migration struct {
+	version     int
+	description string
+	up          func(tx *sql.Tx) error
+}
+
+// migrations 按版本号递增排列，已发布的迁移不可修改，只能追加
+var migrations = []migration{
+	{1, "创建会话表和消息表", migrateBaseTables},
+	{2, "消息增加生成状态", func(tx *sql.Tx) error {
+		return addColumnIfMissing(tx, "messages", "status", "TEXT NOT NULL DEFAULT ''")
+	}},
+	{3, "消息树：父消息与当前分支", migrateMessageTree},
+	{4, "会话级系统提示词与模型参数", migrateSessionSettings},
+	{5, "会话滚动摘要", migrateSummaries},
+	{6, "消息用量统计", migrateMessageUsage},
+	{7, "工具调用消息", migrateToolMessages},
+	{8, "会话级 MCP 服务开关", func(tx *sql.Tx) error {
+		return addColumnIfMissing(tx, "sessions", "disabled_mcp_servers", "TEXT NOT NULL DEFAULT ''")
+	}},
+	{9, "本地知识库", migrateKnowledgeBases},
+	{10, "消息附件", migrateAttachments},
+	{11, "导入记录", migrateImports},
+	{12, "消息排序序号", migrateMessageSeq},
+	{13, "全文索引", migrateSearchIndex},
+}
+
+// latestSchemaVersion 当前程序支持的最新数据库版本
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate 将数据库升级到最新版本，升级已有数据库前会先备份
+func (d *Database) migrate(dbPath string, existed bool) error {
+	var current int
+	if err := d.db.QueryRow(`PRAGMA user_version`).Scan(&current); err != nil {
+		return fmt.Errorf("读取数据库版本失败: %w", err)
+	}
+
+	latest := latestSchemaVersion()
+	if current > latest {
+		return fmt.Errorf("数据库版本 %d 高于程序支持的版本 %d，请升级 GoChat", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	if existed {
+		backupPath, err := d.backup(dbPath, current)
+		if err != nil {
+			return err
+		}
+		log.Printf("数据库将从版本 %d 升级到 %d，已备份到: %s", current, latest, backupPath)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := d.applyMigration(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyMigration 在事务中执行一次迁移并更新版本号
+func (d *Database) applyMigration(m migration) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return fmt.Errorf("数据库迁移 %d（%s）失败: %w", m.version, m.description, err)
+	}
+
+	// user_version 不支持参数绑定
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", m.version)); err != nil {
+		return fmt.Errorf("更新数据库版本失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交数据库迁移 %d 失败: %w", m.version, err)
+	}
+
+	return nil
+}
+
+// backup 将当前数据库完整复制到同目录下的备份文件
+func (d *Database) backup(dbPath string, version int) (string, error) {
+	backupPath := fmt.Sprintf("%s.v%d-%s.bak", dbPath, version, time.Now().Format("20060102-150405"))
+	if _, err := d.db.Exec(`VACUUM INTO ?`, backupPath); err != nil {
+		return "", fmt.Errorf("备份数据库失败: %w", err)
+	}
+	return backupPath, nil
+}
+
+// addColumnIfMissing 表中不存在指定列时追加
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count); err != nil {
+		return fmt.Errorf("读取表结构失败: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("添加列 %s.%s 失败: %w", table, column, err)
+	}
+	return nil
+}
+
+// migrateBaseTables 创建初始表结构，兼容迁移机制引入前创建的数据库
+func migrateBaseTables(tx *sql.Tx) error {
+	// 创建会话表
+	createSessionsTable := `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id TEXT PRIMARY KEY,
+		title TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	`
+
+	// 创建消息表
+	createMessagesTable := `
+	CREATE TABLE IF NOT EXISTS messages (
+		id TEXT PRIMARY KEY,
+		session_id TEXT NOT NULL,
+		role TEXT NOT NULL,
+		content TEXT NOT NULL,
+		timestamp DATETIME NOT NULL,
+		FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+	);
+	`
+
+	// 创建索引
+	createIndexes := `
+	CREATE INDEX IF NOT EXISTS idx_messages_session_id ON messages(session_id);
+	CREATE INDEX IF NOT EXISTS idx_sessions_updated_at ON sessions(updated_at DESC);
+	`
+
+	if _, err := tx.Exec(createSessionsTable); err != nil {
+		return fmt.Errorf("创建会话表失败: %w", err)
+	}
+
+	if _, err := tx.Exec(createMessagesTable); err != nil {
+		return fmt.Errorf("创建消息表失败: %w", err)
+	}
+
+	if _, err := tx.Exec(createIndexes); err != nil {
+		return fmt.Errorf("创建索引失败: %w", err)
+	}
+
+	return nil
+}
+
+// migrateMessageTree 为消息增加 parent_id 形成树结构，会话记录当前所在分支的叶子消息
+// 已有消息按时间顺序串成一条链
+func migrateMessageTree(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "messages", "parent_id", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "sessions", "active_leaf_id", "TEXT"); err != nil {
+		return err
+	}
+
+	backfill := `
+	UPDATE messages SET parent_id = (
+		SELECT prev_id FROM (
+			SELECT id, LAG(id) OVER (PARTITION BY session_id ORDER BY timestamp, rowid) AS prev_id
+			FROM messages
+		) p WHERE p.id = messages.id
+	);
+	UPDATE sessions SET active_leaf_id = (
+		SELECT id FROM messages m
+		WHERE m.session_id = sessions.id
+		ORDER BY m.timestamp DESC, m.rowid DESC
+		LIMIT 1
+	);
+	CREATE INDEX IF NOT EXISTS idx_messages_parent_id ON messages(parent_id);
+	`
+	if _, err := tx.Exec(backfill); err != nil {
+		return fmt.Errorf("建立消息树失败: %w", err)
+	}
+
+	return nil
+}
+
+// migrateSessionSettings 为会话增加系统提示词和模型参数，数值参数为 NULL 时使用默认值
+func migrateSessionSettings(tx *sql.Tx) error {
+	columns := []struct{ name, definition string }{
+		{"system_prompt", "TEXT NOT NULL DEFAULT ''"},
+		{"model", "TEXT NOT NULL DEFAULT ''"},
+		{"temperature", "REAL"},
+		{"top_p", "REAL"},
+		{"max_tokens", "INTEGER"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfMissing(tx, "sessions", c.name, c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateSummaries 创建会话摘要表，每个会话保留一份滚动摘要
+func migrateSummaries(tx *sql.Tx) error {
+	createSummariesTable := `
+	CREATE TABLE IF NOT EXISTS summaries (
+		session_id TEXT PRIMARY KEY,
+		up_to_message_id TEXT NOT NULL,
+		content TEXT NOT NULL,
+		updated_at DATETIME NOT NULL,
+		FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+	);
+	`
+	if _, err := tx.Exec(createSummariesTable); err != nil {
+		return fmt.Errorf("创建摘要表失败: %w", err)
+	}
+	return nil
+}
+
+// migrateMessageUsage 为消息增加模型、token 数、耗时和结束原因
+func migrateMessageUsage(tx *sql.Tx) error {
+	columns := []struct{ name, definition string }{
+		{"model", "TEXT NOT NULL DEFAULT ''"},
+		{"prompt_tokens", "INTEGER NOT NULL DEFAULT 0"},
+		{"completion_tokens", "INTEGER NOT NULL DEFAULT 0"},
+		{"latency_ms", "INTEGER NOT NULL DEFAULT 0"},
+		{"finish_reason", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfMissing(tx, "messages", c.name, c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateToolMessages 为消息增加工具调用（JSON）及工具结果对应的调用 ID 和工具名称
+func migrateToolMessages(tx *sql.Tx) error {
+	columns := []struct{ name, definition string }{
+		{"tool_calls", "TEXT NOT NULL DEFAULT ''"},
+		{"tool_call_id", "TEXT NOT NULL DEFAULT ''"},
+		{"tool_name", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfMissing(tx, "messages", c.name, c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateKnowledgeBases 创建知识库、文档和片段表，并为会话关联知识库、为消息记录引用
+func migrateKnowledgeBases(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS knowledge_bases (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			path TEXT NOT NULL,
+			embedding_model TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS kb_documents (
+			kb_id TEXT NOT NULL,
+			path TEXT NOT NULL,
+			mod_time DATETIME NOT NULL,
+			size INTEGER NOT NULL,
+			chunks INTEGER NOT NULL,
+			PRIMARY KEY (kb_id, path),
+			FOREIGN KEY (kb_id) REFERENCES knowledge_bases(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS kb_chunks (
+			kb_id TEXT NOT NULL,
+			document_path TEXT NOT NULL,
+			seq INTEGER NOT NULL,
+			content TEXT NOT NULL,
+			embedding BLOB NOT NULL,
+			PRIMARY KEY (kb_id, document_path, seq),
+			FOREIGN KEY (kb_id) REFERENCES knowledge_bases(id) ON DELETE CASCADE
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("创建知识库表失败: %w", err)
+		}
+	}
+
+	if err := addColumnIfMissing(tx, "sessions", "knowledge_base_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	return addColumnIfMissing(tx, "messages", "citations", "TEXT NOT NULL DEFAULT ''")
+}
+
+// migrateAttachments 创建附件表，附件内容直接存储在数据库中
+func migrateAttachments(tx *sql.Tx) error {
+	createAttachmentsTable := `
+	CREATE TABLE IF NOT EXISTS attachments (
+		id TEXT PRIMARY KEY,
+		message_id TEXT NOT NULL,
+		session_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		mime_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		data BLOB NOT NULL,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_attachments_message_id ON attachments(message_id);
+	CREATE INDEX IF NOT EXISTS idx_attachments_session_id ON attachments(session_id);
+	`
+	if _, err := tx.Exec(createAttachmentsTable); err != nil {
+		return fmt.Errorf("创建附件表失败: %w", err)
+	}
+	return nil
+}
+
+// migrateImports 创建导入记录表，记录从其他客户端导入的会话和消息，重复导入时据此去重
+func migrateImports(tx *sql.Tx) error {
+	createImportsTable := `
+	CREATE TABLE IF NOT EXISTS imported_messages (
+		source_key TEXT PRIMARY KEY,
+		session_id TEXT NOT NULL,
+		message_id TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_imported_messages_session_id ON imported_messages(session_id);
+	`
+	if _, err := tx.Exec(createImportsTable); err != nil {
+		return fmt.Errorf("创建导入记录表失败: %w", err)
+	}
+	return nil
+}
+
+// migrateMessageSeq 为消息增加递增的写入序号，代替 rowid 作为同一时间消息的排序依据（rowid 在 VACUUM 后可能变化，
+// 旧的毫秒数字 ID 也无法与 UUIDv7 ID 一起排序）；已有消息按原来的顺序编号，ID 保持不变
+func migrateMessageSeq(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "messages", "seq", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	backfill := `
+	UPDATE messages SET seq = o.n
+	FROM (SELECT rowid AS rid, ROW_NUMBER() OVER (ORDER BY timestamp, rowid) AS n FROM messages) o
+	WHERE o.rid = messages.rowid;
+	CREATE INDEX IF NOT EXISTS idx_messages_seq ON messages(seq);
+	`
+	if _, err := tx.Exec(backfill); err != nil {
+		return fmt.Errorf("生成消息序号失败: %w", err)
+	}
+
+	return nil
+}
+
+// migrateSearchIndex 创建消息内容和会话标题的全文索引（FTS5，trigram 分词），用触发器与 messages / sessions 表保持同步，
+// 并为已有数据建立索引；早期版本在每次启动时创建的同名索引和触发器会先删除重建。当前 SQLite 未编译 FTS5 时跳过
+func migrateSearchIndex(tx *sql.Tx) error {
+	available, err := fts5Available(tx)
+	if err != nil || !available {
+		return err
+	}
+
+	dropOld := `
+	DROP TRIGGER IF EXISTS messages_fts_ai;
+	DROP TRIGGER IF EXISTS messages_fts_ad;
+	DROP TRIGGER IF EXISTS messages_fts_au;
+	DROP TRIGGER IF EXISTS sessions_fts_ai;
+	DROP TRIGGER IF EXISTS sessions_fts_ad;
+	DROP TRIGGER IF EXISTS sessions_fts_au;
+	DROP TABLE IF EXISTS messages_fts;
+	DROP TABLE IF EXISTS sessions_fts;
+	`
+	if _, err := tx.Exec(dropOld); err != nil {
+		return fmt.Errorf("删除旧全文索引失败: %w", err)
+	}
+
+	createFTS := `
+	CREATE VIRTUAL TABLE messages_fts USING fts5(
+		content, content='messages', content_rowid='rowid', tokenize='trigram'
+	);
+	CREATE VIRTUAL TABLE sessions_fts USING fts5(
+		title, content='sessions', content_rowid='rowid', tokenize='trigram'
+	);
+	`
+	if _, err := tx.Exec(createFTS); err != nil {
+		return fmt.Errorf("创建全文索引失败: %w", err)
+	}
+
+	createTriggers := `
+	CREATE TRIGGER messages_fts_ai AFTER INSERT ON messages BEGIN
+		INSERT INTO messages_fts(rowid, content) VALUES (new.rowid, new.content);
+	END;
+	CREATE TRIGGER messages_fts_ad AFTER DELETE ON messages BEGIN
+		INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+	END;
+	CREATE TRIGGER messages_fts_au AFTER UPDATE OF content ON messages BEGIN
+		INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+		INSERT INTO messages_fts(rowid, content) VALUES (new.rowid, new.content);
+	END;
+	CREATE TRIGGER sessions_fts_ai AFTER INSERT ON sessions BEGIN
+		INSERT INTO sessions_fts(rowid, title) VALUES (new.rowid, new.title);
+	END;
+	CREATE TRIGGER sessions_fts_ad AFTER DELETE ON sessions BEGIN
+		INSERT INTO sessions_fts(sessions_fts, rowid, title) VALUES ('delete', old.rowid, old.title);
+	END;
+	CREATE TRIGGER sessions_fts_au AFTER UPDATE OF title ON sessions BEGIN
+		INSERT INTO sessions_fts(sessions_fts, rowid, title) VALUES ('delete', old.rowid, old.title);
+		INSERT INTO sessions_fts(rowid, title) VALUES (new.rowid, new.title);
+	END;
+	`
+	if _, err := tx.Exec(createTriggers); err != nil {
+		return fmt.Errorf("创建全文索引触发器失败: %w", err)
+	}
+
+	backfill := `
+	INSERT INTO messages_fts(rowid, content) SELECT rowid, content FROM messages;
+	INSERT INTO sessions_fts(rowid, title) SELECT rowid, title FROM sessions;
+	`
+	if _, err := tx.Exec(backfill); err != nil {
+		return fmt.Errorf("为已有数据建立全文索引失败: %w", err)
+	}
+
+	return nil
+}
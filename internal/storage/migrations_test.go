@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// createDatabaseAt 在 dbPath 创建升级到 version 的数据库，模拟旧版本程序创建的数据库
+func createDatabaseAt(t *testing.T, dbPath string, version int) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Database{db: db}
+	for _, m := range migrations {
+		if m.version > version {
+			break
+		}
+		if err := d.applyMigration(m); err != nil {
+			db.Close()
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// mustExec 执行 SQL，失败时终止测试
+func mustExec(t *testing.T, db *sql.DB, query string, args ...any) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("执行 %q 失败: %v", query, err)
+	}
+}
+
+// openTestDatabase 打开 dbPath 的数据库，测试结束时关闭
+func openTestDatabase(t *testing.T, dbPath string) *Database {
+	t.Helper()
+
+	d, err := NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestSearchFindsMessagesAfterMigration(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "gochat.db")
+
+	// 全文索引迁移之前的数据库中已有会话和消息
+	db := createDatabaseAt(t, dbPath, 12)
+	mustExec(t, db, `INSERT INTO sessions (id, title, created_at, updated_at, active_leaf_id)
+		VALUES ('s1', '旅行计划', '2024-01-01 10:00:00', '2024-01-01 10:01:00', 'm2')`)
+	mustExec(t, db, `INSERT INTO messages (id, session_id, role, content, timestamp, parent_id, seq)
+		VALUES ('m1', 's1', 'user', '帮我规划一次杭州西湖的行程', '2024-01-01 10:00:00', NULL, 1)`)
+	mustExec(t, db, `INSERT INTO messages (id, session_id, role, content, timestamp, parent_id, seq)
+		VALUES ('m2', 's1', 'assistant', '第一天上午游览断桥残雪', '2024-01-01 10:01:00', 'm1', 2)`)
+	db.Close()
+
+	d := openTestDatabase(t, dbPath)
+	var version int
+	if err := d.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != latestSchemaVersion() {
+		t.Fatalf("数据库版本 = %d，期望 %d", version, latestSchemaVersion())
+	}
+	// 使用 -tags sqlite_fts5 运行时走全文索引，否则走 LIKE 查询
+	available, err := fts5Available(d.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ftsEnabled != available {
+		t.Fatalf("全文索引启用 = %v，FTS5 可用 = %v", d.ftsEnabled, available)
+	}
+
+	// 迁移后保存的消息同样可以搜索到
+	if err := d.SaveMessage("s1", &models.Message{ID: "m3", Role: models.RoleUser, Content: "西湖醋鱼哪家好吃", ParentID: "m2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		messageID string // 期望命中的消息，为空表示命中会话标题
+	}{
+		{"已有用户消息", "杭州西湖", "m1"},
+		{"已有助手消息", "断桥残雪", "m2"},
+		{"已有会话标题", "旅行计划", ""},
+		{"迁移后的消息", "西湖醋鱼", "m3"},
+		{"短检索词", "断桥", "m2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := d.Search(tt.query, 10, 0)
+			if err != nil {
+				t.Fatalf("搜索失败: %v", err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("搜索 %q 命中 %d 条，期望 1 条: %v", tt.query, len(results), results)
+			}
+			if r := results[0]; r.SessionID != "s1" || r.SessionTitle != "旅行计划" || r.MessageID != tt.messageID {
+				t.Errorf("搜索结果 = %+v，期望会话 s1 中的 %q", r, tt.messageID)
+			}
+		})
+	}
+}
+
+// schemaVersion 返回 dbPath 数据库的版本号
+func schemaVersion(t *testing.T, dbPath string) int {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func TestMigrateFromEachVersion(t *testing.T) {
+	latest := latestSchemaVersion()
+	versions := []int{0}
+	for _, m := range migrations {
+		versions = append(versions, m.version)
+	}
+
+	for _, version := range versions {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			dbPath := filepath.Join(t.TempDir(), "gochat.db")
+
+			// 旧版本的数据库中有一个会话和两条消息，消息树之前的版本没有 parent_id
+			if version > 0 {
+				db := createDatabaseAt(t, dbPath, version)
+				mustExec(t, db, `INSERT INTO sessions (id, title, created_at, updated_at) VALUES ('s1', '旧会话', '2024-01-01 10:00:00', '2024-01-01 10:01:00')`)
+				mustExec(t, db, `INSERT INTO messages (id, session_id, role, content, timestamp) VALUES ('m1', 's1', 'user', '你好', '2024-01-01 10:00:00')`)
+				mustExec(t, db, `INSERT INTO messages (id, session_id, role, content, timestamp) VALUES ('m2', 's1', 'assistant', '你好！', '2024-01-01 10:01:00')`)
+				if version >= 3 {
+					mustExec(t, db, `UPDATE messages SET parent_id = 'm1' WHERE id = 'm2'`)
+					mustExec(t, db, `UPDATE sessions SET active_leaf_id = 'm2'`)
+				}
+				db.Close()
+			}
+
+			d := openTestDatabase(t, dbPath)
+			if got := schemaVersion(t, dbPath); got != latest {
+				t.Fatalf("数据库版本 = %d，期望 %d", got, latest)
+			}
+
+			// 升级已有数据库前备份，备份保留原版本号；新建或已是最新版本时不备份
+			backups, err := filepath.Glob(dbPath + ".v*.bak")
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantBackup := version > 0 && version < latest
+			if wantBackup != (len(backups) == 1) || len(backups) > 1 {
+				t.Fatalf("备份文件 = %v，期望备份: %v", backups, wantBackup)
+			}
+			if wantBackup {
+				if !strings.HasPrefix(filepath.Base(backups[0]), fmt.Sprintf("gochat.db.v%d-", version)) {
+					t.Errorf("备份文件名 = %s", backups[0])
+				}
+				if got := schemaVersion(t, backups[0]); got != version {
+					t.Errorf("备份的数据库版本 = %d，期望 %d", got, version)
+				}
+			}
+
+			if version == 0 {
+				return
+			}
+			path, err := d.GetActivePath("s1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(path) != 2 || path[0].ID != "m1" || path[1].ID != "m2" || path[1].ParentID != "m1" {
+				t.Fatalf("升级后的当前分支 = %v", path)
+			}
+			session, err := d.GetSession("s1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if session.Title != "旧会话" {
+				t.Errorf("升级后的会话标题 = %q", session.Title)
+			}
+		})
+	}
+}
+
+func TestMigrateRejectsNewerVersion(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "gochat.db")
+	db := createDatabaseAt(t, dbPath, latestSchemaVersion())
+	mustExec(t, db, fmt.Sprintf("PRAGMA user_version = %d", latestSchemaVersion()+1))
+	db.Close()
+
+	if d, err := NewDatabase(dbPath); err == nil {
+		d.Close()
+		t.Fatal("打开更高版本的数据库应失败")
+	}
+	if backups, _ := filepath.Glob(dbPath + ".v*.bak"); len(backups) != 0 {
+		t.Errorf("拒绝打开时不应备份: %v", backups)
+	}
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "gochat.db")
+
+	// 迁移机制引入前的程序直接建表，数据库版本为 0
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, db, `CREATE TABLE sessions (id TEXT PRIMARY KEY, title TEXT NOT NULL, created_at DATETIME NOT NULL, updated_at DATETIME NOT NULL)`)
+	mustExec(t, db, `CREATE TABLE messages (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, role TEXT NOT NULL, content TEXT NOT NULL, timestamp DATETIME NOT NULL)`)
+	mustExec(t, db, `INSERT INTO sessions VALUES ('s1', '旧会话', '2024-01-01 10:00:00', '2024-01-01 10:01:00')`)
+	mustExec(t, db, `INSERT INTO messages VALUES ('m1', 's1', 'user', '你好', '2024-01-01 10:00:00')`)
+	mustExec(t, db, `INSERT INTO messages VALUES ('m2', 's1', 'assistant', '你好！', '2024-01-01 10:01:00')`)
+	db.Close()
+
+	d := openTestDatabase(t, dbPath)
+	if got := schemaVersion(t, dbPath); got != latestSchemaVersion() {
+		t.Fatalf("数据库版本 = %d，期望 %d", got, latestSchemaVersion())
+	}
+	backups, err := filepath.Glob(dbPath + ".v0-*.bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("备份文件 = %v，期望一个 v0 备份", backups)
+	}
+
+	// 已有消息按时间顺序串成当前分支
+	path, err := d.GetActivePath("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 2 || path[0].ID != "m1" || path[1].ID != "m2" || path[1].ParentID != "m1" {
+		t.Fatalf("升级后的当前分支 = %v", path)
+	}
+}
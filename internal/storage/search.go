@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// trigram 分词器要求每个检索词至少 3 个字符
+const minFTSTermLength = 3
+
+// initSearchIndex 检查全文索引（FTS5）是否可用，索引和同步触发器由迁移 migrateSearchIndex 创建
+// 当前 SQLite 未编译 FTS5 时退化为 LIKE 查询；迁移时不支持 FTS5、之后用支持 FTS5 的构建打开时补建索引
+func (d *Database) initSearchIndex() error {
+	available, err := fts5Available(d.db)
+	if err != nil {
+		return err
+	}
+	if !available {
+		log.Printf("当前 SQLite 不支持 FTS5，搜索将使用 LIKE 查询（构建时添加 -tags sqlite_fts5 以启用全文索引）")
+		return nil
+	}
+
+	var exists int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'messages_fts'`).Scan(&exists); err != nil {
+		return fmt.Errorf("检查全文索引失败: %w", err)
+	}
+	if exists == 0 {
+		tx, err := d.db.Begin()
+		if err != nil {
+			return fmt.Errorf("开启事务失败: %w", err)
+		}
+		defer tx.Rollback()
+
+		if err := migrateSearchIndex(tx); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("提交全文索引失败: %w", err)
+		}
+	}
+
+	d.ftsEnabled = true
+	return nil
+}
+
+// fts5Available 当前 SQLite 是否编译了 FTS5
+func fts5Available(db querier) (bool, error) {
+	var used int
+	if err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&used); err != nil {
+		return false, fmt.Errorf("检查 FTS5 支持失败: %w", err)
+	}
+	return used == 1, nil
+}
+
+// Search 在所有会话标题和消息内容中搜索，按相关度排序返回命中结果
+func (d *Database) Search(query string, limit, offset int) ([]*models.SearchResult, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return []*models.SearchResult{}, nil
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	if d.ftsEnabled && allTermsIndexable(terms) {
+		return d.searchFTS(terms, limit, offset)
+	}
+	return d.searchLike(terms, limit, offset)
+}
+
+// searchFTS 使用 FTS5 索引搜索
+func (d *Database) searchFTS(terms []string, limit, offset int) ([]*models.SearchResult, error) {
+	matchQuery := buildMatchQuery(terms)
+
+	query := `
+	SELECT session_id, title, message_id, role, snippet, ts FROM (
+		SELECT m.session_id AS session_id, s.title AS title, m.id AS message_id, m.role AS role,
+			snippet(messages_fts, 0, ?, ?, '…', 16) AS snippet, m.timestamp AS ts,
+			bm25(messages_fts) AS score
+		FROM messages_fts
+		JOIN messages m ON m.rowid = messages_fts.rowid
+		JOIN sessions s ON s.id = m.session_id
+		WHERE messages_fts MATCH ?
+		UNION ALL
+		SELECT s.id, s.title, '', '',
+			snippet(sessions_fts, 0, ?, ?, '…', 16), s.updated_at,
+			bm25(sessions_fts)
+		FROM sessions_fts
+		JOIN sessions s ON s.rowid = sessions_fts.rowid
+		WHERE sessions_fts MATCH ?
+	)
+	ORDER BY score
+	LIMIT ? OFFSET ?
+	`
+
+	rows, err := d.db.Query(query,
+		models.SnippetMarkStart, models.SnippetMarkEnd, matchQuery,
+		models.SnippetMarkStart, models.SnippetMarkEnd, matchQuery,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("全文搜索失败: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]*models.SearchResult, 0)
+	for rows.Next() {
+		result := &models.SearchResult{}
+		var roleStr string
+		if err := rows.Scan(&result.SessionID, &result.SessionTitle, &result.MessageID, &roleStr, &result.Snippet, &result.Timestamp); err != nil {
+			return nil, fmt.Errorf("读取搜索结果失败: %w", err)
+		}
+		result.Role = models.Role(roleStr)
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历搜索结果失败: %w", err)
+	}
+
+	return results, nil
+}
+
+// searchLike 未启用 FTS5 或检索词过短时使用 LIKE 搜索，按时间倒序返回
+func (d *Database) searchLike(terms []string, limit, offset int) ([]*models.SearchResult, error) {
+	var (
+		messageConds = make([]string, 0, len(terms))
+		titleConds   = make([]string, 0, len(terms))
+		messageArgs  = make([]any, 0, len(terms))
+		titleArgs    = make([]any, 0, len(terms))
+	)
+	for _, term := range terms {
+		pattern := "%" + escapeLike(term) + "%"
+		messageConds = append(messageConds, `m.content LIKE ? ESCAPE '\'`)
+		titleConds = append(titleConds, `s.title LIKE ? ESCAPE '\'`)
+		messageArgs = append(messageArgs, pattern)
+		titleArgs = append(titleArgs, pattern)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT session_id, title, message_id, role, content, ts FROM (
+		SELECT m.session_id AS session_id, s.title AS title, m.id AS message_id, m.role AS role,
+			m.content AS content, m.timestamp AS ts
+		FROM messages m
+		JOIN sessions s ON s.id = m.session_id
+		WHERE %s
+		UNION ALL
+		SELECT s.id, s.title, '', '', s.title, s.updated_at
+		FROM sessions s
+		WHERE %s
+	)
+	ORDER BY ts DESC
+	LIMIT ? OFFSET ?
+	`, strings.Join(messageConds, " AND "), strings.Join(titleConds, " AND "))
+
+	args := append(append(messageArgs, titleArgs...), limit, offset)
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("搜索失败: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]*models.SearchResult, 0)
+	for rows.Next() {
+		result := &models.SearchResult{}
+		var roleStr, content string
+		if err := rows.Scan(&result.SessionID, &result.SessionTitle, &result.MessageID, &roleStr, &content, &result.Timestamp); err != nil {
+			return nil, fmt.Errorf("读取搜索结果失败: %w", err)
+		}
+		result.Role = models.Role(roleStr)
+		result.Snippet = makeSnippet(content, terms[0], 16)
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历搜索结果失败: %w", err)
+	}
+
+	return results, nil
+}
+
+// allTermsIndexable 判断所有检索词是否满足 trigram 分词的最小长度
+func allTermsIndexable(terms []string) bool {
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < minFTSTermLength {
+			return false
+		}
+	}
+	return true
+}
+
+// buildMatchQuery 将检索词转换为 FTS5 查询：每个词作为短语，多个词之间为 AND
+func buildMatchQuery(terms []string) string {
+	phrases := make([]string, 0, len(terms))
+	for _, term := range terms {
+		phrases = append(phrases, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+	}
+	return strings.Join(phrases, " AND ")
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// makeSnippet 截取关键词附近的内容作为片段，context 为关键词两侧保留的字符数
+func makeSnippet(content, term string, context int) string {
+	runes := []rune(content)
+	lower := []rune(strings.ToLower(content))
+	termRunes := []rune(strings.ToLower(term))
+	if len(lower) != len(runes) {
+		// 大小写转换改变了长度时退化为区分大小写匹配
+		lower = runes
+		termRunes = []rune(term)
+	}
+
+	pos := -1
+	for i := 0; i+len(termRunes) <= len(lower); i++ {
+		if string(lower[i:i+len(termRunes)]) == string(termRunes) {
+			pos = i
+			break
+		}
+	}
+	if pos < 0 {
+		if len(runes) > context*2 {
+			return string(runes[:context*2]) + "…"
+		}
+		return content
+	}
+
+	start := max(pos-context, 0)
+	end := min(pos+len(termRunes)+context, len(runes))
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	b.WriteString(string(runes[start:pos]))
+	b.WriteString(models.SnippetMarkStart)
+	b.WriteString(string(runes[pos : pos+len(termRunes)]))
+	b.WriteString(models.SnippetMarkEnd)
+	b.WriteString(string(runes[pos+len(termRunes) : end]))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
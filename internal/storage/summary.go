@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// GetSummary 获取会话的滚动摘要，不存在时返回 nil
+func (d *Database) GetSummary(sessionID string) (*models.Summary, error) {
+	query := `
+	SELECT session_id, up_to_message_id, content, updated_at
+	FROM summaries
+	WHERE session_id = ?
+	`
+
+	summary := &models.Summary{}
+	err := d.db.QueryRow(query, sessionID).Scan(
+		&summary.SessionID,
+		&summary.UpToMessageID,
+		&summary.Content,
+		&summary.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("获取会话摘要失败: %w", err)
+	}
+
+	return summary, nil
+}
+
+// SaveSummary 保存会话的滚动摘要，覆盖之前的摘要
+func (d *Database) SaveSummary(summary *models.Summary) error {
+	query := `
+	INSERT INTO summaries (session_id, up_to_message_id, content, updated_at)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT(session_id) DO UPDATE SET
+		up_to_message_id = excluded.up_to_message_id,
+		content = excluded.content,
+		updated_at = excluded.updated_at
+	`
+
+	_, err := d.db.Exec(query, summary.SessionID, summary.UpToMessageID, summary.Content, time.Now())
+	if err != nil {
+		return fmt.Errorf("保存会话摘要失败: %w", err)
+	}
+
+	return nil
+}
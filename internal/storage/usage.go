@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// GetSessionUsage 按模型汇总会话的用量（包含所有分支上的回复）
+func (d *Database) GetSessionUsage(sessionID string) ([]*models.UsageStat, error) {
+	query := `
+	SELECT '', model, COUNT(*), SUM(prompt_tokens), SUM(completion_tokens)
+	FROM messages
+	WHERE session_id = ? AND role = 'assistant' AND (model != '' OR prompt_tokens > 0 OR completion_tokens > 0)
+	GROUP BY model
+	ORDER BY model
+	`
+
+	rows, err := d.db.Query(query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("查询会话用量失败: %w", err)
+	}
+	return scanUsageStats(rows)
+}
+
+// GetDailyUsage 按日期和模型汇总 since 当天及之后的用量，日期按消息写入时的本地时间计算
+func (d *Database) GetDailyUsage(since time.Time) ([]*models.UsageStat, error) {
+	// timestamp 以 "2006-01-02 15:04:05..." 格式保存，前 10 个字符即为日期
+	query := `
+	SELECT substr(timestamp, 1, 10) AS day, model, COUNT(*), SUM(prompt_tokens), SUM(completion_tokens)
+	FROM messages
+	WHERE role = 'assistant' AND (model != '' OR prompt_tokens > 0 OR completion_tokens > 0)
+		AND substr(timestamp, 1, 10) >= ?
+	GROUP BY day, model
+	ORDER BY day DESC, model
+	`
+
+	rows, err := d.db.Query(query, since.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("查询每日用量失败: %w", err)
+	}
+	return scanUsageStats(rows)
+}
+
+// scanUsageStats 读取用量统计列表
+func scanUsageStats(rows *sql.Rows) ([]*models.UsageStat, error) {
+	defer rows.Close()
+
+	stats := make([]*models.UsageStat, 0)
+	for rows.Next() {
+		stat := &models.UsageStat{}
+		if err := rows.Scan(&stat.Day, &stat.Model, &stat.Messages, &stat.PromptTokens, &stat.CompletionTokens); err != nil {
+			return nil, fmt.Errorf("读取用量数据失败: %w", err)
+		}
+		stats = append(stats, stat)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历用量数据失败: %w", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,296 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/ai"
+)
+
+// titleTimeout 生成会话标题的超时时间
+const titleTimeout = 30 * time.Second
+
+// chunkMsg 流式输出的回复片段
+type chunkMsg string
+
+// stepMsg 工具调用过程中产生的消息（发起调用的助手消息或工具结果），已写入数据库
+type stepMsg struct {
+	msg *models.Message
+}
+
+// approvalMsg 模型请求调用工具，等待用户确认
+type approvalMsg struct {
+	call  models.ToolCall
+	reply chan bool
+}
+
+// replyMsg 回复生成结束，reply 已写入数据库
+type replyMsg struct {
+	reply   *models.Message
+	err     error
+	saveErr error
+}
+
+// titleMsg 后台生成的会话标题已写入数据库
+type titleMsg struct {
+	sessionID string
+	title     string
+}
+
+// send 发送输入框中的消息并开始生成回复
+func (m *model) send() tea.Cmd {
+	content := strings.TrimSpace(m.input.Value())
+	if content == "" {
+		return nil
+	}
+	if m.generating() {
+		m.status = "正在生成回复，按 Esc 停止"
+		return nil
+	}
+
+	userMsg := models.NewMessage(models.RoleUser, content)
+	if history := m.conv.History(); len(history) > 0 {
+		userMsg.ParentID = history[len(history)-1].ID
+	}
+	if err := m.env.DB.SaveMessage(m.session.ID, userMsg); err != nil {
+		m.status = fmt.Sprintf("保存消息失败: %v", err)
+		return nil
+	}
+
+	m.input.Reset()
+	m.messages = append(m.messages, userMsg)
+	m.streaming = models.NewMessage(models.RoleAssistant, "")
+	m.refreshMessages(true)
+	return m.startReply(userMsg)
+}
+
+// startReply 在后台生成回复，生成过程通过 events 逐条交给界面处理
+// 消息在后台直接写入数据库，界面退出或停止生成时也不会丢失已生成的工具调用
+func (m *model) startReply(userMsg *models.Message) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan tea.Msg)
+	done := make(chan struct{})
+	m.events, m.cancel, m.done = events, cancel, done
+
+	sessionID, conv := m.session.ID, m.conv
+	deliver := func(msg tea.Msg) bool {
+		select {
+		case events <- msg:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	m.background.Add(1)
+	go func() {
+		defer m.background.Done()
+
+		reply, err := m.env.AI.StreamChat(ctx, conv, userMsg, ai.StreamHandler{
+			OnChunk: func(chunk string) error {
+				deliver(chunkMsg(chunk))
+				return nil
+			},
+			OnMessage: func(msg *models.Message) {
+				if err := m.env.DB.SaveMessage(sessionID, msg); err != nil {
+					log.Printf("保存工具调用消息失败: %v", err)
+				}
+				deliver(stepMsg{msg: msg})
+			},
+			Approve: func(ctx context.Context, call models.ToolCall) bool {
+				answer := make(chan bool, 1)
+				if !deliver(approvalMsg{call: call, reply: answer}) {
+					return false
+				}
+				select {
+				case ok := <-answer:
+					return ok
+				case <-ctx.Done():
+					return false
+				}
+			},
+		})
+
+		result := replyMsg{reply: reply, err: err}
+		var streamErr *ai.StreamError
+		switch {
+		case errors.As(err, &streamErr):
+			result.reply = streamErr.Partial
+			result.saveErr = m.env.DB.SaveMessage(sessionID, streamErr.Partial)
+		case reply != nil && (err == nil || errors.Is(err, context.Canceled)):
+			result.saveErr = m.env.DB.SaveMessage(sessionID, reply)
+		}
+		deliver(result)
+	}()
+
+	return m.waitEvent()
+}
+
+// waitEvent 等待后台生成的下一个事件
+func (m *model) waitEvent() tea.Cmd {
+	events := m.events
+	if events == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
+// generating 是否正在生成回复
+func (m *model) generating() bool {
+	return m.cancel != nil
+}
+
+// stopReply 停止正在生成的回复，已生成的内容会保存为中断的回复
+func (m *model) stopReply() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.approval = nil
+}
+
+// shutdown 退出界面时停止生成并等待后台写入完成
+func (m *model) shutdown() {
+	m.stopReply()
+	if m.done != nil {
+		close(m.done)
+		m.done = nil
+	}
+	m.background.Wait()
+}
+
+// handleChunk 追加流式输出的片段
+func (m *model) handleChunk(chunk chunkMsg) tea.Cmd {
+	if m.streaming != nil {
+		m.streaming.Content += string(chunk)
+		m.refreshMessages(false)
+	}
+	return m.waitEvent()
+}
+
+// handleStep 显示工具调用过程，之后的输出属于新的一步回复
+func (m *model) handleStep(step stepMsg) tea.Cmd {
+	m.messages = append(m.messages, step.msg)
+	m.streaming = models.NewMessage(models.RoleAssistant, "")
+	m.refreshMessages(false)
+	return m.waitEvent()
+}
+
+// handleApproval 确认工具调用：自动允许或在状态栏询问用户
+func (m *model) handleApproval(req approvalMsg) tea.Cmd {
+	if m.autoApprove || m.allowed[req.call.Name] {
+		req.reply <- true
+	} else {
+		m.approval = &req
+	}
+	return m.waitEvent()
+}
+
+// answerApproval 回复等待确认的工具调用，always 为 true 时本会话不再询问该工具
+func (m *model) answerApproval(ok, always bool) {
+	if m.approval == nil {
+		return
+	}
+	if always {
+		m.allowed[m.approval.call.Name] = true
+	}
+	m.approval.reply <- ok
+	m.approval = nil
+}
+
+// handleReply 回复生成结束
+func (m *model) handleReply(result replyMsg) tea.Cmd {
+	m.cancel()
+	m.cancel, m.events, m.streaming, m.approval = nil, nil, nil, nil
+	close(m.done)
+	m.done = nil
+
+	var cmd tea.Cmd
+	var streamErr *ai.StreamError
+	switch {
+	case errors.As(result.err, &streamErr):
+		m.messages = append(m.messages, result.reply)
+		m.status = fmt.Sprintf("生成失败: %v", result.err)
+	case errors.Is(result.err, context.Canceled):
+		if result.reply != nil {
+			m.messages = append(m.messages, result.reply)
+		}
+		m.status = "已停止生成"
+	case result.err != nil:
+		m.status = fmt.Sprintf("生成失败: %v", result.err)
+	default:
+		m.messages = append(m.messages, result.reply)
+		if m.session.Title == models.DefaultSessionTitle && !m.titled[m.session.ID] {
+			cmd = m.generateTitle()
+		}
+	}
+	if result.saveErr != nil {
+		m.status = fmt.Sprintf("保存回复失败: %v", result.saveErr)
+	}
+
+	// 会话的更新时间变化后列表顺序可能改变
+	if err := m.reloadSessions(); err != nil {
+		log.Printf("刷新会话列表失败: %v", err)
+	}
+	m.refreshMessages(false)
+	return cmd
+}
+
+// generateTitle 在后台根据最近的对话生成会话标题
+func (m *model) generateTitle() tea.Cmd {
+	if m.env.Assistant == nil {
+		return nil
+	}
+
+	messages := m.messages
+	if len(messages) > 8 {
+		messages = messages[len(messages)-8:]
+	}
+	messages = append([]*models.Message(nil), messages...)
+	sessionID := m.session.ID
+
+	m.titled[sessionID] = true
+	result := make(chan tea.Msg, 1)
+	m.background.Add(1)
+	go func() {
+		defer m.background.Done()
+		result <- m.fetchTitle(sessionID, messages)
+	}()
+	return func() tea.Msg {
+		return <-result
+	}
+}
+
+// fetchTitle 生成会话标题并写入数据库，失败时返回 nil
+func (m *model) fetchTitle(sessionID string, messages []*models.Message) tea.Msg {
+	ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+	defer cancel()
+
+	title, err := m.env.Assistant.GenerateTitle(ctx, messages)
+	if err != nil {
+		log.Printf("生成会话标题失败: %v", err)
+		return nil
+	}
+	if err := m.env.DB.UpdateSessionTitle(sessionID, title); err != nil {
+		log.Printf("更新会话标题失败: %v", err)
+		return nil
+	}
+	return titleMsg{sessionID: sessionID, title: title}
+}
+
+// handleTitle 显示新生成的会话标题
+func (m *model) handleTitle(msg titleMsg) {
+	if m.session.ID == msg.sessionID {
+		m.session.Title = msg.title
+	}
+	if err := m.reloadSessions(); err != nil {
+		log.Printf("刷新会话列表失败: %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+// Package tui 实现全屏终端界面：会话列表、Markdown 渲染的消息区和多行输入框，
+// 与图形界面共用 gochat.db 和 AI 服务，两边的会话可以互相继续
+package tui
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/glamour"
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/ai"
+	"github.com/wangle201210/gochat/internal/service/assistant"
+	"github.com/wangle201210/gochat/internal/storage"
+)
+
+// Env 终端界面使用的服务
+type Env struct {
+	AI        *ai.Service
+	Assistant *assistant.Service
+	DB        *storage.Database
+	LogPath   string // 界面运行期间日志写入的文件，为空时丢弃日志
+	Stderr    io.Writer
+}
+
+// focus 当前接收按键的区域
+type focus int
+
+const (
+	focusInput focus = iota
+	focusSessions
+)
+
+// model 终端界面的状态
+type model struct {
+	env         *Env
+	autoApprove bool            // 自动允许所有工具调用
+	allowed     map[string]bool // 当前会话中无需再确认的工具
+
+	sessions []*models.Session
+	cursor   int // 会话列表中选中的位置
+	session  *models.Session
+	messages []*models.Message // 当前会话的当前分支
+	conv     *ai.Conversation  // 发送给模型的会话设置和历史
+
+	viewport viewport.Model
+	input    textarea.Model
+	focus    focus
+	width    int
+	height   int
+
+	renderer *glamour.TermRenderer
+	rendered map[string]string // 按消息 ID 缓存渲染结果，宽度变化时清空
+
+	// 生成回复期间的状态
+	events    chan tea.Msg
+	done      chan struct{}   // 关闭后后台不再向界面发送事件
+	cancel    func()          // 为空表示没有正在生成的回复
+	streaming *models.Message // 正在流式输出的回复
+	approval  *approvalMsg    // 等待用户确认的工具调用
+
+	confirmDelete bool            // 等待确认删除选中的会话
+	status        string          // 状态栏提示
+	titled        map[string]bool // 已开始生成标题的会话
+	background    sync.WaitGroup  // 等待后台写入数据库的任务
+}
+
+// Run 执行 gochat tui：启动全屏终端界面
+func Run(env *Env, args []string) error {
+	flags := flag.NewFlagSet("tui", flag.ContinueOnError)
+	flags.SetOutput(env.Stderr)
+	sessionID := flags.String("s", "", "打开指定的会话（默认打开最近的会话）")
+	autoApprove := flags.Bool("y", false, "自动允许模型发起的工具调用")
+	flags.Usage = func() {
+		fmt.Fprintln(env.Stderr, "用法: gochat tui [选项]\n\n选项:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	m := newModel(env, *autoApprove)
+	if err := m.openInitialSession(*sessionID); err != nil {
+		return err
+	}
+
+	// 全屏界面运行期间日志不能写到终端
+	restoreLog, err := redirectLog(env.LogPath)
+	if err != nil {
+		return err
+	}
+	defer restoreLog()
+
+	program := tea.NewProgram(m, tea.WithAltScreen())
+	_, err = program.Run()
+	m.shutdown()
+	if err != nil {
+		return fmt.Errorf("运行终端界面失败: %w", err)
+	}
+	return nil
+}
+
+// redirectLog 将日志写入 path，返回恢复标准错误输出的函数
+func redirectLog(path string) (func(), error) {
+	if path == "" {
+		log.SetOutput(io.Discard)
+		return func() { log.SetOutput(os.Stderr) }, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	log.SetOutput(file)
+	return func() {
+		log.SetOutput(os.Stderr)
+		file.Close()
+	}, nil
+}
+
+// newModel 创建界面状态
+func newModel(env *Env, autoApprove bool) *model {
+	input := textarea.New()
+	input.Placeholder = "输入消息，Enter 发送，Alt+Enter 换行"
+	input.ShowLineNumbers = false
+	input.CharLimit = 0
+	input.SetHeight(inputHeight)
+	input.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("alt+enter", "ctrl+j"))
+	input.Focus()
+
+	return &model{
+		env:         env,
+		autoApprove: autoApprove,
+		allowed:     make(map[string]bool),
+		viewport:    viewport.New(0, 0),
+		input:       input,
+		rendered:    make(map[string]string),
+		titled:      make(map[string]bool),
+	}
+}
+
+// openInitialSession 打开指定的会话；未指定时打开最近的会话，没有会话时创建新会话
+func (m *model) openInitialSession(sessionID string) error {
+	if err := m.reloadSessions(); err != nil {
+		return err
+	}
+
+	if sessionID != "" {
+		session, err := m.env.DB.GetSession(sessionID)
+		if err != nil {
+			return err
+		}
+		if session == nil {
+			return fmt.Errorf("会话不存在: %s", sessionID)
+		}
+		return m.openSession(session)
+	}
+
+	if len(m.sessions) > 0 {
+		return m.openSession(m.sessions[0])
+	}
+	return m.newSession()
+}
+
+// reloadSessions 从数据库重新读取会话列表，保持选中当前会话
+func (m *model) reloadSessions() error {
+	sessions, err := m.env.DB.ListSessions()
+	if err != nil {
+		return err
+	}
+	m.sessions = sessions
+	m.syncCursor()
+	return nil
+}
+
+// syncCursor 将会话列表的选中位置移动到当前会话
+func (m *model) syncCursor() {
+	if m.session == nil {
+		return
+	}
+	for i, session := range m.sessions {
+		if session.ID == m.session.ID {
+			m.cursor = i
+			return
+		}
+	}
+	m.cursor = min(m.cursor, max(len(m.sessions)-1, 0))
+}
+
+// openSession 载入会话的当前分支
+func (m *model) openSession(session *models.Session) error {
+	messages, err := m.env.DB.GetActivePath(session.ID)
+	if err != nil {
+		return err
+	}
+
+	m.session = session
+	m.messages = messages
+	m.allowed = make(map[string]bool)
+	m.conv = ai.NewConversation(session, messages)
+	m.syncCursor()
+	m.refreshMessages(true)
+	return nil
+}
+
+// newSession 创建并打开新会话
+func (m *model) newSession() error {
+	session := models.NewSession()
+	if err := m.env.DB.SaveSession(session); err != nil {
+		return err
+	}
+	m.session = session
+	if err := m.reloadSessions(); err != nil {
+		return err
+	}
+	return m.openSession(session)
+}
+
+// deleteSelectedSession 删除会话列表中选中的会话，删除的是当前会话时切换到其他会话
+func (m *model) deleteSelectedSession() error {
+	if m.cursor >= len(m.sessions) {
+		return nil
+	}
+	target := m.sessions[m.cursor]
+	if err := m.env.DB.DeleteSession(target.ID); err != nil {
+		return err
+	}
+	if err := m.reloadSessions(); err != nil {
+		return err
+	}
+	if target.ID != m.session.ID {
+		return nil
+	}
+
+	if len(m.sessions) == 0 {
+		return m.newSession()
+	}
+	return m.openSession(m.sessions[min(m.cursor, len(m.sessions)-1)])
+}
@@ -0,0 +1,181 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Init 启动输入框光标闪烁
+func (m *model) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+// Update 处理按键、窗口大小变化和后台生成的事件
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.resize()
+		return m, nil
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	case chunkMsg:
+		return m, m.handleChunk(msg)
+	case stepMsg:
+		return m, m.handleStep(msg)
+	case approvalMsg:
+		return m, m.handleApproval(msg)
+	case replyMsg:
+		return m, m.handleReply(msg)
+	case titleMsg:
+		m.handleTitle(msg)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// handleKey 处理按键：确认提示优先，其次是全局快捷键，最后交给当前焦点区域
+func (m *model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.status = ""
+
+	if m.approval != nil {
+		switch msg.String() {
+		case "y":
+			m.answerApproval(true, false)
+		case "a":
+			m.answerApproval(true, true)
+		case "n", "esc":
+			m.answerApproval(false, false)
+		case "ctrl+c":
+			m.stopReply()
+		}
+		return m, nil
+	}
+
+	if m.confirmDelete {
+		m.confirmDelete = false
+		if msg.String() == "y" {
+			if err := m.deleteSelectedSession(); err != nil {
+				m.status = fmt.Sprintf("删除会话失败: %v", err)
+			}
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "ctrl+c":
+		if m.generating() {
+			m.stopReply()
+			return m, nil
+		}
+		return m, tea.Quit
+	case "esc":
+		if m.generating() {
+			m.stopReply()
+		}
+		return m, nil
+	case "tab", "shift+tab":
+		m.toggleFocus()
+		return m, nil
+	case "ctrl+n":
+		if m.checkIdle() {
+			if err := m.newSession(); err != nil {
+				m.status = fmt.Sprintf("创建会话失败: %v", err)
+			}
+			m.setFocus(focusInput)
+		}
+		return m, nil
+	case "pgup":
+		m.viewport.PageUp()
+		return m, nil
+	case "pgdown":
+		m.viewport.PageDown()
+		return m, nil
+	case "ctrl+up":
+		m.viewport.ScrollUp(1)
+		return m, nil
+	case "ctrl+down":
+		m.viewport.ScrollDown(1)
+		return m, nil
+	}
+
+	if m.focus == focusSessions {
+		return m, m.handleSessionKey(msg)
+	}
+
+	if msg.String() == "enter" {
+		return m, m.send()
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// handleSessionKey 处理会话列表中的按键
+func (m *model) handleSessionKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, max(len(m.sessions)-1, 0))
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = max(len(m.sessions)-1, 0)
+	case "enter":
+		if m.cursor < len(m.sessions) && m.checkIdle() {
+			if err := m.openSession(m.sessions[m.cursor]); err != nil {
+				m.status = fmt.Sprintf("加载会话失败: %v", err)
+				return nil
+			}
+			m.setFocus(focusInput)
+			return textarea.Blink
+		}
+	case "d", "delete":
+		if m.cursor < len(m.sessions) && m.checkIdle() {
+			m.confirmDelete = true
+		}
+	case "r":
+		// 重新读取图形界面或其他终端中的修改
+		if err := m.reloadSessions(); err != nil {
+			m.status = fmt.Sprintf("刷新会话列表失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// checkIdle 生成回复期间不能切换或修改会话
+func (m *model) checkIdle() bool {
+	if m.generating() {
+		m.status = "正在生成回复，按 Esc 停止后再操作"
+		return false
+	}
+	return true
+}
+
+// toggleFocus 在输入框和会话列表之间切换焦点
+func (m *model) toggleFocus() {
+	if m.focus == focusInput {
+		m.setFocus(focusSessions)
+		if err := m.reloadSessions(); err != nil {
+			m.status = fmt.Sprintf("刷新会话列表失败: %v", err)
+		}
+		return
+	}
+	m.setFocus(focusInput)
+}
+
+// setFocus 设置焦点区域
+func (m *model) setFocus(f focus) {
+	m.focus = f
+	if f == focusInput {
+		m.input.Focus()
+	} else {
+		m.input.Blur()
+	}
+}
@@ -0,0 +1,233 @@
+package tui
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+const (
+	sidebarWidth = 28 // 会话列表宽度（不含边框）
+	inputHeight  = 3  // 输入框行数
+
+	// defaultMarkdownStyle 未通过 GLAMOUR_STYLE 指定时使用的 Markdown 样式
+	defaultMarkdownStyle = "dark"
+)
+
+var (
+	borderStyle  = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("240"))
+	focusedStyle = borderStyle.BorderForeground(lipgloss.Color("63"))
+	titleStyle   = lipgloss.NewStyle().Bold(true)
+	dimStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+	selectStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("230")).Background(lipgloss.Color("63"))
+	userStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("39"))
+	botStyle     = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("42"))
+	warnStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+)
+
+// View 渲染整个界面：左侧会话列表，右侧标题、消息区和输入框，底部状态栏
+func (m *model) View() string {
+	if m.width == 0 {
+		return "加载中…"
+	}
+
+	sidebarStyle, inputStyle := borderStyle, focusedStyle
+	if m.focus == focusSessions {
+		sidebarStyle, inputStyle = focusedStyle, borderStyle
+	}
+	bodyHeight := m.height - 1
+
+	sidebar := sidebarStyle.Width(sidebarWidth).Height(bodyHeight - 2).Render(m.renderSessions(bodyHeight - 2))
+	main := lipgloss.JoinVertical(lipgloss.Left,
+		m.renderHeader(),
+		m.viewport.View(),
+		inputStyle.Render(m.input.View()),
+	)
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.JoinHorizontal(lipgloss.Top, sidebar, main),
+		m.renderStatus(),
+	)
+}
+
+// resize 按窗口大小调整各区域，并按新宽度重新渲染消息
+func (m *model) resize() {
+	mainWidth := max(m.width-sidebarWidth-2, 20)
+	m.input.SetWidth(mainWidth - 2)
+	m.viewport.Width = mainWidth
+	// 状态栏、标题各一行，输入框上下边框两行
+	m.viewport.Height = max(m.height-1-1-(inputHeight+2), 1)
+
+	style := os.Getenv("GLAMOUR_STYLE")
+	if style == "" {
+		style = defaultMarkdownStyle
+	}
+	renderer, err := glamour.NewTermRenderer(glamour.WithStylePath(style), glamour.WithWordWrap(mainWidth-4))
+	if err != nil {
+		log.Printf("创建 Markdown 渲染器失败: %v", err)
+		renderer = nil
+	}
+	m.renderer = renderer
+	m.rendered = make(map[string]string)
+	m.refreshMessages(true)
+}
+
+// renderSessions 渲染会话列表，保持选中的会话可见
+func (m *model) renderSessions(height int) string {
+	if len(m.sessions) == 0 {
+		return dimStyle.Render("暂无会话")
+	}
+
+	start := 0
+	if m.cursor >= height {
+		start = m.cursor - height + 1
+	}
+	end := min(start+height, len(m.sessions))
+
+	lines := make([]string, 0, end-start)
+	for i := start; i < end; i++ {
+		session := m.sessions[i]
+		marker := "  "
+		if m.session != nil && session.ID == m.session.ID {
+			marker = "● "
+		}
+		line := ansi.Truncate(marker+session.Title, sidebarWidth, "…")
+		if i == m.cursor && m.focus == focusSessions {
+			line = selectStyle.Width(sidebarWidth).Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderHeader 渲染当前会话的标题行
+func (m *model) renderHeader() string {
+	header := titleStyle.Render(m.session.Title) + dimStyle.Render(fmt.Sprintf(" · %d 条消息 · %s", len(m.messages), m.session.ID))
+	return ansi.Truncate(header, m.viewport.Width, "…")
+}
+
+// renderStatus 渲染状态栏：确认提示、错误信息或快捷键说明
+func (m *model) renderStatus() string {
+	var text string
+	switch {
+	case m.approval != nil:
+		text = warnStyle.Render(fmt.Sprintf("模型请求调用工具 %s，参数: %s  允许执行吗？[y]是 [n]否 [a]本会话不再询问",
+			m.approval.call.Name, m.approval.call.Arguments))
+	case m.confirmDelete && m.cursor < len(m.sessions):
+		text = warnStyle.Render(fmt.Sprintf("删除会话「%s」及其所有消息？[y]确认 [其他键]取消", m.sessions[m.cursor].Title))
+	case m.status != "":
+		text = warnStyle.Render(m.status)
+	case m.generating():
+		text = dimStyle.Render("生成中… Esc 停止生成 · PgUp/PgDn 滚动")
+	case m.focus == focusSessions:
+		text = dimStyle.Render("↑/↓ 选择 · Enter 打开 · d 删除 · r 刷新 · Tab 返回输入框 · Ctrl+N 新会话 · Ctrl+C 退出")
+	default:
+		text = dimStyle.Render("Enter 发送 · Alt+Enter 换行 · Tab 会话列表 · Ctrl+N 新会话 · PgUp/PgDn 滚动 · Ctrl+C 退出")
+	}
+	return ansi.Truncate(text, m.width, "…")
+}
+
+// refreshMessages 重新渲染消息区，原本位于底部或 gotoBottom 为 true 时滚动到底部
+func (m *model) refreshMessages(gotoBottom bool) {
+	if m.width == 0 {
+		return
+	}
+
+	atBottom := m.viewport.AtBottom()
+	blocks := make([]string, 0, len(m.messages)+1)
+	for _, msg := range m.messages {
+		blocks = append(blocks, m.renderCached(msg))
+	}
+	if m.streaming != nil {
+		if m.streaming.Content == "" {
+			blocks = append(blocks, botStyle.Render("助手")+"\n"+dimStyle.Render("  思考中…"))
+		} else {
+			blocks = append(blocks, m.renderMessage(m.streaming))
+		}
+	}
+
+	m.viewport.SetContent(strings.Join(blocks, "\n\n"))
+	if gotoBottom || atBottom {
+		m.viewport.GotoBottom()
+	}
+}
+
+// renderCached 渲染消息并按 ID 缓存
+func (m *model) renderCached(msg *models.Message) string {
+	if out, ok := m.rendered[msg.ID]; ok {
+		return out
+	}
+	out := m.renderMessage(msg)
+	m.rendered[msg.ID] = out
+	return out
+}
+
+// renderMessage 渲染一条消息
+func (m *model) renderMessage(msg *models.Message) string {
+	var b strings.Builder
+	switch msg.Role {
+	case models.RoleUser:
+		b.WriteString(userStyle.Render("我") + dimStyle.Render("  "+msg.Timestamp.Format("01-02 15:04")) + "\n")
+		b.WriteString(lipgloss.NewStyle().PaddingLeft(2).Width(m.viewport.Width).Render(msg.Content))
+		for _, attachment := range msg.Attachments {
+			b.WriteString("\n" + dimStyle.Render(fmt.Sprintf("  📎 %s (%s)", attachment.Name, formatSize(attachment.Size))))
+		}
+
+	case models.RoleTool:
+		result := strings.Join(strings.Fields(msg.Content), " ")
+		b.WriteString(dimStyle.Render(ansi.Truncate(fmt.Sprintf("🔧 工具结果 %s: %s", msg.ToolName, result), m.viewport.Width, "…")))
+
+	default:
+		if len(msg.ToolCalls) == 0 {
+			b.WriteString(botStyle.Render("助手") + "\n")
+		}
+		if msg.Content != "" {
+			b.WriteString(m.renderMarkdown(msg.Content))
+		}
+		for _, call := range msg.ToolCalls {
+			b.WriteString("\n" + dimStyle.Render(ansi.Truncate(fmt.Sprintf("🔧 调用工具 %s %s", call.Name, call.Arguments), m.viewport.Width, "…")))
+		}
+		switch msg.Status {
+		case models.StatusInterrupted:
+			b.WriteString("\n" + warnStyle.Render("  ⏹ 已中断"))
+		case models.StatusFailed:
+			b.WriteString("\n" + warnStyle.Render("  ⚠ 生成失败"))
+		}
+		for _, citation := range msg.Citations {
+			b.WriteString("\n" + dimStyle.Render(fmt.Sprintf("  📎 [%d] %s（相似度 %.2f）", citation.Index, citation.Source, citation.Score)))
+		}
+		if msg.Usage != nil {
+			b.WriteString("\n" + dimStyle.Render(fmt.Sprintf("  %s · 输入 %d / 输出 %d tokens · %.1fs",
+				msg.Usage.Model, msg.Usage.PromptTokens, msg.Usage.CompletionTokens, msg.Usage.Latency.Seconds())))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// renderMarkdown 将回复渲染为终端 Markdown，渲染失败时按原文显示
+func (m *model) renderMarkdown(content string) string {
+	if m.renderer != nil {
+		if out, err := m.renderer.Render(content); err == nil {
+			return strings.Trim(out, "\n")
+		}
+	}
+	return lipgloss.NewStyle().PaddingLeft(2).Width(m.viewport.Width).Render(content)
+}
+
+// formatSize 格式化文件大小
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
@@ -0,0 +1,230 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+const (
+	// maxImageAttachmentSize 图片附件的大小上限
+	maxImageAttachmentSize = 10 << 20
+	// maxTextAttachmentSize 文本附件的大小上限，文本会全部附在提示词中
+	maxTextAttachmentSize = 512 << 10
+)
+
+// supportedImageTypes 可以作为多模态内容发送的图片类型
+var supportedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// newAttachment 根据文件名和内容创建附件：图片按类型校验，其余文件必须是 UTF-8 文本
+func newAttachment(name string, data []byte) (*models.Attachment, error) {
+	mimeType := detectMimeType(name, data)
+
+	if strings.HasPrefix(mimeType, "image/") {
+		if !supportedImageTypes[mimeType] {
+			return nil, fmt.Errorf("%s: 不支持的图片格式 %s", name, mimeType)
+		}
+		if len(data) > maxImageAttachmentSize {
+			return nil, fmt.Errorf("%s: 图片超过 %s", name, formatSize(maxImageAttachmentSize))
+		}
+		return models.NewAttachment(name, mimeType, data), nil
+	}
+
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return nil, fmt.Errorf("%s: 只支持图片和文本文件", name)
+	}
+	if len(data) > maxTextAttachmentSize {
+		return nil, fmt.Errorf("%s: 文本文件超过 %s", name, formatSize(maxTextAttachmentSize))
+	}
+
+	attachment := models.NewAttachment(name, mimeType, data)
+	if !attachment.IsText() {
+		// 源代码等没有注册类型的文本文件统一按纯文本处理
+		attachment.MimeType = "text/plain"
+	}
+	return attachment, nil
+}
+
+// detectMimeType 优先按扩展名判断类型，无法判断时根据内容识别
+func detectMimeType(name string, data []byte) string {
+	if mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(name))); mimeType != "" {
+		mediaType, _, err := mime.ParseMediaType(mimeType)
+		if err == nil {
+			return mediaType
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	return mediaType
+}
+
+// showAttachmentPicker 打开文件选择框添加附件
+func (cw *ChatWindow) showAttachmentPicker() {
+	fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, cw.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("读取文件失败: %w", err), cw.window)
+			return
+		}
+		cw.addAttachment(reader.URI().Name(), data)
+	}, cw.window)
+	fileDialog.Resize(fyne.NewSize(640, 480))
+	fileDialog.Show()
+}
+
+// handleDrop 处理拖放到窗口上的文件，目录会被忽略
+func (cw *ChatWindow) handleDrop(_ fyne.Position, uris []fyne.URI) {
+	for _, uri := range uris {
+		info, err := os.Stat(uri.Path())
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(uri.Path())
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("读取文件失败: %w", err), cw.window)
+			continue
+		}
+		cw.addAttachment(uri.Name(), data)
+	}
+}
+
+// addAttachment 校验文件并加入待发送附件
+func (cw *ChatWindow) addAttachment(name string, data []byte) {
+	attachment, err := newAttachment(name, data)
+	if err != nil {
+		dialog.ShowError(err, cw.window)
+		return
+	}
+
+	cw.pendingAttachments = append(cw.pendingAttachments, attachment)
+	cw.refreshAttachmentBar()
+}
+
+// removeAttachment 从待发送附件中移除
+func (cw *ChatWindow) removeAttachment(id string) {
+	for i, a := range cw.pendingAttachments {
+		if a.ID == id {
+			cw.pendingAttachments = append(cw.pendingAttachments[:i], cw.pendingAttachments[i+1:]...)
+			break
+		}
+	}
+	cw.refreshAttachmentBar()
+}
+
+// takeAttachments 取出待发送附件并清空附件栏
+func (cw *ChatWindow) takeAttachments() []*models.Attachment {
+	attachments := cw.pendingAttachments
+	cw.pendingAttachments = nil
+	cw.refreshAttachmentBar()
+	return attachments
+}
+
+// refreshAttachmentBar 重建输入框上方的附件栏，没有附件时隐藏
+func (cw *ChatWindow) refreshAttachmentBar() {
+	chips := container.NewHBox()
+	for _, a := range cw.pendingAttachments {
+		removeBtn := widget.NewButton("✕", func() {
+			cw.removeAttachment(a.ID)
+		})
+		removeBtn.Importance = widget.LowImportance
+		chips.Add(container.NewHBox(widget.NewLabel(attachmentLabel(a)), removeBtn))
+	}
+
+	cw.attachmentBar.Objects = []fyne.CanvasObject{container.NewHScroll(chips)}
+	if len(cw.pendingAttachments) == 0 {
+		cw.attachmentBar.Hide()
+	} else {
+		cw.attachmentBar.Show()
+	}
+	cw.attachmentBar.Refresh()
+}
+
+// newAttachmentsView 创建消息卡片中的附件展示：图片显示缩略图，其他文件显示文件名和大小
+func (cw *ChatWindow) newAttachmentsView(attachments []*models.Attachment) fyne.CanvasObject {
+	images := container.NewHBox()
+	files := container.NewHBox()
+	for _, a := range attachments {
+		if !a.IsImage() {
+			files.Add(widget.NewLabel(attachmentLabel(a)))
+			continue
+		}
+
+		thumbnail := canvas.NewImageFromResource(fyne.NewStaticResource(a.Name, a.Data))
+		thumbnail.FillMode = canvas.ImageFillContain
+		thumbnail.SetMinSize(fyne.NewSize(160, 120))
+
+		// 点击缩略图查看大图
+		openBtn := widget.NewButton("", func() {
+			cw.showImagePreview(a)
+		})
+		openBtn.Importance = widget.LowImportance
+		images.Add(container.NewStack(thumbnail, openBtn))
+	}
+
+	view := container.NewVBox()
+	if len(images.Objects) > 0 {
+		view.Add(container.NewHScroll(images))
+	}
+	if len(files.Objects) > 0 {
+		view.Add(container.NewHScroll(files))
+	}
+	return view
+}
+
+// showImagePreview 在对话框中显示图片原图
+func (cw *ChatWindow) showImagePreview(a *models.Attachment) {
+	image := canvas.NewImageFromResource(fyne.NewStaticResource(a.Name, a.Data))
+	image.FillMode = canvas.ImageFillContain
+	image.SetMinSize(fyne.NewSize(640, 480))
+
+	preview := dialog.NewCustom(a.Name, "关闭", image, cw.window)
+	preview.Show()
+}
+
+// attachmentLabel 返回附件的图标、文件名和大小
+func attachmentLabel(a *models.Attachment) string {
+	icon := "📄"
+	if a.IsImage() {
+		icon = "🖼"
+	}
+	return fmt.Sprintf("%s %s (%s)", icon, a.Name, formatSize(a.Size))
+}
+
+// formatSize 格式化文件大小
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
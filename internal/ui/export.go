@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"github.com/wangle201210/gochat/internal/exporter"
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// onExportSession 会话菜单中导出回调：选择保存位置后写出会话
+func (cw *ChatWindow) onExportSession(session *models.Session, format exporter.Format) {
+	conv, err := exporter.Load(cw.db, session.ID)
+	if err != nil {
+		log.Printf("读取会话失败: %v", err)
+		dialog.ShowError(err, cw.window)
+		return
+	}
+
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, cw.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		if err := exporter.Write(writer, conv, format); err != nil {
+			log.Printf("导出会话失败: %v", err)
+			dialog.ShowError(fmt.Errorf("导出会话失败: %w", err), cw.window)
+			return
+		}
+		dialog.ShowInformation("导出完成", "会话已导出到 "+writer.URI().Path(), cw.window)
+	}, cw.window)
+	saveDialog.SetFileName(exporter.FileName(session, format))
+	saveDialog.Resize(fyne.NewSize(640, 480))
+	saveDialog.Show()
+}
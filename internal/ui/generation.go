@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"fyne.io/fyne/v2/widget"
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/generation"
+)
+
+// placeholder 界面末尾显示生成中回复的占位消息，切换或新建会话重建界面后失效
+type placeholder struct {
+	gen      *generation.Generation
+	index    int
+	richText *widget.RichText
+}
+
+// generating 当前会话是否正在生成回复
+func (cw *ChatWindow) generating() bool {
+	return cw.currentSession != nil && cw.generations.Get(cw.currentSession.ID) != nil
+}
+
+// showingGeneration 界面是否正在显示发起该生成的对话
+func (cw *ChatWindow) showingGeneration(g *generation.Generation) bool {
+	return cw.conversation == g.Conversation()
+}
+
+// placeholderOf 返回界面上该生成的占位消息，没有时返回 nil
+func (cw *ChatWindow) placeholderOf(g *generation.Generation) *placeholder {
+	if cw.placeholder != nil && cw.placeholder.gen == g {
+		return cw.placeholder
+	}
+	return nil
+}
+
+// ensurePlaceholder 在界面末尾显示生成中的占位消息，已显示时不重复添加
+func (cw *ChatWindow) ensurePlaceholder(g *generation.Generation) *placeholder {
+	if p := cw.placeholderOf(g); p != nil {
+		return p
+	}
+	richText := cw.addMessage(models.NewMessage(models.RoleAssistant, g.PlaceholderText()))
+	cw.placeholder = &placeholder{gen: g, index: len(cw.messages) - 1, richText: richText}
+	return cw.placeholder
+}
+
+// setText 更新占位消息显示的内容
+func (p *placeholder) setText(cw *ChatWindow, text string) {
+	cw.messages[p.index].Content = text
+	p.richText.ParseMarkdown(text)
+}
+
+// updateSendButton 当前会话正在生成时显示停止按钮，否则显示发送按钮
+func (cw *ChatWindow) updateSendButton() {
+	if cw.generating() {
+		cw.sendButton.Hide()
+		cw.stopButton.Show()
+	} else {
+		cw.stopButton.Hide()
+		cw.sendButton.Show()
+	}
+}
@@ -2,19 +2,27 @@ package ui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
 	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/generation"
 )
 
 // handleSend 处理发送消息
 func (cw *ChatWindow) handleSend() {
+	// 正在生成回复时忽略新的发送请求
+	if cw.generating() {
+		return
+	}
+
 	userInput := strings.TrimSpace(cw.inputEntry.Text)
-	if userInput == "" {
+	if userInput == "" && len(cw.pendingAttachments) == 0 {
 		return
 	}
 
@@ -26,63 +34,283 @@ func (cw *ChatWindow) handleSend() {
 	// 立即清空输入框（不阻塞）
 	cw.inputEntry.SetText("")
 
-	// 禁用发送按钮，防止重复发送
-	cw.sendButton.Disable()
-
-	// 立即添加用户消息到界面（不阻塞）
+	// 保存用户消息到数据库，接在当前分支末尾
 	userMsg := models.NewMessage(models.RoleUser, userInput)
+	userMsg.ParentID = cw.lastMessageID()
+	userMsg.Attachments = cw.takeAttachments()
+	if err := cw.db.SaveMessage(cw.currentSession.ID, userMsg); err != nil {
+		dialog.ShowError(err, cw.window)
+	}
+
+	// 立即添加用户消息到界面（不阻塞），并在排队前写入对话历史，
+	// 排队期间被停止时对话历史与数据库保持一致
 	cw.addMessage(userMsg)
+	cw.syncHistory()
 
-	// 保存用户消息到数据库
-	if err := cw.db.SaveMessage(cw.currentSession.ID, userMsg); err != nil {
+	cw.streamReply(cw.aiService.StreamReply)
+}
+
+// handleRetry 从生成失败的消息处重试：删除该消息所在的分支并重新生成回复
+func (cw *ChatWindow) handleRetry(msg *models.Message) {
+	if cw.generating() || cw.currentSession == nil {
+		return
+	}
+
+	index := cw.messageIndex(msg.ID)
+	if index < 0 {
+		return
+	}
+
+	retry := func() {
+		if err := cw.db.DeleteBranch(cw.currentSession.ID, msg.ID); err != nil {
+			dialog.ShowError(err, cw.window)
+			return
+		}
+		cw.loadBranches(cw.currentSession.ID)
+
+		cw.truncateMessages(index)
+		cw.streamReply(cw.aiService.StreamReply)
+	}
+
+	// 后面还有消息时先确认
+	if index < len(cw.messages)-1 {
+		dialog.ShowConfirm("确认重试", "从此处重试将删除这条消息之后的所有消息，是否继续？", func(ok bool) {
+			if ok {
+				retry()
+			}
+		}, cw.window)
+		return
+	}
+	retry()
+}
+
+// handleRegenerate 重新生成最后一条助手回复，旧回复作为另一个版本保留
+func (cw *ChatWindow) handleRegenerate(msg *models.Message) {
+	if cw.generating() || cw.currentSession == nil {
+		return
+	}
+
+	index := cw.messageIndex(msg.ID)
+	if index < 0 || index != len(cw.messages)-1 {
+		return
+	}
+
+	// 回复前可能有工具调用过程，一并回退到最后一条用户消息之后
+	for index > 0 && cw.messages[index-1].Role != models.RoleUser {
+		index--
+	}
+
+	// 只移除界面上的旧回复，AI 历史由 RegenerateReply 自行回退
+	cw.messages = cw.messages[:index]
+	cw.messageContainer.Objects = cw.messageContainer.Objects[:index]
+	cw.messageContainer.Refresh()
+
+	cw.streamReply(cw.aiService.RegenerateReply)
+}
+
+// handleEdit 编辑用户消息：以改写后的内容创建一个新分支并重新生成回复，原对话保留在旧分支中
+func (cw *ChatWindow) handleEdit(msg *models.Message) {
+	if cw.generating() || cw.currentSession == nil {
+		return
+	}
+
+	entry := widget.NewMultiLineEntry()
+	entry.Wrapping = fyne.TextWrapWord
+	entry.SetText(msg.Content)
+	entry.SetMinRowsVisible(6)
+
+	hint := widget.NewLabel("保存后将创建新的对话分支并重新生成回复，原对话可通过 ◀ ▶ 切换查看")
+	hint.Wrapping = fyne.TextWrapWord
+
+	editDialog := dialog.NewForm("编辑消息", "保存并重新生成", "取消", []*widget.FormItem{
+		widget.NewFormItem("", entry),
+		widget.NewFormItem("", hint),
+	}, func(ok bool) {
+		content := strings.TrimSpace(entry.Text)
+		if !ok || (content == "" && len(msg.Attachments) == 0) || cw.generating() {
+			return
+		}
+
+		index := cw.messageIndex(msg.ID)
+		if index < 0 {
+			return
+		}
+
+		// 新消息与原消息共享父消息，成为其兄弟分支
+		edited := models.NewMessage(models.RoleUser, content)
+		edited.ParentID = msg.ParentID
+		// 附件随编辑后的消息一起保留
+		for _, a := range msg.Attachments {
+			edited.Attachments = append(edited.Attachments, models.NewAttachment(a.Name, a.MimeType, a.Data))
+		}
+		if err := cw.db.SaveMessage(cw.currentSession.ID, edited); err != nil {
+			dialog.ShowError(err, cw.window)
+			return
+		}
+		cw.loadBranches(cw.currentSession.ID)
+
+		cw.truncateMessages(index)
+		cw.addMessage(edited)
+		cw.syncHistory()
+		cw.streamReply(cw.aiService.StreamReply)
+	}, cw.window)
+	editDialog.Resize(fyne.NewSize(520, 320))
+	editDialog.Show()
+}
+
+// messageIndex 返回消息在当前列表中的位置，找不到时返回 -1
+func (cw *ChatWindow) messageIndex(messageID string) int {
+	for i, m := range cw.messages {
+		if m.ID == messageID {
+			return i
+		}
+	}
+	return -1
+}
+
+// lastMessageID 返回当前分支最后一条消息的 ID，没有消息时返回空
+func (cw *ChatWindow) lastMessageID() string {
+	if len(cw.messages) == 0 {
+		return ""
+	}
+	return cw.messages[len(cw.messages)-1].ID
+}
+
+// truncateMessages 只保留前 n 条消息，并同步发送给模型的历史
+func (cw *ChatWindow) truncateMessages(n int) {
+	cw.messages = cw.messages[:n]
+	cw.messageContainer.Objects = cw.messageContainer.Objects[:n]
+	cw.messageContainer.Refresh()
+	cw.syncHistory()
+}
+
+// syncHistory 用界面上的当前分支覆盖发送给模型的历史
+func (cw *ChatWindow) syncHistory() {
+	cw.conversation.SetHistory(cw.messages)
+}
+
+// switchBranch 切换到包含指定消息的分支并重新加载会话
+func (cw *ChatWindow) switchBranch(messageID string) {
+	if cw.generating() || cw.currentSession == nil {
+		return
+	}
+
+	if err := cw.db.SelectBranch(cw.currentSession.ID, messageID); err != nil {
 		dialog.ShowError(err, cw.window)
+		return
 	}
 
-	// 创建一个占位消息用于流式更新
-	assistantMsg := models.NewMessage(models.RoleAssistant, "正在思考...")
-	assistantRichText := cw.addMessage(assistantMsg)
-	assistantIndex := len(cw.messages) - 1
+	cw.loadSession(cw.currentSession)
+	cw.scrollToMessage(messageID)
+}
 
-	// 异步获取 AI 回复（不阻塞 UI）
-	go func() {
-		ctx := context.Background()
-		var fullResponse strings.Builder
-
-		err := cw.aiService.StreamChat(ctx, userInput, func(chunk string) error {
-			fullResponse.WriteString(chunk)
-			currentContent := fullResponse.String()
-
-			// 在主线程中更新 UI - 使用 Fyne 提供的线程安全方法
-			fyne.Do(func() {
-				cw.messages[assistantIndex].Content = currentContent
-				// 更新 RichText 的 Markdown 内容
-				assistantRichText.ParseMarkdown(currentContent)
-				cw.scrollToBottom()
-			})
+// streamReply 在界面末尾追加占位消息并在后台流式生成回复
+// generate 负责在 conv 上调用 AI 服务；生成由 cw.generations 按会话跟踪，期间切换会话不影响生成，
+// 回复保存到发起生成的会话，界面只在显示该会话时更新
+func (cw *ChatWindow) streamReply(generate generation.GenerateFunc) {
+	conv := cw.conversation
+	sessionID := cw.currentSession.ID
+	ctx, gen := cw.generations.Start(sessionID, conv, cw.aiService.Provider())
 
-			return nil
+	// 切换为停止按钮，防止重复发送
+	cw.updateSendButton()
+	cw.ensurePlaceholder(gen)
+
+	// 异步获取 AI 回复（不阻塞 UI），界面更新通过 fyne.Do 回到主线程
+	go func() {
+		reply, err := cw.generations.Run(ctx, gen, generate, generation.Events{
+			OnStart: func() {
+				fyne.Do(func() {
+					if p := cw.placeholderOf(gen); p != nil && gen.Content() == "" {
+						p.setText(cw, gen.PlaceholderText())
+					}
+				})
+			},
+			OnChunk: func(content string) {
+				fyne.Do(func() {
+					if !cw.showingGeneration(gen) {
+						return
+					}
+					// 更新 RichText 的 Markdown 内容
+					cw.ensurePlaceholder(gen).setText(cw, content)
+					cw.scrollToBottom()
+				})
+			},
+			OnMessage: func(msg *models.Message) {
+				// 工具调用的中间消息：保存并显示为工具卡片
+				fyne.Do(func() {
+					if err := cw.generations.Save(gen, msg); err != nil {
+						log.Printf("保存工具调用消息失败: %v", err)
+					}
+					if gen.Discarded() || !cw.showingGeneration(gen) {
+						return
+					}
+					cw.loadBranches(sessionID)
+					if p := cw.placeholderOf(gen); p != nil && msg.Role == models.RoleAssistant {
+						cw.placeholder = nil
+						cw.updateMessage(p.index, msg)
+					} else {
+						cw.addMessage(msg)
+					}
+					cw.scrollToBottom()
+				})
+			},
+			Approve: func(ctx context.Context, call models.ToolCall) bool {
+				return cw.approveToolCall(ctx, sessionID, call)
+			},
 		})
 
-		// 在主线程中处理错误和完成操作
+		// 在主线程中保存结果并更新界面
 		fyne.Do(func() {
-			if err != nil {
-				errMsg := fmt.Sprintf("错误: %v", err)
-				cw.messages[assistantIndex].Content = errMsg
-				assistantRichText.ParseMarkdown(errMsg)
-				dialog.ShowError(err, cw.window)
-			} else {
-				// 保存 AI 回复到数据库
-				if err := cw.db.SaveMessage(cw.currentSession.ID, cw.messages[assistantIndex]); err != nil {
-					dialog.ShowError(err, cw.window)
+			queued := gen.Queued()
+			saved, saveErr := cw.generations.Finish(gen, reply, err)
+			if gen.Discarded() {
+				cw.queue.Clear(sessionID)
+				return
+			}
+			if queued {
+				// 排队期间被停止：移除占位消息，用户消息已在对话历史中
+				if p := cw.placeholderOf(gen); p != nil {
+					cw.placeholder = nil
+					cw.truncateMessages(p.index)
 				}
+				cw.updateSendButton()
+				return
+			}
 
-				// 异步生成/更新会话标题
-				go cw.generateSessionTitle()
+			var p *placeholder
+			if cw.showingGeneration(gen) {
+				// 正在查看该会话，不需要保留完成或失败的标记
+				cw.queue.MarkSeen(sessionID)
+				p = cw.ensurePlaceholder(gen)
+				cw.placeholder = nil
+			}
+			if saveErr != nil {
+				dialog.ShowError(saveErr, cw.window)
+			}
+			switch {
+			case p == nil:
+			case saved != nil:
+				// 正常完成、用户中止或出错时保留的部分内容
+				cw.loadBranches(sessionID)
+				cw.updateMessage(p.index, saved)
+			case err != nil:
+				p.setText(cw, fmt.Sprintf("错误: %v", err))
+			}
+			if err != nil && !errors.Is(err, context.Canceled) {
+				dialog.ShowError(err, cw.window)
 			}
 
-			// 完成后滚动到底部并重新启用发送按钮
-			cw.scrollToBottom()
-			cw.sendButton.Enable()
+			// 异步生成/更新会话标题
+			if err == nil {
+				go cw.generateSessionTitle(sessionID, conv.History())
+			}
+
+			// 完成后滚动到底部并恢复发送按钮
+			cw.updateSendButton()
+			if p != nil {
+				cw.scrollToBottom()
+			}
 
 			// 刷新会话列表以更新时间戳
 			cw.refreshSessionList()
@@ -90,14 +318,21 @@ func (cw *ChatWindow) handleSend() {
 	}()
 }
 
-// generateSessionTitle 生成会话标题
-func (cw *ChatWindow) generateSessionTitle() {
-	if cw.currentSession == nil || cw.assistantService == nil {
+// handleStop 中止当前会话正在进行的流式生成
+func (cw *ChatWindow) handleStop() {
+	if cw.currentSession != nil {
+		cw.generations.Stop(cw.currentSession.ID, false)
+	}
+}
+
+// generateSessionTitle 根据会话的当前分支生成会话标题
+func (cw *ChatWindow) generateSessionTitle(sessionID string, messages []*models.Message) {
+	if cw.assistantService == nil {
 		return
 	}
 
 	// 获取最近4组对话（最多8条消息）
-	recentMessages := cw.messages
+	recentMessages := messages
 	if len(recentMessages) > 8 {
 		recentMessages = recentMessages[len(recentMessages)-8:]
 	}
@@ -116,14 +351,17 @@ func (cw *ChatWindow) generateSessionTitle() {
 	}
 
 	// 更新数据库中的标题
-	if err := cw.db.UpdateSessionTitle(cw.currentSession.ID, title); err != nil {
+	if err := cw.db.UpdateSessionTitle(sessionID, title); err != nil {
 		log.Printf("更新会话标题失败: %v", err)
 		return
 	}
 
 	// 在主线程更新界面
 	fyne.Do(func() {
-		cw.currentSession.Title = title
+		if cw.currentSession != nil && cw.currentSession.ID == sessionID {
+			cw.currentSession.Title = title
+			cw.titleLabel.SetText(title)
+		}
 		cw.refreshSessionList()
 	})
 }
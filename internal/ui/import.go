@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+	"github.com/wangle201210/gochat/internal/importer"
+)
+
+// showImportPicker 选择 ChatGPT 等客户端的导出文件并导入
+func (cw *ChatWindow) showImportPicker() {
+	fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, cw.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("读取文件失败: %w", err), cw.window)
+			return
+		}
+		cw.importConversations(reader.URI().Name(), data)
+	}, cw.window)
+	fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".json", ".zip", ".md", ".markdown", ".txt"}))
+	fileDialog.Resize(fyne.NewSize(640, 480))
+	fileDialog.Show()
+}
+
+// importConversations 在后台解析并导入会话，显示进度并允许取消
+func (cw *ChatWindow) importConversations(name string, data []byte) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	statusLabel := widget.NewLabel("正在解析 " + name + "...")
+	statusLabel.Truncation = fyne.TextTruncateEllipsis
+	progressBar := widget.NewProgressBar()
+
+	progressDialog := dialog.NewCustom("导入对话", "取消", container.NewVBox(statusLabel, progressBar), cw.window)
+	progressDialog.SetOnClosed(cancel)
+	progressDialog.Resize(fyne.NewSize(420, 160))
+	progressDialog.Show()
+
+	go func() {
+		var result *importer.Result
+		sessions, err := importer.Parse(name, data)
+		if err == nil {
+			result, err = importer.Import(ctx, cw.db, sessions, func(p importer.Progress) {
+				fyne.Do(func() {
+					if p.Total > 0 {
+						progressBar.SetValue(float64(p.Done) / float64(p.Total))
+					}
+					if p.Title != "" {
+						statusLabel.SetText(fmt.Sprintf("(%d/%d) %s", p.Done+1, p.Total, p.Title))
+					}
+				})
+			})
+		}
+
+		fyne.Do(func() {
+			canceled := ctx.Err() != nil
+			progressDialog.Hide()
+			if result != nil {
+				cw.refreshSessionList()
+			}
+
+			switch {
+			case errors.Is(err, context.Canceled) || canceled:
+				dialog.ShowInformation("导入已取消", "已导入的会话会保留，再次导入同一文件时继续导入剩余会话。", cw.window)
+			case err != nil:
+				dialog.ShowError(err, cw.window)
+			default:
+				message := fmt.Sprintf("新建 %d 个会话，更新 %d 个，跳过已导入的 %d 个\n共写入 %d 条消息",
+					result.Created, result.Updated, result.Skipped, result.Messages)
+				dialog.ShowInformation("导入完成", message, cw.window)
+			}
+		})
+	}()
+}
@@ -0,0 +1,227 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/knowledge"
+)
+
+// noKnowledgeBase 知识库选择框中表示不使用知识库的选项
+const noKnowledgeBase = "不使用知识库"
+
+// showKnowledgePanel 显示知识库面板：为当前会话选择知识库、添加文件夹、重建索引或删除知识库
+func (cw *ChatWindow) showKnowledgePanel() {
+	session := cw.currentSession
+	if session == nil {
+		return
+	}
+	if cw.knowledge == nil {
+		dialog.ShowInformation("知识库", "知识库服务未启用，请检查配置文件中的 knowledge.embedding 设置", cw.window)
+		return
+	}
+
+	kbs, err := cw.db.ListKnowledgeBases()
+	if err != nil {
+		dialog.ShowError(err, cw.window)
+		return
+	}
+
+	// 选项显示名称和文件夹，避免同名知识库无法区分
+	options := []string{noKnowledgeBase}
+	byOption := make(map[string]*models.KnowledgeBase, len(kbs))
+	for _, kb := range kbs {
+		option := fmt.Sprintf("%s (%s)", kb.Name, kb.Path)
+		options = append(options, option)
+		byOption[option] = kb
+	}
+
+	infoLabel := widget.NewLabel("")
+	infoLabel.Wrapping = fyne.TextWrapWord
+
+	kbSelect := widget.NewSelect(options, nil)
+	var panel dialog.Dialog
+
+	reindexBtn := widget.NewButton("↻ 更新索引", func() {
+		if kb := byOption[kbSelect.Selected]; kb != nil {
+			panel.Hide()
+			cw.indexKnowledgeBase(kb)
+		}
+	})
+	deleteBtn := widget.NewButton("🗑 删除", func() {
+		kb := byOption[kbSelect.Selected]
+		if kb == nil {
+			return
+		}
+		dialog.ShowConfirm("删除知识库", fmt.Sprintf("确定删除知识库 %s 的索引吗？文件夹中的文件不会被删除。", kb.Name), func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := cw.db.DeleteKnowledgeBase(kb.ID); err != nil {
+				dialog.ShowError(err, cw.window)
+				return
+			}
+			if cw.currentSession != nil && cw.currentSession.KnowledgeBaseID == kb.ID {
+				cw.currentSession.KnowledgeBaseID = ""
+				cw.conversation.SetSession(cw.currentSession)
+			}
+			panel.Hide()
+			cw.showKnowledgePanel()
+		}, cw.window)
+	})
+	deleteBtn.Importance = widget.DangerImportance
+
+	kbSelect.OnChanged = func(option string) {
+		kb := byOption[option]
+		if kb == nil {
+			infoLabel.SetText("回复时不检索知识库")
+			reindexBtn.Disable()
+			deleteBtn.Disable()
+			return
+		}
+		reindexBtn.Enable()
+		deleteBtn.Enable()
+		infoLabel.SetText(cw.knowledgeBaseInfo(kb))
+	}
+
+	kbSelect.SetSelected(noKnowledgeBase)
+	for option, kb := range byOption {
+		if kb.ID == session.KnowledgeBaseID {
+			kbSelect.SetSelected(option)
+		}
+	}
+
+	addBtn := widget.NewButton("📁 添加文件夹...", func() {
+		folderDialog := dialog.NewFolderOpen(func(uri fyne.ListableURI, err error) {
+			if err != nil {
+				dialog.ShowError(err, cw.window)
+				return
+			}
+			if uri == nil {
+				return
+			}
+
+			kb := models.NewKnowledgeBase(filepath.Base(uri.Path()), uri.Path())
+			if err := cw.db.SaveKnowledgeBase(kb); err != nil {
+				dialog.ShowError(err, cw.window)
+				return
+			}
+			panel.Hide()
+			cw.attachKnowledgeBase(session, kb.ID)
+			cw.indexKnowledgeBase(kb)
+		}, cw.window)
+		folderDialog.Resize(fyne.NewSize(640, 480))
+		folderDialog.Show()
+	})
+
+	hint := widget.NewLabel("支持 Markdown、TXT 和 PDF 文件。文件有改动后点击“更新索引”，只会处理新增或修改过的文件。")
+	hint.Wrapping = fyne.TextWrapWord
+
+	content := container.NewVBox(
+		widget.NewForm(widget.NewFormItem("当前会话", kbSelect)),
+		infoLabel,
+		container.NewHBox(addBtn, reindexBtn, deleteBtn),
+		widget.NewSeparator(),
+		hint,
+	)
+
+	panel = dialog.NewCustomConfirm("📚 知识库", "保存", "取消", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		var kbID string
+		if kb := byOption[kbSelect.Selected]; kb != nil {
+			kbID = kb.ID
+		}
+		cw.attachKnowledgeBase(session, kbID)
+	}, cw.window)
+	panel.Resize(fyne.NewSize(560, 360))
+	panel.Show()
+}
+
+// knowledgeBaseInfo 返回知识库的文件夹、文件数和片段数说明
+func (cw *ChatWindow) knowledgeBaseInfo(kb *models.KnowledgeBase) string {
+	docs, err := cw.db.ListKnowledgeDocuments(kb.ID)
+	if err != nil {
+		log.Printf("查询知识库文件失败: %v", err)
+		return kb.Path
+	}
+
+	chunks := 0
+	for _, doc := range docs {
+		chunks += doc.Chunks
+	}
+
+	info := fmt.Sprintf("文件夹: %s\n已索引 %d 个文件，共 %d 个片段", kb.Path, len(docs), chunks)
+	if kb.EmbeddingModel != "" && kb.EmbeddingModel != cw.knowledge.ModelID() {
+		info += fmt.Sprintf("\n⚠ 索引使用的向量模型 %s 与当前配置不一致，请更新索引", kb.EmbeddingModel)
+	}
+	return info
+}
+
+// attachKnowledgeBase 设置会话关联的知识库，kbID 为空表示不使用知识库
+func (cw *ChatWindow) attachKnowledgeBase(session *models.Session, kbID string) {
+	if err := cw.db.UpdateSessionKnowledgeBase(session.ID, kbID); err != nil {
+		dialog.ShowError(err, cw.window)
+		return
+	}
+	session.KnowledgeBaseID = kbID
+
+	// 当前会话的后续请求立即使用新知识库
+	if cw.currentSession != nil && cw.currentSession.ID == session.ID {
+		cw.conversation.SetSession(session)
+	}
+}
+
+// indexKnowledgeBase 在后台增量索引知识库，显示进度并允许取消
+func (cw *ChatWindow) indexKnowledgeBase(kb *models.KnowledgeBase) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	statusLabel := widget.NewLabel("正在扫描文件...")
+	statusLabel.Truncation = fyne.TextTruncateEllipsis
+	progressBar := widget.NewProgressBar()
+
+	progressDialog := dialog.NewCustom("正在建立索引: "+kb.Name, "取消", container.NewVBox(statusLabel, progressBar), cw.window)
+	progressDialog.SetOnClosed(cancel)
+	progressDialog.Resize(fyne.NewSize(420, 160))
+	progressDialog.Show()
+
+	go func() {
+		result, err := cw.knowledge.Index(ctx, kb, func(p knowledge.IndexProgress) {
+			fyne.Do(func() {
+				if p.Total > 0 {
+					progressBar.SetValue(float64(p.Done) / float64(p.Total))
+				}
+				if p.Path != "" {
+					statusLabel.SetText(fmt.Sprintf("(%d/%d) %s", p.Done+1, p.Total, p.Path))
+				}
+			})
+		})
+
+		fyne.Do(func() {
+			canceled := ctx.Err() != nil
+			progressDialog.Hide()
+
+			switch {
+			case errors.Is(err, context.Canceled) || canceled:
+				dialog.ShowInformation("索引已取消", "已完成的文件会保留索引，下次更新时继续处理剩余文件。", cw.window)
+			case err != nil:
+				dialog.ShowError(err, cw.window)
+			default:
+				message := fmt.Sprintf("新建或更新 %d 个文件，跳过未修改的 %d 个，移除 %d 个", result.Indexed, result.Skipped, result.Removed)
+				if result.Failed > 0 {
+					message += fmt.Sprintf("\n%d 个文件处理失败，详情见日志", result.Failed)
+				}
+				dialog.ShowInformation("索引完成", message, cw.window)
+			}
+		})
+	}()
+}
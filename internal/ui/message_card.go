@@ -1,12 +1,17 @@
 package ui
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"image/color"
+	"log"
 	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/wangle201210/gochat/internal/models"
 )
@@ -31,6 +36,35 @@ func normalizeEmoji(content string) string {
 func (cw *ChatWindow) addMessage(msg *models.Message) *widget.RichText {
 	cw.messages = append(cw.messages, msg)
 
+	card, richText := cw.newMessageCard(msg)
+
+	// 添加到消息容器
+	cw.messageContainer.Add(card)
+
+	// 上一条助手回复不再是最新的，重建以移除"重新生成"按钮
+	if n := len(cw.messages); n >= 2 && cw.messages[n-2].Role == models.RoleAssistant {
+		cw.updateMessage(n-2, cw.messages[n-2])
+	}
+
+	cw.scrollToBottom()
+
+	return richText
+}
+
+// updateMessage 用新的消息内容重建指定位置的消息卡片
+func (cw *ChatWindow) updateMessage(index int, msg *models.Message) {
+	if index < 0 || index >= len(cw.messages) || index >= len(cw.messageContainer.Objects) {
+		return
+	}
+
+	cw.messages[index] = msg
+	card, _ := cw.newMessageCard(msg)
+	cw.messageContainer.Objects[index] = card
+	cw.messageContainer.Refresh()
+}
+
+// newMessageCard 创建消息卡片，返回卡片和消息内容的 RichText 引用（仅助手消息）
+func (cw *ChatWindow) newMessageCard(msg *models.Message) (fyne.CanvasObject, *widget.RichText) {
 	// 规范化消息内容中的 emoji
 	displayContent := normalizeEmoji(msg.Content)
 
@@ -47,14 +81,25 @@ func (cw *ChatWindow) addMessage(msg *models.Message) *widget.RichText {
 		contentLabel := widget.NewLabel(displayContent)
 		contentLabel.Wrapping = fyne.TextWrapWord
 
+		// 编辑按钮：改写这条消息并重新生成回复
+		editBtn := widget.NewButton("✎ 编辑", func() {
+			cw.handleEdit(msg)
+		})
+		editBtn.Importance = widget.LowImportance
+
 		// 创建内容容器
 		contentBox := container.NewVBox(
-			roleLabel,
-			contentLabel,
+			container.NewBorder(nil, nil, nil, container.NewHBox(cw.newBranchNavigator(msg), editBtn), roleLabel),
 		)
+		if len(msg.Attachments) > 0 {
+			contentBox.Add(cw.newAttachmentsView(msg.Attachments))
+		}
+		if msg.Content != "" || len(msg.Attachments) == 0 {
+			contentBox.Add(contentLabel)
+		}
 
 		// 创建带柔和边距的背景
-		bg := canvas.NewRectangle(userMessageBg)
+		bg := canvas.NewRectangle(cw.cardBackground(msg, userMessageBg))
 
 		// 使用适度的内边距
 		cardContent := container.NewPadded(contentBox)
@@ -69,19 +114,78 @@ func (cw *ChatWindow) addMessage(msg *models.Message) *widget.RichText {
 		richText = widget.NewRichTextFromMarkdown(displayContent)
 		richText.Wrapping = fyne.TextWrapWord
 
+		// 右上角操作：分支切换，最新的回复可重新生成
+		actions := container.NewHBox(cw.newBranchNavigator(msg))
+		if cw.isLastMessage(msg) && msg.Status != models.StatusFailed && msg.ParentID != "" {
+			regenerateBtn := widget.NewButton("↻ 重新生成", func() {
+				cw.handleRegenerate(msg)
+			})
+			regenerateBtn.Importance = widget.LowImportance
+			actions.Add(regenerateBtn)
+		}
+
 		// 创建内容容器
 		contentBox := container.NewVBox(
-			roleLabel,
-			richText,
+			container.NewBorder(nil, nil, nil, actions, roleLabel),
 		)
+		if msg.Content != "" || len(msg.ToolCalls) == 0 {
+			contentBox.Add(richText)
+		}
+
+		// 发起的工具调用，默认折叠
+		if len(msg.ToolCalls) > 0 {
+			contentBox.Add(newToolCallsAccordion(msg.ToolCalls))
+		}
+
+		// 引用的知识库片段，默认折叠
+		if len(msg.Citations) > 0 {
+			contentBox.Add(newCitationsAccordion(msg.Citations))
+		}
+
+		// 未正常完成的回复附加状态提示
+		switch msg.Status {
+		case models.StatusInterrupted:
+			statusLabel := widget.NewLabel("⏹ 已中断")
+			statusLabel.TextStyle = fyne.TextStyle{Italic: true}
+			contentBox.Add(statusLabel)
+		case models.StatusFailed:
+			statusLabel := widget.NewLabel("⚠ 生成失败")
+			statusLabel.TextStyle = fyne.TextStyle{Italic: true}
+			retryBtn := widget.NewButton("↻ 从此处重试", func() {
+				cw.handleRetry(msg)
+			})
+			retryBtn.Importance = widget.LowImportance
+			contentBox.Add(container.NewHBox(statusLabel, retryBtn))
+		}
+
+		// 底部显示模型、token 数、耗时和费用估算
+		if msg.Usage != nil {
+			usageLabel := widget.NewLabel(cw.formatMessageUsage(msg.Usage))
+			usageLabel.SizeName = theme.SizeNameCaptionText
+			usageLabel.Importance = widget.LowImportance
+			contentBox.Add(usageLabel)
+		}
 
 		// 创建带柔和边距的背景
-		bg := canvas.NewRectangle(assistantBg)
+		bg := canvas.NewRectangle(cw.cardBackground(msg, assistantBg))
 
 		// 使用适度的内边距
 		cardContent := container.NewPadded(contentBox)
 		messageCard = container.NewStack(bg, cardContent)
 
+	case models.RoleTool:
+		// 工具结果 - 可折叠的卡片
+		resultLabel := widget.NewLabel(displayContent)
+		resultLabel.Wrapping = fyne.TextWrapWord
+
+		item := widget.NewAccordionItem(fmt.Sprintf("🔧 工具结果: %s", msg.ToolName), resultLabel)
+		contentBox := container.NewVBox(
+			container.NewBorder(nil, nil, nil, cw.newBranchNavigator(msg), widget.NewAccordion(item)),
+		)
+
+		bg := canvas.NewRectangle(cw.cardBackground(msg, toolMessageBg))
+		messageCard = container.NewStack(bg, container.NewPadded(contentBox))
+
 	case models.RoleSystem:
 		// 系统消息 - 简单样式
 		roleLabel := widget.NewLabel("⚙️ 系统")
@@ -106,13 +210,114 @@ func (cw *ChatWindow) addMessage(msg *models.Message) *widget.RichText {
 		spacer,
 	)
 
-	// 添加到消息容器，左右添加边距
-	cw.messageContainer.Add(
-		container.NewPadded(spacedCard),
-	)
-	cw.scrollToBottom()
+	// 左右添加边距
+	return container.NewPadded(spacedCard), richText
+}
 
-	return richText
+// newToolCallsAccordion 创建工具调用列表，每个调用可展开查看参数
+func newToolCallsAccordion(calls []models.ToolCall) *widget.Accordion {
+	accordion := widget.NewAccordion()
+	accordion.MultiOpen = true
+	for _, call := range calls {
+		argsLabel := widget.NewLabel(formatToolArguments(call.Arguments))
+		argsLabel.Wrapping = fyne.TextWrapWord
+		argsLabel.TextStyle = fyne.TextStyle{Monospace: true}
+		accordion.Append(widget.NewAccordionItem(fmt.Sprintf("🔧 调用工具: %s", call.Name), argsLabel))
+	}
+	return accordion
+}
+
+// newCitationsAccordion 创建参考资料列表，展开后显示每个片段的来源、相似度和摘录
+func newCitationsAccordion(citations []models.Citation) *widget.Accordion {
+	list := container.NewVBox()
+	for _, c := range citations {
+		sourceLabel := widget.NewLabel(fmt.Sprintf("[%d] %s（相似度 %.2f）", c.Index, c.Source, c.Score))
+		sourceLabel.TextStyle = fyne.TextStyle{Bold: true}
+
+		excerptLabel := widget.NewLabel(c.Content)
+		excerptLabel.Wrapping = fyne.TextWrapWord
+
+		list.Add(sourceLabel)
+		list.Add(excerptLabel)
+	}
+
+	return widget.NewAccordion(widget.NewAccordionItem(fmt.Sprintf("📎 参考资料 (%d)", len(citations)), list))
+}
+
+// formatToolArguments 格式化 JSON 参数便于阅读，非法 JSON 原样返回
+func formatToolArguments(arguments string) string {
+	var out bytes.Buffer
+	if err := json.Indent(&out, []byte(arguments), "", "  "); err != nil {
+		return arguments
+	}
+	return out.String()
+}
+
+// newBranchNavigator 创建 "◀ 2/3 ▶" 分支切换控件，消息没有兄弟分支时返回空容器
+func (cw *ChatWindow) newBranchNavigator(msg *models.Message) fyne.CanvasObject {
+	navigator := container.NewHBox()
+	branch := cw.branches[msg.ID]
+	if branch == nil {
+		return navigator
+	}
+
+	prevBtn := widget.NewButton("◀", func() {
+		cw.switchBranch(branch.PrevID)
+	})
+	prevBtn.Importance = widget.LowImportance
+	if branch.PrevID == "" {
+		prevBtn.Disable()
+	}
+
+	nextBtn := widget.NewButton("▶", func() {
+		cw.switchBranch(branch.NextID)
+	})
+	nextBtn.Importance = widget.LowImportance
+	if branch.NextID == "" {
+		nextBtn.Disable()
+	}
+
+	navigator.Add(prevBtn)
+	navigator.Add(widget.NewLabel(fmt.Sprintf("%d/%d", branch.Index+1, branch.Count)))
+	navigator.Add(nextBtn)
+	return navigator
+}
+
+// loadBranches 读取会话各消息的分支位置，供消息卡片显示分支切换控件
+// 加载会话时读取一次，之后在保存或删除消息（可能产生或移除兄弟分支）后重新读取
+func (cw *ChatWindow) loadBranches(sessionID string) {
+	branches, err := cw.db.GetBranches(sessionID)
+	if err != nil {
+		log.Printf("获取分支消息失败: %v", err)
+	}
+	cw.branches = branches
+}
+
+// isLastMessage 判断消息是否为当前分支的最后一条
+func (cw *ChatWindow) isLastMessage(msg *models.Message) bool {
+	return len(cw.messages) > 0 && cw.messages[len(cw.messages)-1].ID == msg.ID
+}
+
+// cardBackground 返回消息卡片背景色，搜索命中的消息使用高亮色
+func (cw *ChatWindow) cardBackground(msg *models.Message, defaultColor color.Color) color.Color {
+	if cw.highlightedMessageID != "" && msg.ID == cw.highlightedMessageID {
+		return highlightBg
+	}
+	return defaultColor
+}
+
+// scrollToMessage 滚动到指定消息，找不到时保持当前位置
+func (cw *ChatWindow) scrollToMessage(messageID string) {
+	for i, msg := range cw.messages {
+		if msg.ID != messageID || i >= len(cw.messageContainer.Objects) {
+			continue
+		}
+
+		// 先完成布局以获得卡片位置
+		cw.messageContainer.Refresh()
+		cw.scrollContainer.ScrollToOffset(fyne.NewPos(0, cw.messageContainer.Objects[i].Position().Y))
+		return
+	}
 }
 
 // scrollToBottom 滚动到底部
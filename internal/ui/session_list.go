@@ -2,25 +2,35 @@ package ui
 
 import (
 	"image/color"
+	"log"
+	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"github.com/wangle201210/gochat/internal/exporter"
 	"github.com/wangle201210/gochat/internal/models"
+	"github.com/wangle201210/gochat/internal/service/queue"
 )
 
 // sessionListItem 会话列表项
 type sessionListItem struct {
 	widget.BaseWidget
 	label      *widget.Label
+	spinner    *widget.Activity // 会话正在生成回复时显示
+	statusIcon *widget.Icon     // 排队、完成或失败时显示
+	status     queue.Status
 	deleteBtn  *widget.Button
 	background *canvas.Rectangle
 	content    *fyne.Container
 	container  *fyne.Container
 	onTapped   func()
 	onDelete   func()
+	onMenu     func(pos fyne.Position) // 右键菜单，pos 为点击位置（窗口坐标）
 }
 
 func newSessionListItem(text string, onTapped func(), onDelete func()) *sessionListItem {
@@ -38,11 +48,16 @@ func newSessionListItem(text string, onTapped func(), onDelete func()) *sessionL
 	})
 	item.deleteBtn.Importance = widget.LowImportance
 
+	item.spinner = widget.NewActivity()
+	item.spinner.Hide()
+	item.statusIcon = widget.NewIcon(nil)
+	item.statusIcon.Hide()
+
 	// 创建背景矩形（默认透明）
 	item.background = canvas.NewRectangle(color.Transparent)
 
 	// 创建内容容器
-	item.content = container.NewBorder(nil, nil, nil, item.deleteBtn, item.label)
+	item.content = container.NewBorder(nil, nil, nil, container.NewHBox(item.spinner, item.statusIcon, item.deleteBtn), item.label)
 
 	// 使用 Stack 将背景和内容叠加
 	item.container = container.NewStack(item.background, container.NewPadded(item.content))
@@ -60,6 +75,13 @@ func (i *sessionListItem) Tapped(_ *fyne.PointEvent) {
 	}
 }
 
+// TappedSecondary 右键点击时显示会话菜单
+func (i *sessionListItem) TappedSecondary(e *fyne.PointEvent) {
+	if i.onMenu != nil {
+		i.onMenu(e.AbsolutePosition)
+	}
+}
+
 func (i *sessionListItem) SetText(text string) {
 	i.label.SetText(text)
 }
@@ -73,6 +95,35 @@ func (i *sessionListItem) SetBold(bold bool) {
 	i.label.Refresh()
 }
 
+// SetStatus 显示会话生成任务的状态：进行中显示加载标记，排队、完成和失败显示对应图标
+func (i *sessionListItem) SetStatus(status queue.Status) {
+	if status == i.status {
+		return
+	}
+	i.status = status
+
+	if status == queue.StatusRunning {
+		i.spinner.Show()
+		i.spinner.Start()
+	} else {
+		i.spinner.Stop()
+		i.spinner.Hide()
+	}
+
+	switch status {
+	case queue.StatusQueued:
+		i.statusIcon.SetResource(theme.HistoryIcon())
+	case queue.StatusDone:
+		i.statusIcon.SetResource(theme.ConfirmIcon())
+	case queue.StatusFailed:
+		i.statusIcon.SetResource(theme.ErrorIcon())
+	default:
+		i.statusIcon.Hide()
+		return
+	}
+	i.statusIcon.Show()
+}
+
 func (i *sessionListItem) SetHighlight(highlight bool) {
 	if highlight {
 		// 高亮背景色 - 淡蓝色
@@ -87,17 +138,97 @@ func (i *sessionListItem) SetHighlight(highlight bool) {
 	i.label.Refresh()
 }
 
+// searchResultItem 搜索结果列表项
+type searchResultItem struct {
+	widget.BaseWidget
+	title    *widget.Label
+	snippet  *widget.RichText
+	content  *fyne.Container
+	onTapped func()
+}
+
+func newSearchResultItem() *searchResultItem {
+	item := &searchResultItem{
+		title:   widget.NewLabel("会话标题"),
+		snippet: widget.NewRichText(),
+	}
+	item.title.TextStyle = fyne.TextStyle{Bold: true}
+	item.title.Truncation = fyne.TextTruncateEllipsis
+	item.snippet.Truncation = fyne.TextTruncateEllipsis
+	item.content = container.NewVBox(item.title, item.snippet)
+	item.ExtendBaseWidget(item)
+	return item
+}
+
+func (i *searchResultItem) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(container.NewPadded(i.content))
+}
+
+func (i *searchResultItem) Tapped(_ *fyne.PointEvent) {
+	if i.onTapped != nil {
+		i.onTapped()
+	}
+}
+
+// SetResult 显示搜索结果，命中的关键词加粗
+func (i *searchResultItem) SetResult(result *models.SearchResult) {
+	i.title.SetText(result.SessionTitle)
+	i.snippet.Segments = snippetSegments(result.Snippet)
+	i.snippet.Refresh()
+}
+
+// snippetSegments 将带标记的搜索片段转换为 RichText 片段，关键词加粗
+func snippetSegments(snippet string) []widget.RichTextSegment {
+	snippet = strings.ReplaceAll(snippet, "\n", " ")
+	snippet = strings.Join(strings.Fields(snippet), " ")
+
+	segments := make([]widget.RichTextSegment, 0)
+	for snippet != "" {
+		start := strings.Index(snippet, models.SnippetMarkStart)
+		if start < 0 {
+			segments = append(segments, &widget.TextSegment{Text: snippet, Style: widget.RichTextStyleInline})
+			break
+		}
+		if start > 0 {
+			segments = append(segments, &widget.TextSegment{Text: snippet[:start], Style: widget.RichTextStyleInline})
+		}
+		snippet = snippet[start+len(models.SnippetMarkStart):]
+
+		end := strings.Index(snippet, models.SnippetMarkEnd)
+		if end < 0 {
+			end = len(snippet)
+		}
+		segments = append(segments, &widget.TextSegment{Text: snippet[:end], Style: widget.RichTextStyleStrong})
+		snippet = strings.TrimPrefix(snippet[end:], models.SnippetMarkEnd)
+	}
+	return segments
+}
+
 // SessionList 会话列表组件
 type SessionList struct {
 	widget.BaseWidget
 	sessions        []*models.Session
 	currentSession  *models.Session
+	statuses        map[string]queue.Status // 各会话生成任务的状态
 	onSessionSelect func(*models.Session)
 	onNewSession    func()
 	onDeleteSession func(*models.Session)
 	list            *widget.List
+	onExportSession func(*models.Session, exporter.Format)
+
+	// 搜索
+	search         func(query string) ([]*models.SearchResult, error)
+	onResultSelect func(*models.SearchResult)
+	results        []*models.SearchResult
+	searchEntry    *widget.Entry
+	resultList     *widget.List
+	searchTimer    *time.Timer // 输入停顿后执行搜索
+	searchSeq      int         // 每次输入递增，用于丢弃过期的搜索结果
 }
 
+// searchDelay 输入停顿多久后执行搜索
+const searchDelay = 250 * time.Millisecond
+
 // NewSessionList 创建会话列表
 func NewSessionList(onSessionSelect func(*models.Session), onNewSession func(), onDeleteSession func(*models.Session)) *SessionList {
 	sl := &SessionList{
@@ -142,6 +273,7 @@ func (sl *SessionList) CreateRenderer() fyne.WidgetRenderer {
 			// 高亮当前会话 - 使用背景色和粗体
 			isCurrentSession := sl.currentSession != nil && session.ID == sl.currentSession.ID
 			listItem.SetHighlight(isCurrentSession)
+			listItem.SetStatus(sl.statuses[session.ID])
 
 			// 设置回调
 			listItem.onTapped = func() {
@@ -154,21 +286,132 @@ func (sl *SessionList) CreateRenderer() fyne.WidgetRenderer {
 					sl.onDeleteSession(session)
 				}
 			}
+			listItem.onMenu = func(pos fyne.Position) {
+				sl.showSessionMenu(session, pos)
+			}
+		},
+	)
+
+	// 创建搜索结果列表（有搜索关键词时替换会话列表）
+	sl.resultList = widget.NewList(
+		func() int {
+			return len(sl.results)
+		},
+		func() fyne.CanvasObject {
+			return newSearchResultItem()
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			if id < 0 || id >= len(sl.results) {
+				return
+			}
+
+			result := sl.results[id]
+			resultItem := item.(*searchResultItem)
+			resultItem.SetResult(result)
+			resultItem.onTapped = func() {
+				if sl.onResultSelect != nil {
+					sl.onResultSelect(result)
+				}
+			}
 		},
 	)
+	sl.resultList.Hide()
+
+	// 创建搜索框
+	sl.searchEntry = widget.NewEntry()
+	sl.searchEntry.SetPlaceHolder("搜索会话和消息...")
+	sl.searchEntry.OnChanged = sl.scheduleSearch
 
 	content := container.NewBorder(
 		container.NewVBox(
+			sl.searchEntry,
 			newSessionBtn,
 			widget.NewSeparator(),
 		),
 		nil, nil, nil,
-		sl.list,
+		container.NewStack(sl.list, sl.resultList),
 	)
 
 	return widget.NewSimpleRenderer(content)
 }
 
+// SetSearchHandlers 设置搜索函数和搜索结果点击回调
+func (sl *SessionList) SetSearchHandlers(search func(query string) ([]*models.SearchResult, error), onResultSelect func(*models.SearchResult)) {
+	sl.search = search
+	sl.onResultSelect = onResultSelect
+}
+
+// SetExportHandler 设置会话菜单中导出的回调
+func (sl *SessionList) SetExportHandler(onExport func(*models.Session, exporter.Format)) {
+	sl.onExportSession = onExport
+}
+
+// showSessionMenu 在 pos 处显示会话的右键菜单
+func (sl *SessionList) showSessionMenu(session *models.Session, pos fyne.Position) {
+	var items []*fyne.MenuItem
+	if sl.onExportSession != nil {
+		for _, format := range exporter.Formats {
+			items = append(items, fyne.NewMenuItem("导出为 "+format.Label(), func() {
+				sl.onExportSession(session, format)
+			}))
+		}
+		items = append(items, fyne.NewMenuItemSeparator())
+	}
+	items = append(items, fyne.NewMenuItem("删除会话", func() {
+		if sl.onDeleteSession != nil {
+			sl.onDeleteSession(session)
+		}
+	}))
+
+	windowCanvas := fyne.CurrentApp().Driver().CanvasForObject(sl)
+	if windowCanvas == nil {
+		return
+	}
+	widget.ShowPopUpMenuAtPosition(fyne.NewMenu("", items...), windowCanvas, pos)
+}
+
+// scheduleSearch 输入停顿 searchDelay 后在后台执行搜索，关键词为空时立即恢复会话列表
+func (sl *SessionList) scheduleSearch(query string) {
+	if sl.searchTimer != nil {
+		sl.searchTimer.Stop()
+	}
+	sl.searchSeq++
+	seq := sl.searchSeq
+
+	query = strings.TrimSpace(query)
+	search := sl.search
+	if query == "" || search == nil {
+		sl.results = nil
+		sl.resultList.Hide()
+		sl.list.Show()
+		return
+	}
+
+	sl.searchTimer = time.AfterFunc(searchDelay, func() {
+		results, err := search(query)
+		if err != nil {
+			log.Printf("搜索失败: %v", err)
+			results = nil
+		}
+		fyne.Do(func() {
+			// 等待期间输入已变化，丢弃过期的结果
+			if seq != sl.searchSeq {
+				return
+			}
+			sl.showResults(results)
+		})
+	})
+}
+
+// showResults 用搜索结果替换会话列表
+func (sl *SessionList) showResults(results []*models.SearchResult) {
+	sl.results = results
+	sl.list.Hide()
+	sl.resultList.Show()
+	sl.resultList.Refresh()
+	sl.resultList.ScrollToTop()
+}
+
 // SetSessions 设置会话列表
 func (sl *SessionList) SetSessions(sessions []*models.Session) {
 	sl.sessions = sessions
@@ -185,6 +428,14 @@ func (sl *SessionList) SetCurrentSession(session *models.Session) {
 	}
 }
 
+// SetStatuses 设置各会话生成任务的状态，列表中显示对应标记
+func (sl *SessionList) SetStatuses(statuses map[string]queue.Status) {
+	sl.statuses = statuses
+	if sl.list != nil {
+		sl.list.Refresh()
+	}
+}
+
 // GetCurrentSession 获取当前会话
 func (sl *SessionList) GetCurrentSession() *models.Session {
 	return sl.currentSession
@@ -0,0 +1,167 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showSessionSettings 显示当前会话的系统提示词和模型参数设置对话框
+func (cw *ChatWindow) showSessionSettings() {
+	session := cw.currentSession
+	if session == nil {
+		return
+	}
+
+	systemPromptEntry := widget.NewMultiLineEntry()
+	systemPromptEntry.Wrapping = fyne.TextWrapWord
+	systemPromptEntry.SetMinRowsVisible(5)
+	systemPromptEntry.SetPlaceHolder("例如：你是一名资深 Go 工程师，回答简洁准确")
+	systemPromptEntry.SetText(session.SystemPrompt)
+
+	modelEntry := widget.NewEntry()
+	modelEntry.SetPlaceHolder("留空使用配置文件中的模型")
+	modelEntry.SetText(session.Model)
+
+	temperatureEntry := widget.NewEntry()
+	temperatureEntry.SetPlaceHolder("默认，范围 0 ~ 2")
+	temperatureEntry.SetText(formatOptionalFloat(session.Temperature))
+	temperatureEntry.Validator = optionalFloatValidator(0, 2)
+
+	topPEntry := widget.NewEntry()
+	topPEntry.SetPlaceHolder("默认，范围 0 ~ 1")
+	topPEntry.SetText(formatOptionalFloat(session.TopP))
+	topPEntry.Validator = optionalFloatValidator(0, 1)
+
+	maxTokensEntry := widget.NewEntry()
+	maxTokensEntry.SetPlaceHolder("默认")
+	if session.MaxTokens != nil {
+		maxTokensEntry.SetText(strconv.Itoa(*session.MaxTokens))
+	}
+	maxTokensEntry.Validator = func(text string) error {
+		if _, err := parseOptionalInt(text); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("系统提示词", systemPromptEntry),
+		widget.NewFormItem("模型", modelEntry),
+		widget.NewFormItem("Temperature", temperatureEntry),
+		widget.NewFormItem("Top P", topPEntry),
+		widget.NewFormItem("最大 Token 数", maxTokensEntry),
+	}
+
+	// 已启动的 MCP 服务，勾选表示在本会话中启用
+	mcpServers := cw.aiService.ToolGroups()
+	mcpCheck := widget.NewCheckGroup(mcpServers, nil)
+	mcpCheck.Horizontal = true
+	for _, server := range mcpServers {
+		if session.MCPServerEnabled(server) {
+			mcpCheck.Selected = append(mcpCheck.Selected, server)
+		}
+	}
+	if len(mcpServers) > 0 {
+		items = append(items, widget.NewFormItem("MCP 服务", mcpCheck))
+	}
+
+	settingsDialog := dialog.NewForm("会话设置", "保存", "取消", items, func(ok bool) {
+		if !ok {
+			return
+		}
+
+		// 表单已通过校验，这里的解析不会失败
+		temperature, _ := parseOptionalFloat(temperatureEntry.Text)
+		topP, _ := parseOptionalFloat(topPEntry.Text)
+		maxTokens, _ := parseOptionalInt(maxTokensEntry.Text)
+
+		session.SystemPrompt = strings.TrimSpace(systemPromptEntry.Text)
+		session.Model = strings.TrimSpace(modelEntry.Text)
+		session.Temperature = temperature
+		session.TopP = topP
+		session.MaxTokens = maxTokens
+
+		// 只更新当前已启动服务的开关，保留未启动服务原有的设置
+		disabled := make([]string, 0)
+		for _, server := range session.DisabledMCPServers {
+			if !slices.Contains(mcpServers, server) {
+				disabled = append(disabled, server)
+			}
+		}
+		for _, server := range mcpServers {
+			if !slices.Contains(mcpCheck.Selected, server) {
+				disabled = append(disabled, server)
+			}
+		}
+		session.DisabledMCPServers = disabled
+
+		if err := cw.db.UpdateSessionSettings(session); err != nil {
+			dialog.ShowError(err, cw.window)
+			return
+		}
+
+		// 当前会话的后续请求立即使用新设置
+		if cw.currentSession != nil && cw.currentSession.ID == session.ID {
+			cw.conversation.SetSession(session)
+		}
+	}, cw.window)
+	settingsDialog.Resize(fyne.NewSize(560, 480))
+	settingsDialog.Show()
+}
+
+// formatOptionalFloat 格式化可选数值，未设置时返回空字符串
+func formatOptionalFloat(v *float32) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(float64(*v), 'f', -1, 32)
+}
+
+// parseOptionalFloat 解析可选数值，空字符串表示未设置
+func parseOptionalFloat(text string) (*float32, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.ParseFloat(text, 32)
+	if err != nil {
+		return nil, fmt.Errorf("请输入数字")
+	}
+	f := float32(v)
+	return &f, nil
+}
+
+// parseOptionalInt 解析可选的正整数，空字符串表示未设置
+func parseOptionalInt(text string) (*int, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.Atoi(text)
+	if err != nil || v <= 0 {
+		return nil, fmt.Errorf("请输入正整数")
+	}
+	return &v, nil
+}
+
+// optionalFloatValidator 校验可选数值是否在 [min, max] 范围内
+func optionalFloatValidator(min, max float32) fyne.StringValidator {
+	return func(text string) error {
+		v, err := parseOptionalFloat(text)
+		if err != nil {
+			return err
+		}
+		if v != nil && (*v < min || *v > max) {
+			return fmt.Errorf("取值范围 %g ~ %g", min, max)
+		}
+		return nil
+	}
+}
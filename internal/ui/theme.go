@@ -12,6 +12,8 @@ var (
 	userMessageBg   = color.NRGBA{R: 240, G: 248, B: 255, A: 255} // 淡蓝白
 	assistantBg     = color.NRGBA{R: 255, G: 253, B: 245, A: 255} // 温暖米白
 	backgroundColor = color.NRGBA{R: 250, G: 252, B: 252, A: 255} // 清新白
+	highlightBg     = color.NRGBA{R: 255, G: 243, B: 196, A: 255} // 搜索命中高亮
+	toolMessageBg   = color.NRGBA{R: 245, G: 245, B: 245, A: 255} // 工具结果浅灰
 )
 
 // customTheme 自定义主题
@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// approveToolCall 弹出确认框询问是否执行工具调用，阻塞直到用户选择或 ctx 被取消
+// 由流式生成的 goroutine 调用；用户选择"本会话不再询问"的工具直接放行
+func (cw *ChatWindow) approveToolCall(ctx context.Context, sessionID string, call models.ToolCall) bool {
+	result := make(chan bool, 1)
+	reply := func(ok bool) {
+		select {
+		case result <- ok:
+		default:
+		}
+	}
+
+	var confirm dialog.Dialog
+	fyne.Do(func() {
+		if cw.allowedTools[sessionID][call.Name] {
+			reply(true)
+			return
+		}
+
+		text := fmt.Sprintf("模型请求调用工具 %s，参数如下：", call.Name)
+		// 后台生成的会话发起的调用，注明所属会话
+		if cw.currentSession == nil || cw.currentSession.ID != sessionID {
+			if session, err := cw.db.GetSession(sessionID); err == nil && session != nil {
+				text = fmt.Sprintf("会话「%s」中的模型请求调用工具 %s，参数如下：", session.Title, call.Name)
+			}
+		}
+		title := widget.NewLabel(text)
+		title.Wrapping = fyne.TextWrapWord
+
+		args := widget.NewLabel(formatToolArguments(call.Arguments))
+		args.Wrapping = fyne.TextWrapWord
+		args.TextStyle = fyne.TextStyle{Monospace: true}
+		argsScroll := container.NewVScroll(args)
+		argsScroll.SetMinSize(fyne.NewSize(420, 160))
+
+		alwaysCheck := widget.NewCheck("本会话内不再询问此工具", nil)
+
+		confirm = dialog.NewCustomConfirm("执行工具调用", "允许", "拒绝",
+			container.NewVBox(title, argsScroll, alwaysCheck),
+			func(ok bool) {
+				if ok && alwaysCheck.Checked {
+					if cw.allowedTools[sessionID] == nil {
+						cw.allowedTools[sessionID] = make(map[string]bool)
+					}
+					cw.allowedTools[sessionID][call.Name] = true
+				}
+				reply(ok)
+			}, cw.window)
+		confirm.Show()
+	})
+
+	select {
+	case ok := <-result:
+		return ok
+	case <-ctx.Done():
+		// 停止生成时关闭仍在等待的确认框
+		fyne.Do(func() {
+			if confirm != nil {
+				confirm.Hide()
+			}
+		})
+		return false
+	}
+}
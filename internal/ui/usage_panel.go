@@ -0,0 +1,160 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/wangle201210/gochat/internal/models"
+)
+
+// usageDays 用量面板统计的天数
+const usageDays = 30
+
+// showUsagePanel 显示当前会话和最近每天的 token 用量与费用估算
+func (cw *ChatWindow) showUsagePanel() {
+	var sessionStats []*models.UsageStat
+	if cw.currentSession != nil {
+		stats, err := cw.db.GetSessionUsage(cw.currentSession.ID)
+		if err != nil {
+			log.Printf("查询会话用量失败: %v", err)
+		}
+		sessionStats = stats
+	}
+
+	since := time.Now().AddDate(0, 0, -(usageDays - 1))
+	dailyStats, err := cw.db.GetDailyUsage(since)
+	if err != nil {
+		log.Printf("查询每日用量失败: %v", err)
+	}
+
+	// 当前会话：按模型列出
+	sessionGrid := newUsageGrid("模型")
+	sessionTotal := &models.UsageStat{Model: "合计"}
+	sessionCost := usageCost{}
+	for _, stat := range sessionStats {
+		cost := cw.usageCost(stat)
+		cw.addUsageRow(sessionGrid, stat.Model, stat, cost)
+		accumulateUsage(sessionTotal, stat)
+		sessionCost.add(cost)
+	}
+	if len(sessionStats) == 0 {
+		sessionGrid.Add(widget.NewLabel("暂无用量"))
+	} else {
+		cw.addUsageRow(sessionGrid, "合计", sessionTotal, sessionCost)
+	}
+
+	// 最近每天：同一天的多个模型合并为一行
+	dailyGrid := newUsageGrid("日期")
+	var day *models.UsageStat
+	dayCost := usageCost{}
+	flushDay := func() {
+		if day != nil {
+			cw.addUsageRow(dailyGrid, day.Day, day, dayCost)
+		}
+	}
+	for _, stat := range dailyStats {
+		if day == nil || day.Day != stat.Day {
+			flushDay()
+			day, dayCost = &models.UsageStat{Day: stat.Day}, usageCost{}
+		}
+		accumulateUsage(day, stat)
+		dayCost.add(cw.usageCost(stat))
+	}
+	flushDay()
+	if len(dailyStats) == 0 {
+		dailyGrid.Add(widget.NewLabel("暂无用量"))
+	}
+
+	note := widget.NewLabel("费用按配置文件中 pricing 的价格估算，带 * 表示部分模型未配置价格；provider 未返回 token 数时按字符数估算")
+	note.Wrapping = fyne.TextWrapWord
+	note.SizeName = theme.SizeNameCaptionText
+
+	content := container.NewVBox(
+		widget.NewCard("当前会话", "", sessionGrid),
+		widget.NewCard(fmt.Sprintf("最近 %d 天", usageDays), "", dailyGrid),
+		note,
+	)
+
+	scroll := container.NewVScroll(content)
+	scroll.SetMinSize(fyne.NewSize(560, 420))
+	dialog.ShowCustom("用量统计", "关闭", scroll, cw.window)
+}
+
+// usageCost 汇总后的费用估算
+type usageCost struct {
+	amount  float64
+	priced  bool // 至少有一个模型配置了价格
+	partial bool // 存在未配置价格的模型
+}
+
+func (c *usageCost) add(other usageCost) {
+	c.amount += other.amount
+	c.priced = c.priced || other.priced
+	c.partial = c.partial || other.partial
+}
+
+// usageCost 按价格表估算一条统计的费用
+func (cw *ChatWindow) usageCost(stat *models.UsageStat) usageCost {
+	amount, ok := cw.pricing.Cost(stat.Model, stat.PromptTokens, stat.CompletionTokens)
+	return usageCost{amount: amount, priced: ok, partial: !ok}
+}
+
+// formatCost 格式化费用，未配置任何价格时显示 "-"
+func (cw *ChatWindow) formatCost(cost usageCost) string {
+	if !cost.priced {
+		return "-"
+	}
+	text := fmt.Sprintf("%s%.4f", cw.pricing.CurrencySymbol(), cost.amount)
+	if cost.partial {
+		text += " *"
+	}
+	return text
+}
+
+// newUsageGrid 创建用量表格并写入表头
+func newUsageGrid(firstColumn string) *fyne.Container {
+	grid := container.NewGridWithColumns(5)
+	for _, title := range []string{firstColumn, "回复数", "输入 tokens", "输出 tokens", "费用"} {
+		label := widget.NewLabel(title)
+		label.TextStyle = fyne.TextStyle{Bold: true}
+		grid.Add(label)
+	}
+	return grid
+}
+
+// addUsageRow 向用量表格追加一行
+func (cw *ChatWindow) addUsageRow(grid *fyne.Container, name string, stat *models.UsageStat, cost usageCost) {
+	if name == "" {
+		name = "未知模型"
+	}
+	grid.Add(widget.NewLabel(name))
+	grid.Add(widget.NewLabel(fmt.Sprintf("%d", stat.Messages)))
+	grid.Add(widget.NewLabel(fmt.Sprintf("%d", stat.PromptTokens)))
+	grid.Add(widget.NewLabel(fmt.Sprintf("%d", stat.CompletionTokens)))
+	grid.Add(widget.NewLabel(cw.formatCost(cost)))
+}
+
+// accumulateUsage 将 stat 的用量累加到 total
+func accumulateUsage(total, stat *models.UsageStat) {
+	total.Messages += stat.Messages
+	total.PromptTokens += stat.PromptTokens
+	total.CompletionTokens += stat.CompletionTokens
+}
+
+// formatMessageUsage 格式化单条回复的用量，显示在助手消息卡片底部
+func (cw *ChatWindow) formatMessageUsage(usage *models.Usage) string {
+	text := fmt.Sprintf("%s · 输入 %d / 输出 %d tokens · %.1fs", usage.Model, usage.PromptTokens, usage.CompletionTokens, usage.Latency.Seconds())
+	if cost, ok := cw.pricing.Cost(usage.Model, usage.PromptTokens, usage.CompletionTokens); ok {
+		text += fmt.Sprintf(" · ≈%s%.4f", cw.pricing.CurrencySymbol(), cost)
+	}
+	if usage.FinishReason != "" && usage.FinishReason != "stop" {
+		text += " · " + usage.FinishReason
+	}
+	return text
+}
@@ -13,6 +13,9 @@ import (
 	"github.com/wangle201210/gochat/internal/models"
 	"github.com/wangle201210/gochat/internal/service/ai"
 	"github.com/wangle201210/gochat/internal/service/assistant"
+	"github.com/wangle201210/gochat/internal/service/generation"
+	"github.com/wangle201210/gochat/internal/service/knowledge"
+	"github.com/wangle201210/gochat/internal/service/queue"
 	"github.com/wangle201210/gochat/internal/storage"
 )
 
@@ -23,22 +26,37 @@ type ChatWindow struct {
 	aiService            *ai.Service
 	assistantService     *assistant.Service
 	uiConfig             *config.UIConfig
+	pricing              *config.PricingConfig // 模型价格表，用于费用估算
+	knowledge            *knowledge.Service    // 本地知识库，为空时不可用
 	db                   *storage.Database
 	messageContainer     *fyne.Container
 	scrollContainer      *container.Scroll
 	inputEntry           *customEntry
+	attachmentBar        *fyne.Container      // 输入框上方的待发送附件
+	pendingAttachments   []*models.Attachment // 随下一条消息发送的附件
 	sendButton           *widget.Button
+	stopButton           *widget.Button
+	sendArea             *fyne.Container
+	generations          *generation.Manager // 各会话正在进行的回复生成
+	queue                *queue.Queue        // 跨会话的生成队列，限制并发数和 provider 限速
+	placeholder          *placeholder        // 当前会话生成中回复的占位消息
 	messages             []*models.Message
 	currentSession       *models.Session
+	conversation         *ai.Conversation // 当前会话发送给模型的设置和历史，切换会话时重新创建
 	sessionList          *SessionList
 	sessionListContainer *fyne.Container
 	toggleButton         *widget.Button
+	titleLabel           *widget.Label
+	chatArea             *fyne.Container
 	mainContent          *fyne.Container
 	sessionListVisible   bool
+	highlightedMessageID string                        // 搜索跳转后高亮的消息
+	allowedTools         map[string]map[string]bool    // 各会话中无需再确认的工具
+	branches             map[string]*models.BranchInfo // 当前会话中有兄弟分支的消息及其位置
 }
 
 // NewChatWindow 创建聊天窗口
-func NewChatWindow(app fyne.App, aiService *ai.Service, assistantService *assistant.Service, uiConfig *config.UIConfig, db *storage.Database) *ChatWindow {
+func NewChatWindow(app fyne.App, aiService *ai.Service, assistantService *assistant.Service, uiConfig *config.UIConfig, pricing *config.PricingConfig, knowledgeService *knowledge.Service, generationQueue *queue.Queue, db *storage.Database) *ChatWindow {
 	window := app.NewWindow("GoChat - AI 对话助手")
 
 	// 应用自定义主题
@@ -50,12 +68,23 @@ func NewChatWindow(app fyne.App, aiService *ai.Service, assistantService *assist
 		aiService:          aiService,
 		assistantService:   assistantService,
 		uiConfig:           uiConfig,
+		pricing:            pricing,
+		knowledge:          knowledgeService,
+		queue:              generationQueue,
+		generations:        generation.NewManager(db, generationQueue),
 		db:                 db,
 		messages:           make([]*models.Message, 0),
+		allowedTools:       make(map[string]map[string]bool),
 		sessionListVisible: true, // 默认显示会话列表
 	}
-
 	cw.setupUI()
+
+	// 队列状态变化时更新会话列表中的标记，状态保存在队列中，窗口重新获得焦点或切换会话后仍然有效
+	cw.queue.SetOnChange(func() {
+		fyne.Do(func() {
+			cw.sessionList.SetStatuses(cw.queue.Statuses())
+		})
+	})
 	cw.initializeSession()
 	return cw
 }
@@ -101,20 +130,35 @@ func (cw *ChatWindow) setupUI() {
 	cw.sendButton = widget.NewButton("发送消息", cw.handleSend)
 	cw.sendButton.Importance = widget.HighImportance
 
+	// 停止按钮，流式生成期间替换发送按钮
+	cw.stopButton = widget.NewButton("停止生成", cw.handleStop)
+	cw.stopButton.Importance = widget.DangerImportance
+	cw.stopButton.Hide()
+	cw.sendArea = container.NewStack(cw.sendButton, cw.stopButton)
+
 	// 创建切换按钮
 	cw.toggleButton = widget.NewButton("☰", cw.toggleSessionList)
 	cw.toggleButton.Importance = widget.LowImportance
 
+	// 附件按钮，也可以把文件拖放到窗口上
+	attachButton := widget.NewButton("📎", cw.showAttachmentPicker)
+	attachButton.Importance = widget.LowImportance
+	cw.attachmentBar = container.NewStack()
+	cw.attachmentBar.Hide()
+	cw.window.SetOnDropped(cw.handleDrop)
+
 	// 底部按钮栏
 	buttonBar := container.NewHBox(
 		cw.toggleButton,
+		attachButton,
 		layout.NewSpacer(),
-		cw.sendButton,
+		cw.sendArea,
 	)
 
 	// 输入区域容器
 	inputCard := container.NewVBox(
 		widget.NewSeparator(),
+		cw.attachmentBar,
 		container.NewPadded(cw.inputEntry),
 		container.NewPadded(buttonBar),
 	)
@@ -125,6 +169,10 @@ func (cw *ChatWindow) setupUI() {
 		cw.onNewSession,
 		cw.onDeleteSession,
 	)
+	cw.sessionList.SetSearchHandlers(func(query string) ([]*models.SearchResult, error) {
+		return cw.db.Search(query, 50, 0)
+	}, cw.onSearchResultSelect)
+	cw.sessionList.SetExportHandler(cw.onExportSession)
 
 	// 会话列表区域
 	cw.sessionListContainer = container.NewBorder(
@@ -135,9 +183,24 @@ func (cw *ChatWindow) setupUI() {
 	// 使用固定宽度容器包装会话列表
 	sessionListFixed := newFixedWidthContainer(200, cw.sessionListContainer)
 
+	// 顶部标题栏：会话标题和会话设置入口
+	cw.titleLabel = widget.NewLabel("新会话")
+	cw.titleLabel.TextStyle = fyne.TextStyle{Bold: true}
+	cw.titleLabel.Truncation = fyne.TextTruncateEllipsis
+	settingsButton := widget.NewButton("⚙ 会话设置", cw.showSessionSettings)
+	settingsButton.Importance = widget.LowImportance
+	usageButton := widget.NewButton("📊 用量", cw.showUsagePanel)
+	usageButton.Importance = widget.LowImportance
+	knowledgeButton := widget.NewButton("📚 知识库", cw.showKnowledgePanel)
+	knowledgeButton.Importance = widget.LowImportance
+	header := container.NewVBox(
+		container.NewBorder(nil, nil, nil, container.NewHBox(knowledgeButton, usageButton, settingsButton), cw.titleLabel),
+		widget.NewSeparator(),
+	)
+
 	// 主聊天区域
-	chatArea := container.NewBorder(
-		nil,
+	cw.chatArea = container.NewBorder(
+		header,
 		inputCard,
 		nil,
 		nil,
@@ -149,11 +212,18 @@ func (cw *ChatWindow) setupUI() {
 		nil, nil,
 		sessionListFixed,
 		nil,
-		chatArea,
+		cw.chatArea,
 	)
 
 	cw.window.SetContent(cw.mainContent)
 
+	// 菜单栏
+	cw.window.SetMainMenu(fyne.NewMainMenu(
+		fyne.NewMenu("文件",
+			fyne.NewMenuItem("导入对话...", cw.showImportPicker),
+		),
+	))
+
 	// 使用配置中的窗口尺寸
 	windowWidth := cw.uiConfig.WindowWidth
 	windowHeight := cw.uiConfig.WindowHeight
@@ -170,32 +240,15 @@ func (cw *ChatWindow) setupUI() {
 func (cw *ChatWindow) toggleSessionList() {
 	cw.sessionListVisible = !cw.sessionListVisible
 
-	// 创建聊天区域
-	chatArea := container.NewBorder(
-		nil,
-		container.NewVBox(
-			widget.NewSeparator(),
-			container.NewPadded(cw.inputEntry),
-			container.NewPadded(container.NewHBox(
-				cw.toggleButton,
-				layout.NewSpacer(),
-				cw.sendButton,
-			)),
-		),
-		nil,
-		nil,
-		cw.scrollContainer,
-	)
-
 	if cw.sessionListVisible {
 		// 显示会话列表
 		cw.toggleButton.SetText("☰")
 		sessionListFixed := newFixedWidthContainer(200, cw.sessionListContainer)
-		cw.mainContent = container.NewBorder(nil, nil, sessionListFixed, nil, chatArea)
+		cw.mainContent = container.NewBorder(nil, nil, sessionListFixed, nil, cw.chatArea)
 	} else {
 		// 隐藏会话列表
 		cw.toggleButton.SetText("→")
-		cw.mainContent = chatArea
+		cw.mainContent = cw.chatArea
 	}
 
 	cw.window.SetContent(cw.mainContent)
@@ -223,14 +276,18 @@ func (cw *ChatWindow) createNewSession() {
 	}
 
 	// 清空当前消息和 AI 历史
+	cw.highlightedMessageID = ""
 	cw.messages = make([]*models.Message, 0)
-	cw.aiService.ClearHistory()
+	cw.placeholder = nil
+	cw.conversation = ai.NewConversation(newSession, nil)
 	cw.messageContainer.Objects = []fyne.CanvasObject{}
 	cw.messageContainer.Refresh()
 
 	// 设置当前会话
 	cw.currentSession = newSession
+	cw.titleLabel.SetText(newSession.Title)
 	cw.sessionList.SetCurrentSession(newSession)
+	cw.updateSendButton()
 	cw.refreshSessionList()
 }
 
@@ -245,16 +302,19 @@ func (cw *ChatWindow) loadSession(session *models.Session) {
 		cw.saveCurrentMessages()
 	}
 
-	// 加载会话消息
-	messages, err := cw.db.GetMessages(session.ID)
+	// 加载会话当前分支的消息
+	messages, err := cw.db.GetActivePath(session.ID)
 	if err != nil {
 		log.Printf("加载会话消息失败: %v", err)
 		dialog.ShowError(err, cw.window)
 		return
 	}
 
+	cw.loadBranches(session.ID)
+
 	// 清空当前界面
 	cw.messages = make([]*models.Message, 0)
+	cw.placeholder = nil
 	cw.messageContainer.Objects = []fyne.CanvasObject{}
 
 	// 重新加载所有消息到界面
@@ -262,17 +322,26 @@ func (cw *ChatWindow) loadSession(session *models.Session) {
 		cw.addMessage(msg)
 	}
 
-	// 恢复 AI 服务的历史记录
-	cw.aiService.SetHistory(messages)
+	// 恢复发送给模型的历史记录和会话设置；会话仍在生成回复时沿用生成中的对话，并显示已生成的内容
+	cw.conversation = cw.generations.Conversation(session, messages)
+	if gen := cw.generations.Get(session.ID); gen != nil {
+		cw.ensurePlaceholder(gen)
+	}
 
 	cw.currentSession = session
+	cw.titleLabel.SetText(session.Title)
 	cw.sessionList.SetCurrentSession(session)
+	cw.updateSendButton()
+
+	// 已查看会话，清除完成或失败的标记
+	cw.queue.MarkSeen(session.ID)
 	cw.scrollToBottom()
 }
 
 // saveCurrentMessages 保存当前会话的消息
+// 正在生成回复时跳过，界面上的占位消息不是真正的回复，生成结束后会写入原会话
 func (cw *ChatWindow) saveCurrentMessages() {
-	if cw.currentSession == nil {
+	if cw.currentSession == nil || cw.generating() {
 		return
 	}
 
@@ -317,14 +386,55 @@ func (cw *ChatWindow) onNewSession() {
 // onSessionSelect 选择会话回调
 func (cw *ChatWindow) onSessionSelect(session *models.Session) {
 	if session != nil && (cw.currentSession == nil || session.ID != cw.currentSession.ID) {
+		cw.highlightedMessageID = ""
 		cw.loadSession(session)
 	}
 }
 
+// onSearchResultSelect 搜索结果点击回调：跳转到对应会话并高亮命中的消息
+func (cw *ChatWindow) onSearchResultSelect(result *models.SearchResult) {
+	previous := cw.highlightedMessageID
+	cw.highlightedMessageID = result.MessageID
+
+	sameSession := cw.currentSession != nil && cw.currentSession.ID == result.SessionID
+	if sameSession && (result.MessageID == "" || cw.messageIndex(result.MessageID) >= 0) {
+		// 命中的消息已在当前分支上，只需重绘高亮的消息卡片
+		for i, msg := range cw.messages {
+			if msg.ID == previous || msg.ID == result.MessageID {
+				cw.updateMessage(i, msg)
+			}
+		}
+	} else {
+		// 命中的消息可能位于其他分支，先切换过去；会话正在生成回复时不切换分支
+		if result.MessageID != "" && cw.generations.Get(result.SessionID) == nil {
+			if err := cw.db.SelectBranch(result.SessionID, result.MessageID); err != nil {
+				log.Printf("切换分支失败: %v", err)
+			}
+		}
+
+		session, err := cw.db.GetSession(result.SessionID)
+		if err != nil {
+			log.Printf("加载会话失败: %v", err)
+			dialog.ShowError(err, cw.window)
+			return
+		}
+		if session == nil {
+			return
+		}
+		cw.loadSession(session)
+	}
+
+	cw.scrollToMessage(result.MessageID)
+}
+
 // onDeleteSession 删除会话回调
 func (cw *ChatWindow) onDeleteSession(session *models.Session) {
 	dialog.ShowConfirm("确认删除", "确定要删除这个会话吗？所有消息将被删除。", func(ok bool) {
 		if ok {
+			// 停止该会话正在进行的生成并移出生成队列，生成结果不再保存
+			cw.generations.Stop(session.ID, true)
+			cw.queue.Clear(session.ID)
+
 			if err := cw.db.DeleteSession(session.ID); err != nil {
 				log.Printf("删除会话失败: %v", err)
 				dialog.ShowError(err, cw.window)